@@ -13,6 +13,7 @@ import (
 	"github.com/gin-gonic/gin"
 
 	"github.com/0p5dev/controller/internal/api"
+	"github.com/0p5dev/controller/internal/config"
 	"github.com/0p5dev/controller/internal/middleware"
 )
 
@@ -47,7 +48,12 @@ func main() {
 
 	err := api.Initialize(router)
 	if err != nil {
-		slog.Error("Failed to initialize application", "error", err)
+		var missingEnvVarsErr *config.MissingEnvVarsError
+		if errors.As(err, &missingEnvVarsErr) {
+			slog.Error("Failed to initialize application: required environment variables are not set", "missing", missingEnvVarsErr.Missing)
+		} else {
+			slog.Error("Failed to initialize application", "error", err)
+		}
 		os.Exit(1)
 	}
 
@@ -1,79 +1,130 @@
 package api
 
 import (
+	"context"
 	"fmt"
-	"log/slog"
-	"os"
+	"net"
+	"net/http"
+	"slices"
 
 	"github.com/gin-contrib/cors"
 	"github.com/gin-gonic/gin"
 
+	"github.com/0p5dev/controller/internal/config"
+	deploymentsHandler "github.com/0p5dev/controller/internal/handlers/deployments"
+	"github.com/0p5dev/controller/internal/jobs"
 	"github.com/0p5dev/controller/internal/middleware"
 	"github.com/0p5dev/controller/internal/routes"
+	"github.com/0p5dev/controller/internal/sharedUtils"
 )
 
-func ensureEnvVars() error {
-	requiredVars := []string{
-		"POSTGRES_CONNECTION_STRING",
-		"SUPABASE_JWT_SECRET",
-		"GCP_PROJECT_ID",
-		"GCP_REGION",
-		"SERVICE_ACCOUNT_EMAIL",
-		"AR_REPO_URL",
-		"STRIPE_API_KEY",
-		"STRIPE_WEBHOOK_SIGNING_SECRET",
-		"CLOUD_STORAGE_BUCKET_NAME",
-	}
+// Google Front End ranges that sit in front of Cloud Run
+var defaultTrustedProxies = []string{"35.191.0.0/16", "130.211.0.0/22"}
 
-	for _, v := range requiredVars {
-		if os.Getenv(v) == "" {
-			return fmt.Errorf("environment variable %s is required", v)
-		}
+func Initialize(router *gin.Engine) error {
+	// Load config once and fail fast if required environment variables are missing
+	if _, err := config.Load(); err != nil {
+		return err
 	}
 
-	return nil
-}
-
-func Initialize(router *gin.Engine) error {
-	// Fail fast if required environment variables are missing
-	if err := ensureEnvVars(); err != nil {
+	// Configure the default logger from LOG_LEVEL and LOG_FORMAT
+	if err := middleware.ConfigureLogging(); err != nil {
 		return err
 	}
 
-	// Configure logging level based on environment
-	logLevel := slog.LevelInfo
-	if os.Getenv("GIN_MODE") != "release" {
-		logLevel = slog.LevelDebug
+	// Register the custom binding tags used by request bodies
+	if err := deploymentsHandler.RegisterValidators(); err != nil {
+		return fmt.Errorf("failed to register request validators: %w", err)
 	}
-	slog.SetLogLoggerLevel(logLevel)
-
-	// Configure CORS
-	corsConfig := cors.Config{
-		AllowOrigins:  []string{"*"},
-		AllowMethods:  []string{"GET", "POST", "PUT", "DELETE", "OPTIONS"},
-		AllowHeaders:  []string{"Origin", "Content-Type", "Authorization"},
-		ExposeHeaders: []string{"Content-Length"},
+
+	// Check the image upload bucket up front; local development without GCP credentials can opt out
+	if !config.Get().SkipBucketCheck {
+		if err := checkStorageBucket(context.Background()); err != nil {
+			return err
+		}
 	}
-	router.Use(cors.New(corsConfig))
 
-	// Not using a proxy, so disable trusted proxy checking
-	router.SetTrustedProxies(nil)
+	// CORS must run before every route, but its allowed methods come from the routes themselves,
+	// so the handler is built once routes are registered below
+	var corsHandler gin.HandlerFunc
+	router.Use(func(c *gin.Context) {
+		corsHandler(c)
+	})
+
+	// Only trust X-Forwarded-For from known proxies so c.ClientIP() can't be spoofed
+	trustedProxies, err := trustedProxyRanges()
+	if err != nil {
+		return err
+	}
+	if err := router.SetTrustedProxies(trustedProxies); err != nil {
+		return fmt.Errorf("failed to set trusted proxies: %w", err)
+	}
 
 	// Recovery middleware by default and logging per environment
 	router.Use(gin.Recovery())
-	if os.Getenv("GIN_MODE") == "release" {
-		router.Use(middleware.SloggerMiddleware())
-	} else {
-		router.Use(gin.Logger())
-	}
+	router.Use(middleware.RequestIdMiddleware())
+	router.Use(middleware.SloggerMiddleware())
 
 	// Inject neccessary dependencies into the context for handlers to use
 	router.Use(middleware.DatabaseMiddleware())
 	router.Use(middleware.HubMiddleware())
 	router.Use(middleware.StripeMiddleware())
 
+	// Start background jobs once the database pool is available
+	if pool := middleware.DatabasePool(); pool != nil {
+		jobs.StartOrphanedServiceCleanup(pool)
+		jobs.StartDeletedDeploymentPurge(pool)
+	}
+
+	// Image names contain slashes, so they are passed URL-encoded as a single path segment
+	router.UseRawPath = true
+
 	// Create API routes
 	routes.CreateRoutes(router)
 
+	corsHandler = cors.New(newCorsConfig(router))
+
 	return nil
 }
+
+// newCorsConfig allows the origins in the CORS allowlist (only non-production falls back to allowing any origin) to
+// call the routes registered on router
+func newCorsConfig(router *gin.Engine) cors.Config {
+	return cors.Config{
+		AllowOrigins:  middleware.AllowedOrigins(),
+		AllowMethods:  registeredMethods(router),
+		AllowHeaders:  []string{"Origin", "Content-Type", "Authorization", "Idempotency-Key", "If-None-Match", "If-Match", middleware.RequestIdHeader},
+		ExposeHeaders: []string{"Content-Length", "X-Total-Count", "Link", "ETag", middleware.RequestIdHeader},
+	}
+}
+
+// registeredMethods lists every HTTP method used by a registered route plus OPTIONS for preflight,
+// so the CORS config can't fall out of sync with the route table
+func registeredMethods(router *gin.Engine) []string {
+	methods := []string{http.MethodOptions}
+	for _, route := range router.Routes() {
+		if !slices.Contains(methods, route.Method) {
+			methods = append(methods, route.Method)
+		}
+	}
+	return methods
+}
+
+// trustedProxyRanges reads TRUSTED_PROXIES (comma-separated CIDRs or IPs), defaulting to the Google Front End
+// ranges in production and to any address in development. Malformed entries fail startup.
+func trustedProxyRanges() ([]string, error) {
+	trustedProxies := sharedUtils.GetEnvList("TRUSTED_PROXIES")
+	if len(trustedProxies) == 0 {
+		if config.Get().Release {
+			return defaultTrustedProxies, nil
+		}
+		return []string{"0.0.0.0/0", "::/0"}, nil
+	}
+
+	for _, proxy := range trustedProxies {
+		if _, _, err := net.ParseCIDR(proxy); err != nil && net.ParseIP(proxy) == nil {
+			return nil, fmt.Errorf("invalid TRUSTED_PROXIES entry %q: must be a CIDR or IP address", proxy)
+		}
+	}
+	return trustedProxies, nil
+}
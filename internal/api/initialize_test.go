@@ -0,0 +1,110 @@
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/gin-contrib/cors"
+	"github.com/gin-gonic/gin"
+)
+
+// newCorsTestRouter wires CORS the way Initialize does: in front of every route, configured once they're registered.
+// A deployment route is registered for each method.
+func newCorsTestRouter(methods ...string) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	var corsHandler gin.HandlerFunc
+	router.Use(func(c *gin.Context) {
+		corsHandler(c)
+	})
+
+	for _, method := range methods {
+		router.Handle(method, "/api/v1/deployments/:name", func(c *gin.Context) { c.Status(http.StatusOK) })
+	}
+
+	corsHandler = cors.New(newCorsConfig(router))
+	return router
+}
+
+func preflight(router *gin.Engine, origin string, method string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodOptions, "/api/v1/deployments/api", nil)
+	req.Header.Set("Origin", origin)
+	req.Header.Set("Access-Control-Request-Method", method)
+	req.Header.Set("Access-Control-Request-Headers", "Authorization, Content-Type, If-Match")
+	recorder := httptest.NewRecorder()
+	router.ServeHTTP(recorder, req)
+	return recorder
+}
+
+func TestCorsAllowsListedOrigins(t *testing.T) {
+	t.Setenv("CORS_ALLOWED_ORIGINS", "https://0p5.dev, https://staging.0p5.dev")
+	router := newCorsTestRouter(http.MethodGet, http.MethodPatch)
+
+	for _, origin := range []string{"https://0p5.dev", "https://staging.0p5.dev"} {
+		recorder := preflight(router, origin, http.MethodPatch)
+		if recorder.Code != http.StatusNoContent {
+			t.Errorf("preflight from %s: status = %d, want 204", origin, recorder.Code)
+		}
+		if got := recorder.Header().Get("Access-Control-Allow-Origin"); got != origin {
+			t.Errorf("preflight from %s: Access-Control-Allow-Origin = %q", origin, got)
+		}
+		if methods := recorder.Header().Get("Access-Control-Allow-Methods"); !strings.Contains(methods, http.MethodPatch) {
+			t.Errorf("preflight from %s: Access-Control-Allow-Methods = %q, want PATCH", origin, methods)
+		}
+	}
+}
+
+func TestCorsRejectsUnlistedOrigins(t *testing.T) {
+	t.Setenv("CORS_ALLOWED_ORIGINS", "https://0p5.dev")
+	router := newCorsTestRouter(http.MethodGet, http.MethodPatch)
+
+	recorder := preflight(router, "https://evil.example.com", http.MethodPatch)
+	if recorder.Code != http.StatusForbidden {
+		t.Errorf("status = %d, want 403", recorder.Code)
+	}
+	if got := recorder.Header().Get("Access-Control-Allow-Origin"); got != "" {
+		t.Errorf("Access-Control-Allow-Origin = %q, want none", got)
+	}
+}
+
+func TestCorsAllowsRegisteredMethods(t *testing.T) {
+	t.Setenv("CORS_ALLOWED_ORIGINS", "https://0p5.dev")
+	router := newCorsTestRouter(http.MethodGet, http.MethodPatch)
+
+	// DELETE isn't registered on the test router, so it isn't allowed until a route uses it
+	if methods := preflight(router, "https://0p5.dev", http.MethodDelete).Header().Get("Access-Control-Allow-Methods"); strings.Contains(methods, http.MethodDelete) {
+		t.Errorf("Access-Control-Allow-Methods = %q, want DELETE left out", methods)
+	}
+
+	router = newCorsTestRouter(http.MethodGet, http.MethodPatch, http.MethodDelete)
+	recorder := preflight(router, "https://0p5.dev", http.MethodDelete)
+	if recorder.Code != http.StatusNoContent {
+		t.Errorf("DELETE preflight: status = %d, want 204", recorder.Code)
+	}
+	if methods := recorder.Header().Get("Access-Control-Allow-Methods"); !strings.Contains(methods, http.MethodDelete) {
+		t.Errorf("Access-Control-Allow-Methods = %q, want DELETE", methods)
+	}
+}
+
+func TestRegisteredMethods(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	handler := func(c *gin.Context) {}
+	router.GET("/a", handler)
+	router.GET("/b", handler)
+	router.DELETE("/a", handler)
+	router.PUT("/a", handler)
+
+	methods := registeredMethods(router)
+	if len(methods) != 4 {
+		t.Errorf("methods = %v, want each method once", methods)
+	}
+	for _, method := range []string{http.MethodOptions, http.MethodGet, http.MethodDelete, http.MethodPut} {
+		if !slices.Contains(methods, method) {
+			t.Errorf("methods = %v, want %s", methods, method)
+		}
+	}
+}
@@ -0,0 +1,56 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"time"
+
+	"cloud.google.com/go/storage"
+
+	"github.com/0p5dev/controller/internal/config"
+)
+
+// Image uploads are written through signed URLs issued by the controller's service account and read back when the
+// image is pushed, so the service account needs both
+var requiredBucketPermissions = []string{"storage.objects.create", "storage.objects.get"}
+
+// checkStorageBucket fails startup when CLOUD_STORAGE_BUCKET_NAME doesn't exist or the service account can't use
+// it, instead of leaving the first image push to fail halfway through
+func checkStorageBucket(ctx context.Context) error {
+	bucketName := config.Get().CloudStorageBucketName
+
+	ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+
+	client, err := storage.NewClient(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to create cloud storage client: %w", err)
+	}
+	defer client.Close()
+
+	bucket := client.Bucket(bucketName)
+	if _, err := bucket.Attrs(ctx); err != nil {
+		if errors.Is(err, storage.ErrBucketNotExist) {
+			return fmt.Errorf("cloud storage bucket %s does not exist", bucketName)
+		}
+		return fmt.Errorf("failed to read cloud storage bucket %s: %w", bucketName, err)
+	}
+
+	granted, err := bucket.IAM().TestPermissions(ctx, requiredBucketPermissions)
+	if err != nil {
+		return fmt.Errorf("failed to check permissions on cloud storage bucket %s: %w", bucketName, err)
+	}
+	var missing []string
+	for _, permission := range requiredBucketPermissions {
+		if !slices.Contains(granted, permission) {
+			missing = append(missing, permission)
+		}
+	}
+	if len(missing) > 0 {
+		return fmt.Errorf("service account is missing %v on cloud storage bucket %s", missing, bucketName)
+	}
+
+	return nil
+}
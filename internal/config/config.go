@@ -0,0 +1,122 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"sync/atomic"
+)
+
+// Config holds the settings read from the environment once at startup. Tuning knobs that have safe defaults
+// (e.g. GCP_RETRY_MAX_ATTEMPTS) are still read with sharedUtils.GetEnvInt where they are used.
+type Config struct {
+	PostgresConnectionString   string
+	SupabaseJwtSecret          string
+	GcpProjectId               string
+	GcpRegion                  string
+	ServiceAccountEmail        string
+	ArRepoUrl                  string
+	StripeApiKey               string
+	StripeWebhookSigningSecret string
+	CloudStorageBucketName     string
+
+	// Optional settings and feature flags
+	Release               bool   // GIN_MODE=release
+	PublicBaseUrl         string // PUBLIC_BASE_URL, without a trailing slash
+	ArPerUserPath         bool   // AR_PER_USER_PATH=true
+	SkipVulnerabilityScan bool   // SKIP_VULNERABILITY_SCAN=true
+	OrphanCleanupEnabled  bool   // ORPHAN_CLEANUP_ENABLED=true
+	SkipBucketCheck       bool   // SKIP_BUCKET_CHECK=true
+	// DefaultDeletionProtection protects new deployments that don't set deletion_protection themselves
+	DefaultDeletionProtection bool // DEFAULT_DELETION_PROTECTION=true
+	// DefaultCpu and DefaultMemory limit the primary container of deployments that don't set cpu or memory
+	// themselves; when unset, Cloud Run's defaults apply
+	DefaultCpu    string // DEFAULT_CPU, e.g. 1
+	DefaultMemory string // DEFAULT_MEMORY, e.g. 512Mi
+}
+
+var current atomic.Pointer[Config]
+
+// MissingEnvVarsError lists every required environment variable that is unset or blank
+type MissingEnvVarsError struct {
+	Missing []string
+}
+
+func (e *MissingEnvVarsError) Error() string {
+	return fmt.Sprintf("missing required environment variables: %s", strings.Join(e.Missing, ", "))
+}
+
+// Load reads the environment, fails with every missing required variable listed, and makes the result
+// available through Get
+func Load() (*Config, error) {
+	cfg := fromEnv()
+
+	required := []struct {
+		name  string
+		value string
+	}{
+		{"POSTGRES_CONNECTION_STRING", cfg.PostgresConnectionString},
+		{"SUPABASE_JWT_SECRET", cfg.SupabaseJwtSecret},
+		{"GCP_PROJECT_ID", cfg.GcpProjectId},
+		{"GCP_REGION", cfg.GcpRegion},
+		{"SERVICE_ACCOUNT_EMAIL", cfg.ServiceAccountEmail},
+		{"AR_REPO_URL", cfg.ArRepoUrl},
+		{"STRIPE_API_KEY", cfg.StripeApiKey},
+		{"STRIPE_WEBHOOK_SIGNING_SECRET", cfg.StripeWebhookSigningSecret},
+		{"CLOUD_STORAGE_BUCKET_NAME", cfg.CloudStorageBucketName},
+	}
+
+	var missing []string
+	for _, v := range required {
+		// A whitespace-only value is as good as unset, e.g. an empty JWT secret padded by a .env file
+		if strings.TrimSpace(v.value) == "" {
+			missing = append(missing, v.name)
+		}
+	}
+	if len(missing) > 0 {
+		return nil, &MissingEnvVarsError{Missing: missing}
+	}
+
+	Set(cfg)
+	return cfg, nil
+}
+
+// Get returns the config loaded at startup, falling back to an unvalidated read of the environment
+// if Load hasn't run
+func Get() *Config {
+	if cfg := current.Load(); cfg != nil {
+		return cfg
+	}
+	return fromEnv()
+}
+
+// Set replaces the active config, e.g. to inject one in tests
+func Set(cfg *Config) {
+	current.Store(cfg)
+}
+
+func fromEnv() *Config {
+	return &Config{
+		PostgresConnectionString:   os.Getenv("POSTGRES_CONNECTION_STRING"),
+		SupabaseJwtSecret:          os.Getenv("SUPABASE_JWT_SECRET"),
+		GcpProjectId:               os.Getenv("GCP_PROJECT_ID"),
+		GcpRegion:                  os.Getenv("GCP_REGION"),
+		ServiceAccountEmail:        os.Getenv("SERVICE_ACCOUNT_EMAIL"),
+		ArRepoUrl:                  os.Getenv("AR_REPO_URL"),
+		StripeApiKey:               os.Getenv("STRIPE_API_KEY"),
+		StripeWebhookSigningSecret: os.Getenv("STRIPE_WEBHOOK_SIGNING_SECRET"),
+		CloudStorageBucketName:     os.Getenv("CLOUD_STORAGE_BUCKET_NAME"),
+
+		Release:               os.Getenv("GIN_MODE") == "release",
+		PublicBaseUrl:         strings.TrimSuffix(os.Getenv("PUBLIC_BASE_URL"), "/"),
+		ArPerUserPath:         os.Getenv("AR_PER_USER_PATH") == "true",
+		SkipVulnerabilityScan: os.Getenv("SKIP_VULNERABILITY_SCAN") == "true",
+		OrphanCleanupEnabled:  os.Getenv("ORPHAN_CLEANUP_ENABLED") == "true",
+		SkipBucketCheck:       os.Getenv("SKIP_BUCKET_CHECK") == "true",
+
+		DefaultDeletionProtection: os.Getenv("DEFAULT_DELETION_PROTECTION") == "true",
+
+		DefaultCpu:    strings.TrimSpace(os.Getenv("DEFAULT_CPU")),
+		DefaultMemory: strings.TrimSpace(os.Getenv("DEFAULT_MEMORY")),
+	}
+}
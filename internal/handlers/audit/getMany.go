@@ -0,0 +1,105 @@
+package audit
+
+import (
+	"log/slog"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5/pgxpool"
+
+	"github.com/0p5dev/controller/internal/models"
+)
+
+type PaginatedAuditLogResponse struct {
+	Entries    []models.AuditLogEntry `json:"entries"`
+	Count      int                    `json:"count"`
+	Page       int                    `json:"page"`
+	Limit      int                    `json:"limit"`
+	TotalPages int                    `json:"total_pages"`
+}
+
+// @Summary List audit log entries
+// @Description Get a paginated list of create, update, and delete operations, newest first. Admin only.
+// @Tags audit
+// @Produce json
+// @Security BearerAuth
+// @Param page query int false "Page number (default: 1)"
+// @Param limit query int false "Items per page (default: 50, max: 200)"
+// @Success 200 {object} audit.PaginatedAuditLogResponse "Paginated audit log"
+// @Failure 401 {object} map[string]string "Unauthorized"
+// @Failure 403 {object} map[string]string "Admin access required"
+// @Failure 500 {object} map[string]string "Failed to retrieve audit log"
+// @Router /audit [get]
+func GetMany(c *gin.Context) {
+	logger := c.MustGet("Logger").(*slog.Logger)
+	pool := c.MustGet("Pool").(*pgxpool.Pool)
+
+	ctx := c.Request.Context()
+
+	page, err := strconv.Atoi(c.DefaultQuery("page", "1"))
+	if err != nil || page < 1 {
+		page = 1
+	}
+
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", "50"))
+	if err != nil || limit < 1 || limit > 200 {
+		limit = 50
+	}
+
+	offset := (page - 1) * limit
+
+	var totalCount int
+	if err := pool.QueryRow(ctx, "SELECT COUNT(*) FROM audit_log").Scan(&totalCount); err != nil {
+		logger.Error("Error counting audit log entries", "error", err)
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to count audit log entries",
+		})
+		return
+	}
+
+	rows, err := pool.Query(ctx, `
+		SELECT id, actor_email, action, target, source_ip, outcome, created_at
+		FROM audit_log
+		ORDER BY created_at DESC, id DESC
+		LIMIT $1 OFFSET $2
+	`, limit, offset)
+	if err != nil {
+		logger.Error("Error querying audit log", "error", err)
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to retrieve audit log",
+		})
+		return
+	}
+	defer rows.Close()
+
+	entries := []models.AuditLogEntry{}
+	for rows.Next() {
+		var entry models.AuditLogEntry
+		if err := rows.Scan(&entry.Id, &entry.ActorEmail, &entry.Action, &entry.Target, &entry.SourceIp, &entry.Outcome, &entry.CreatedAt); err != nil {
+			logger.Error("Error scanning audit log entry", "error", err)
+			c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{
+				"error": "Failed to scan audit log entry",
+			})
+			return
+		}
+		entries = append(entries, entry)
+	}
+	if err := rows.Err(); err != nil {
+		logger.Error("Error iterating audit log", "error", err)
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to retrieve audit log",
+		})
+		return
+	}
+
+	totalPages := (totalCount + limit - 1) / limit
+
+	c.JSON(http.StatusOK, PaginatedAuditLogResponse{
+		Entries:    entries,
+		Count:      totalCount,
+		Page:       page,
+		Limit:      limit,
+		TotalPages: totalPages,
+	})
+}
@@ -5,12 +5,13 @@ import (
 	"encoding/json"
 	"log/slog"
 	"net/http"
-	"os"
 
 	"github.com/gin-gonic/gin"
 	"github.com/jackc/pgx/v5/pgxpool"
 	"github.com/stripe/stripe-go/v84"
 	"github.com/stripe/stripe-go/v84/webhook"
+
+	"github.com/0p5dev/controller/internal/config"
 )
 
 func Webhook(c *gin.Context) {
@@ -24,7 +25,7 @@ func Webhook(c *gin.Context) {
 		return
 	}
 
-	event, err := webhook.ConstructEvent(payload, c.GetHeader("Stripe-Signature"), os.Getenv("STRIPE_WEBHOOK_SIGNING_SECRET"))
+	event, err := webhook.ConstructEvent(payload, c.GetHeader("Stripe-Signature"), config.Get().StripeWebhookSigningSecret)
 	if err != nil {
 		slog.Error("Failed to verify webhook signature", "error", err)
 		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid webhook signature"})
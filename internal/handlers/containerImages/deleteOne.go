@@ -0,0 +1,98 @@
+package containerImages
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/go-containerregistry/pkg/name"
+	"github.com/google/go-containerregistry/pkg/v1/google"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+	"github.com/jackc/pgx/v5/pgxpool"
+
+	"github.com/0p5dev/controller/internal/sharedUtils"
+)
+
+// @Summary Delete a container image
+// @Description Delete a pushed container image record and its registry tag. Images still referenced by a deployment cannot be deleted.
+// @Tags container-images
+// @Produce json
+// @Security BearerAuth
+// @Param fqin path string true "URL-encoded fully qualified image name"
+// @Success 200 {object} map[string]string "Image deleted"
+// @Failure 401 {object} map[string]string "Unauthorized"
+// @Failure 404 {object} map[string]string "Image not found"
+// @Failure 409 {object} map[string]interface{} "Image is still used by deployments"
+// @Failure 500 {object} map[string]string "Failed to delete image"
+// @Router /container-images/{fqin} [delete]
+func DeleteOne(c *gin.Context) {
+	userClaims := c.MustGet("UserClaims").(*sharedUtils.UserClaims)
+	logger := c.MustGet("Logger").(*slog.Logger)
+	pool := c.MustGet("Pool").(*pgxpool.Pool)
+	ctx := c.Request.Context()
+
+	fqin := c.Param("fqin")
+
+	auditEntry := sharedUtils.NewAuditLogEntry(c, "container_image.delete", fqin)
+	defer func() { sharedUtils.RecordAuditLogEntry(pool, auditEntry) }()
+
+	result, err := pool.Exec(ctx, "DELETE FROM container_images WHERE fqin = $1 AND user_id = $2", fqin, userClaims.UserMetadata.AppUser.Id)
+	if err != nil {
+		if sharedUtils.IsForeignKeyViolation(err) {
+			deploymentNames := referencingDeploymentNames(ctx, pool, userClaims.UserMetadata.AppUser.Id, fqin)
+			message := "container image " + fqin + " is still used by deployments"
+			if len(deploymentNames) == 0 {
+				message = "container image " + fqin + " is still referenced by deleted deployments retained for audit"
+			}
+			c.AbortWithStatusJSON(http.StatusConflict, gin.H{
+				"error":       message,
+				"deployments": deploymentNames,
+			})
+			return
+		}
+		logger.Error("Failed to delete container image record", "fqin", fqin, "error", err)
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to delete container image",
+		})
+		return
+	}
+	if result.RowsAffected() == 0 {
+		c.AbortWithStatusJSON(http.StatusNotFound, gin.H{
+			"error": "container image " + fqin + " not found",
+		})
+		return
+	}
+
+	auditEntry.Outcome = "succeeded"
+
+	// The record is gone, so a registry failure only leaves an untracked tag behind
+	if ref, err := name.ParseReference(fqin); err == nil {
+		if err := remote.Delete(ref, remote.WithAuthFromKeychain(google.Keychain), remote.WithContext(ctx)); err != nil {
+			logger.Warn("Failed to delete image tag from registry", "fqin", fqin, "error", err)
+		}
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Container image " + fqin + " deleted successfully",
+	})
+}
+
+// referencingDeploymentNames lists the caller's deployments that still point at an image
+func referencingDeploymentNames(ctx context.Context, pool *pgxpool.Pool, userId string, fqin string) []string {
+	rows, err := pool.Query(ctx, "SELECT name FROM deployments WHERE container_image = $1 AND user_id = $2 AND deleted_at IS NULL ORDER BY name", fqin, userId)
+	if err != nil {
+		slog.Error("Failed to list deployments referencing container image", "fqin", fqin, "error", err)
+		return []string{}
+	}
+	defer rows.Close()
+
+	names := []string{}
+	for rows.Next() {
+		var deploymentName string
+		if err := rows.Scan(&deploymentName); err == nil {
+			names = append(names, deploymentName)
+		}
+	}
+	return names
+}
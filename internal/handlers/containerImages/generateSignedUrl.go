@@ -4,21 +4,23 @@ import (
 	"context"
 	"fmt"
 	"net/http"
-	"os"
 	"time"
 
 	"cloud.google.com/go/storage"
+	"github.com/0p5dev/controller/internal/config"
 	"github.com/0p5dev/controller/internal/sharedUtils"
 	"github.com/gin-gonic/gin"
 )
 
 type GenerateSignedUrlRequestBody struct {
 	ImageName string `json:"image_name" binding:"required"`
+	// ContentType is the Content-Type the upload will be sent with, which the signed URL requires it to match
+	ContentType string `json:"content_type,omitempty" binding:"omitempty,oneof=application/gzip application/x-gzip"`
 }
 
 func GenerateSignedUrl(c *gin.Context) {
 	userClaims := c.MustGet("UserClaims").(*sharedUtils.UserClaims)
-	bucketName := os.Getenv("CLOUD_STORAGE_BUCKET_NAME")
+	bucketName := config.Get().CloudStorageBucketName
 	ctx := context.Background()
 
 	var reqBody GenerateSignedUrlRequestBody
@@ -34,11 +36,16 @@ func GenerateSignedUrl(c *gin.Context) {
 	}
 	defer client.Close()
 
+	contentType := reqBody.ContentType
+	if contentType == "" {
+		contentType = "application/gzip"
+	}
+
 	opts := &storage.SignedURLOptions{
 		Scheme:      storage.SigningSchemeV4,
 		Method:      "PUT",
 		Expires:     time.Now().Add(15 * time.Minute),
-		ContentType: "application/gzip",
+		ContentType: contentType,
 	}
 
 	objectName := fmt.Sprintf("%s-%s.tgz", reqBody.ImageName, userClaims.UserMetadata.AppUser.Id)
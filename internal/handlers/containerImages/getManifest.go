@@ -0,0 +1,177 @@
+package containerImages
+
+import (
+	"errors"
+	"log/slog"
+	"net/http"
+	"sort"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/go-containerregistry/pkg/name"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/google"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+	"github.com/google/go-containerregistry/pkg/v1/remote/transport"
+	"github.com/jackc/pgx/v5/pgxpool"
+
+	"github.com/0p5dev/controller/internal/sharedUtils"
+)
+
+// ImageManifest describes a pushed image. Multi-arch images only list their platforms; each platform's digest can be
+// inspected on its own for the rest.
+type ImageManifest struct {
+	Fqin         string            `json:"fqin"`
+	Digest       string            `json:"digest"`
+	MediaType    string            `json:"media_type"`
+	Os           string            `json:"os,omitempty"`
+	Architecture string            `json:"architecture,omitempty"`
+	Variant      string            `json:"variant,omitempty"`
+	ExposedPorts []string          `json:"exposed_ports,omitempty"` // e.g. 8080/tcp
+	Entrypoint   []string          `json:"entrypoint,omitempty"`
+	Cmd          []string          `json:"cmd,omitempty"`
+	Labels       map[string]string `json:"labels,omitempty"`
+	SizeBytes    int64             `json:"size_bytes,omitempty"` // config plus compressed layers
+	Platforms    []ImagePlatform   `json:"platforms,omitempty"`
+}
+
+// ImagePlatform is one image of a multi-arch index
+type ImagePlatform struct {
+	Digest       string `json:"digest"`
+	Os           string `json:"os"`
+	Architecture string `json:"architecture"`
+	Variant      string `json:"variant,omitempty"`
+	SizeBytes    int64  `json:"size_bytes"` // size of the platform's manifest
+}
+
+// @Summary Inspect a container image
+// @Description Read a pushed image's manifest and config from its registry: exposed ports, entrypoint and cmd, labels, platform, and total size. Multi-arch images return their platform list instead.
+// @Tags container-images
+// @Produce json
+// @Security BearerAuth
+// @Param fqin path string true "URL-encoded fully qualified image name"
+// @Success 200 {object} ImageManifest "Image manifest"
+// @Failure 401 {object} map[string]string "Unauthorized"
+// @Failure 404 {object} map[string]string "Image not found"
+// @Failure 500 {object} map[string]string "Failed to look up image"
+// @Failure 502 {object} map[string]string "Failed to reach container registry"
+// @Router /container-images/{fqin}/manifest [get]
+func GetManifest(c *gin.Context) {
+	userClaims := c.MustGet("UserClaims").(*sharedUtils.UserClaims)
+	logger := c.MustGet("Logger").(*slog.Logger)
+	pool := c.MustGet("Pool").(*pgxpool.Pool)
+	ctx := c.Request.Context()
+
+	fqin := c.Param("fqin")
+
+	var owned bool
+	err := pool.QueryRow(ctx, "SELECT EXISTS(SELECT 1 FROM container_images WHERE fqin = $1 AND user_id = $2)", fqin, userClaims.UserMetadata.AppUser.Id).Scan(&owned)
+	if err != nil {
+		logger.Error("Failed to look up container image", "fqin", fqin, "error", err)
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to look up container image",
+		})
+		return
+	}
+	ref, err := name.ParseReference(fqin)
+	if !owned || err != nil {
+		c.AbortWithStatusJSON(http.StatusNotFound, gin.H{
+			"error": "container image " + fqin + " not found",
+		})
+		return
+	}
+
+	descriptor, err := remote.Get(ref, remote.WithAuthFromKeychain(google.Keychain), remote.WithContext(ctx))
+	if err != nil {
+		registryErrorResponse(c, logger, fqin, err)
+		return
+	}
+
+	manifest := ImageManifest{
+		Fqin:      fqin,
+		Digest:    descriptor.Digest.String(),
+		MediaType: string(descriptor.MediaType),
+	}
+
+	if descriptor.MediaType.IsIndex() {
+		index, err := descriptor.ImageIndex()
+		if err == nil {
+			var indexManifest *v1.IndexManifest
+			if indexManifest, err = index.IndexManifest(); err == nil {
+				manifest.Platforms = indexPlatforms(indexManifest)
+			}
+		}
+		if err != nil {
+			registryErrorResponse(c, logger, fqin, err)
+			return
+		}
+		c.JSON(http.StatusOK, manifest)
+		return
+	}
+
+	image, err := descriptor.Image()
+	if err != nil {
+		registryErrorResponse(c, logger, fqin, err)
+		return
+	}
+	configFile, err := image.ConfigFile()
+	if err != nil {
+		registryErrorResponse(c, logger, fqin, err)
+		return
+	}
+	imageManifest, err := image.Manifest()
+	if err != nil {
+		registryErrorResponse(c, logger, fqin, err)
+		return
+	}
+
+	manifest.Os = configFile.OS
+	manifest.Architecture = configFile.Architecture
+	manifest.Variant = configFile.Variant
+	manifest.Entrypoint = configFile.Config.Entrypoint
+	manifest.Cmd = configFile.Config.Cmd
+	manifest.Labels = configFile.Config.Labels
+	for port := range configFile.Config.ExposedPorts {
+		manifest.ExposedPorts = append(manifest.ExposedPorts, port)
+	}
+	sort.Strings(manifest.ExposedPorts)
+	manifest.SizeBytes = imageManifest.Config.Size
+	for _, layer := range imageManifest.Layers {
+		manifest.SizeBytes += layer.Size
+	}
+
+	c.JSON(http.StatusOK, manifest)
+}
+
+// indexPlatforms lists the images of a multi-arch index, skipping attestation manifests that have no real platform
+func indexPlatforms(indexManifest *v1.IndexManifest) []ImagePlatform {
+	platforms := []ImagePlatform{}
+	for _, entry := range indexManifest.Manifests {
+		if entry.Platform == nil || entry.Platform.OS == "unknown" {
+			continue
+		}
+		platforms = append(platforms, ImagePlatform{
+			Digest:       entry.Digest.String(),
+			Os:           entry.Platform.OS,
+			Architecture: entry.Platform.Architecture,
+			Variant:      entry.Platform.Variant,
+			SizeBytes:    entry.Size,
+		})
+	}
+	return platforms
+}
+
+// registryErrorResponse reports an image the registry no longer has as not found, and anything else as a registry failure
+func registryErrorResponse(c *gin.Context, logger *slog.Logger, fqin string, err error) {
+	var transportErr *transport.Error
+	if errors.As(err, &transportErr) && transportErr.StatusCode == http.StatusNotFound {
+		c.AbortWithStatusJSON(http.StatusNotFound, gin.H{
+			"error": "container image " + fqin + " not found in registry",
+		})
+		return
+	}
+	logger.Error("Failed to read image manifest", "fqin", fqin, "error", err)
+	c.AbortWithStatusJSON(http.StatusBadGateway, gin.H{
+		"error":   "failed to reach container registry",
+		"message": err.Error(),
+	})
+}
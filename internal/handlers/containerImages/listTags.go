@@ -0,0 +1,116 @@
+package containerImages
+
+import (
+	"log/slog"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/go-containerregistry/pkg/name"
+	"github.com/google/go-containerregistry/pkg/v1/google"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+	"github.com/jackc/pgx/v5/pgxpool"
+
+	"github.com/0p5dev/controller/internal/sharedUtils"
+)
+
+type PaginatedTagsResponse struct {
+	Repository string   `json:"repository"`
+	Tags       []string `json:"tags"`
+	Count      int      `json:"count"`
+	Page       int      `json:"page"`
+	Limit      int      `json:"limit"`
+	TotalPages int      `json:"total_pages"`
+}
+
+// @Summary List the tags of an image repository
+// @Description List the tags in the registry for an image repository the caller has pushed to, sorted by name, to pick one for a deployment
+// @Tags container-images
+// @Produce json
+// @Security BearerAuth
+// @Param repository query string true "Image repository without a tag, e.g. us-docker.pkg.dev/project/repo/app-<user id>"
+// @Param filter query string false "Only tags containing this text"
+// @Param page query int false "Page number (default: 1)"
+// @Param limit query int false "Items per page (default: 50, max: 200)"
+// @Success 200 {object} containerImages.PaginatedTagsResponse "Paginated tags"
+// @Failure 400 {object} map[string]string "Missing or invalid repository"
+// @Failure 401 {object} map[string]string "Unauthorized"
+// @Failure 404 {object} map[string]string "Repository not found"
+// @Failure 500 {object} map[string]string "Failed to look up repository"
+// @Failure 502 {object} map[string]string "Failed to reach container registry"
+// @Router /container-images/tags [get]
+func ListTags(c *gin.Context) {
+	userClaims := c.MustGet("UserClaims").(*sharedUtils.UserClaims)
+	logger := c.MustGet("Logger").(*slog.Logger)
+	pool := c.MustGet("Pool").(*pgxpool.Pool)
+	ctx := c.Request.Context()
+
+	repository := strings.TrimSuffix(c.Query("repository"), "/")
+	repo, err := name.NewRepository(repository)
+	if repository == "" || err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{
+			"error": "repository must be an image repository without a tag or digest",
+		})
+		return
+	}
+
+	page, err := strconv.Atoi(c.DefaultQuery("page", "1"))
+	if err != nil || page < 1 {
+		page = 1
+	}
+
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", "50"))
+	if err != nil || limit < 1 || limit > 200 {
+		limit = 50
+	}
+
+	// Users only see repositories they have pushed an image to, which keeps them out of each other's namespaces
+	var owned bool
+	err = pool.QueryRow(ctx, `
+		SELECT EXISTS(
+			SELECT 1 FROM container_images
+			WHERE user_id = $1 AND (starts_with(fqin, $2 || ':') OR starts_with(fqin, $2 || '@'))
+		)
+	`, userClaims.UserMetadata.AppUser.Id, repository).Scan(&owned)
+	if err != nil {
+		logger.Error("Failed to look up container image repository", "repository", repository, "error", err)
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to look up container image repository",
+		})
+		return
+	}
+	if !owned {
+		c.AbortWithStatusJSON(http.StatusNotFound, gin.H{
+			"error": "repository " + repository + " not found",
+		})
+		return
+	}
+
+	tags, err := remote.List(repo, remote.WithAuthFromKeychain(google.Keychain), remote.WithContext(ctx))
+	if err != nil {
+		registryErrorResponse(c, logger, repository, err)
+		return
+	}
+
+	if filter := c.Query("filter"); filter != "" {
+		tags = slices.DeleteFunc(tags, func(tag string) bool {
+			return !strings.Contains(tag, filter)
+		})
+	}
+	slices.Sort(tags)
+
+	totalCount := len(tags)
+	start := min((page-1)*limit, totalCount)
+	end := min(start+limit, totalCount)
+
+	c.JSON(http.StatusOK, PaginatedTagsResponse{
+		Repository: repository,
+		Tags:       append([]string{}, tags[start:end]...),
+		Count:      totalCount,
+		Page:       page,
+		Limit:      limit,
+		TotalPages: (totalCount + limit - 1) / limit,
+	})
+}
@@ -0,0 +1,93 @@
+package containerImages
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// pushProgressInterval throttles progress events, which the registry client reports for every chunk it uploads
+const pushProgressInterval = time.Second
+
+// PushProgressEvent is one event of a streamed push. Stages run load (reading the tarball from Cloud Storage), tag,
+// and push; the stream ends with a done event carrying the FQIN, or an error event.
+type PushProgressEvent struct {
+	Stage    string `json:"stage"`              // load | tag | push | done | error
+	Complete int64  `json:"complete,omitempty"` // bytes loaded or pushed so far
+	Total    int64  `json:"total,omitempty"`    // bytes to push; not known while loading
+	Fqin     string `json:"fqin,omitempty"`
+	Status   int    `json:"status,omitempty"` // on error, the HTTP status the push would have failed with
+	Error    string `json:"error,omitempty"`
+}
+
+// pushStream reports a push's progress as Server-Sent Events when the client asked for stream=true. Otherwise progress
+// is dropped, and fail and finish send the usual JSON responses.
+type pushStream struct {
+	c         *gin.Context
+	streaming bool
+	started   bool
+	lastSent  time.Time
+}
+
+func newPushStream(c *gin.Context) *pushStream {
+	return &pushStream{c: c, streaming: c.Query("stream") == "true"}
+}
+
+// progress sends an event, skipping it when the previous one went out less than pushProgressInterval ago unless
+// force is set
+func (s *pushStream) progress(event PushProgressEvent, force bool) {
+	if !s.streaming || (!force && time.Since(s.lastSent) < pushProgressInterval) {
+		return
+	}
+	s.send("progress", event)
+}
+
+// fail ends the request with an error, as a JSON response if nothing has been streamed yet and as an error event
+// once the stream's 200 status has been sent
+func (s *pushStream) fail(status int, body gin.H) {
+	if !s.started {
+		s.c.AbortWithStatusJSON(status, body)
+		return
+	}
+	s.send("error", PushProgressEvent{Stage: "error", Status: status, Error: fmt.Sprint(body["error"])})
+}
+
+// finish ends the request with the pushed image's FQIN
+func (s *pushStream) finish(fqin string) {
+	if !s.streaming {
+		s.c.JSON(http.StatusOK, gin.H{
+			"fqin": fqin,
+		})
+		return
+	}
+	s.send("done", PushProgressEvent{Stage: "done", Fqin: fqin})
+}
+
+func (s *pushStream) send(name string, event PushProgressEvent) {
+	if !s.started {
+		s.c.Header("Content-Type", "text/event-stream")
+		s.c.Header("Cache-Control", "no-cache")
+		s.c.Header("Connection", "keep-alive")
+		s.started = true
+	}
+	s.c.SSEvent(name, event)
+	s.c.Writer.Flush()
+	s.lastSent = time.Now()
+}
+
+// loadProgressWriter counts the bytes of the decompressed tarball as they are written, reporting them as load progress
+type loadProgressWriter struct {
+	w       io.Writer
+	stream  *pushStream
+	written int64
+}
+
+func (lw *loadProgressWriter) Write(p []byte) (int, error) {
+	n, err := lw.w.Write(p)
+	lw.written += int64(n)
+	lw.stream.progress(PushProgressEvent{Stage: "load", Complete: lw.written}, false)
+	return n, err
+}
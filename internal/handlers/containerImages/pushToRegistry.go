@@ -2,29 +2,44 @@ package containerImages
 
 import (
 	"compress/gzip"
+	"errors"
 	"fmt"
 	"io"
 	"log/slog"
 	"math/rand"
+	"net"
 	"net/http"
 	"os"
 	"path"
+	"regexp"
 	"strings"
 	"time"
 
 	"cloud.google.com/go/storage"
 	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgxpool"
 	"github.com/oklog/ulid/v2"
 
+	"github.com/0p5dev/controller/internal/config"
 	"github.com/0p5dev/controller/internal/sharedUtils"
 
 	"github.com/google/go-containerregistry/pkg/name"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
 	"github.com/google/go-containerregistry/pkg/v1/google"
 	"github.com/google/go-containerregistry/pkg/v1/remote"
+	"github.com/google/go-containerregistry/pkg/v1/remote/transport"
 	"github.com/google/go-containerregistry/pkg/v1/tarball"
 )
 
+const maxIdempotencyKeyLength = 255
+
+// Artifact Registry image names are lowercase path components separated by /, and tags follow the OCI tag grammar
+var (
+	imageNamePattern = regexp.MustCompile(`^[a-z0-9]+(?:[._-][a-z0-9]+)*(?:/[a-z0-9]+(?:[._-][a-z0-9]+)*)*$`)
+	imageTagPattern  = regexp.MustCompile(`^[A-Za-z0-9_][A-Za-z0-9._-]{0,127}$`)
+)
+
 type PushToRegistryRequestBody struct {
 	ImageName string `json:"image_name" binding:"required"`
 }
@@ -53,22 +68,31 @@ func getImageNameFromTarballPath(tarPath string) string {
 }
 
 // @Summary Push container image to registry
-// @Description Pull a gzipped docker save tarball from Cloud Storage and push it to Google Artifact Registry
+// @Description Pull a gzipped docker save tarball from Cloud Storage and push it to Google Artifact Registry. With stream=true the response is a Server-Sent Events stream of load, tag, and push progress events ending in a done event with the FQIN, or an error event.
 // @Tags container-images
 // @Accept application/json
-// @Produce json
+// @Produce json,text/event-stream
 // @Security BearerAuth
+// @Param stream query bool false "Stream progress as Server-Sent Events instead of waiting for the push to finish"
+// @Param name query string false "Image name to push as, instead of the name derived from the tarball"
+// @Param tag query string false "Image tag to push as (e.g. a git SHA), instead of a random tag"
+// @Param repository query string false "Artifact Registry repository URL to push to; must be one of AR_REPO_URLS (default: AR_REPO_URL)"
+// @Param Idempotency-Key header string false "Repeating a request with the same key returns the original FQIN without pushing again"
 // @Param image body PushToRegistryRequestBody true "Container image payload"
 // @Success 200 {object} map[string]string "Image pushed successfully with FQIN"
 // @Failure 400 {object} map[string]string "Invalid request"
 // @Failure 401 {object} map[string]string "Unauthorized"
+// @Failure 415 {object} map[string]interface{} "Content-Type is not application/json"
 // @Failure 500 {object} map[string]string "Failed to push image"
+// @Failure 502 {object} map[string]string "Registry rejected the push"
+// @Failure 503 {object} map[string]string "Registry unreachable"
 // @Router /container-images [post]
 func PushToRegistry(c *gin.Context) {
 	userClaims := c.MustGet("UserClaims").(*sharedUtils.UserClaims)
+	logger := c.MustGet("Logger").(*slog.Logger)
 	pool := c.MustGet("Pool").(*pgxpool.Pool)
 	ctx := c.Request.Context()
-	bucketName := os.Getenv("CLOUD_STORAGE_BUCKET_NAME")
+	bucketName := config.Get().CloudStorageBucketName
 
 	var reqBody PushToRegistryRequestBody
 	if err := c.ShouldBindJSON(&reqBody); err != nil {
@@ -76,12 +100,70 @@ func PushToRegistry(c *gin.Context) {
 		return
 	}
 
-	// slog.Info("push to registry", "appUser", userClaims.UserMetadata.AppUserMetadata.AppUser)
+	// logger.Info("push to registry", "appUser", userClaims.UserMetadata.AppUserMetadata.AppUser)
+
+	requestedName := c.Query("name")
+	if requestedName != "" && (len(requestedName) > 128 || !imageNamePattern.MatchString(requestedName)) {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{
+			"error": "Invalid name: must be lowercase letters, digits, and separators (. _ - /), at most 128 characters",
+		})
+		return
+	}
+
+	requestedTag := c.Query("tag")
+	if requestedTag != "" && !imageTagPattern.MatchString(requestedTag) {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{
+			"error": "Invalid tag: must start with a letter, digit, or underscore and contain at most 128 letters, digits, or . _ -",
+		})
+		return
+	}
+
+	repoUrl, err := resolveRepositoryUrl(c.Query("repository"))
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+
+	stream := newPushStream(c)
+
+	// A retried request with the same Idempotency-Key gets the original result instead of a second push
+	idempotencyKey := c.GetHeader("Idempotency-Key")
+	if len(idempotencyKey) > maxIdempotencyKeyLength {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{
+			"error": fmt.Sprintf("Idempotency-Key must be %d characters or less", maxIdempotencyKeyLength),
+		})
+		return
+	}
+	if idempotencyKey != "" {
+		var existingFqin string
+		err := pool.QueryRow(ctx, `
+			SELECT fqin FROM idempotency_keys
+			WHERE user_id = $1 AND key = $2 AND created_at > NOW() - make_interval(hours => $3)
+		`, userClaims.UserMetadata.AppUser.Id, idempotencyKey, idempotencyKeyTtlHours()).Scan(&existingFqin)
+		if err == nil {
+			c.Header("Idempotent-Replayed", "true")
+			stream.finish(existingFqin)
+			return
+		}
+		if !errors.Is(err, pgx.ErrNoRows) {
+			logger.Error("Failed to look up idempotency key", "user_id", userClaims.UserMetadata.AppUser.Id, "error", err)
+			c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{
+				"error": "Failed to check idempotency key",
+			})
+			return
+		}
+	}
+
+	// The target is the uploaded image name until the push resolves it to a fqin
+	auditEntry := sharedUtils.NewAuditLogEntry(c, "container_image.push", reqBody.ImageName)
+	defer func() { sharedUtils.RecordAuditLogEntry(pool, auditEntry) }()
 
 	storageClient, err := storage.NewClient(ctx)
 	if err != nil {
-		slog.Error("Failed to create cloud storage client", "error", err)
-		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{
+		logger.Error("Failed to create cloud storage client", "error", err)
+		stream.fail(http.StatusInternalServerError, gin.H{
 			"error": "Failed to initialize cloud storage client",
 		})
 		return
@@ -91,18 +173,20 @@ func PushToRegistry(c *gin.Context) {
 	objectName := fmt.Sprintf("%s-%s.tgz", reqBody.ImageName, userClaims.UserMetadata.AppUser.Id)
 	objectReader, err := storageClient.Bucket(bucketName).Object(objectName).NewReader(ctx)
 	if err != nil {
-		slog.Error("Failed to open cloud storage object", "bucket", bucketName, "object", objectName, "error", err)
-		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{
+		logger.Error("Failed to open cloud storage object", "bucket", bucketName, "object", objectName, "error", err)
+		stream.fail(http.StatusBadRequest, gin.H{
 			"error": "Failed to read image tarball from cloud storage",
 		})
 		return
 	}
 	defer objectReader.Close()
 
+	stream.progress(PushProgressEvent{Stage: "load"}, true)
+
 	gzr, err := gzip.NewReader(objectReader)
 	if err != nil {
-		slog.Error("Gzip reader error", "error", err)
-		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{
+		logger.Error("Gzip reader error", "error", err)
+		stream.fail(http.StatusBadRequest, gin.H{
 			"error": "Failed to create gzip reader (invalid gzip data)",
 		})
 		return
@@ -111,8 +195,8 @@ func PushToRegistry(c *gin.Context) {
 
 	tmpTar, err := os.CreateTemp("", "uploaded-image-*.tar")
 	if err != nil {
-		slog.Error("Failed to create temp tar file", "error", err)
-		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{
+		logger.Error("Failed to create temp tar file", "error", err)
+		stream.fail(http.StatusInternalServerError, gin.H{
 			"error": "Failed to prepare uploaded image for processing",
 		})
 		return
@@ -120,18 +204,20 @@ func PushToRegistry(c *gin.Context) {
 	tmpTarPath := tmpTar.Name()
 	defer os.Remove(tmpTarPath)
 
-	if _, err := io.Copy(tmpTar, gzr); err != nil {
+	loaded := &loadProgressWriter{w: tmpTar, stream: stream}
+	if _, err := io.Copy(loaded, gzr); err != nil {
 		tmpTar.Close()
-		slog.Error("Failed to read uploaded tarball", "error", err)
-		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{
+		logger.Error("Failed to read uploaded tarball", "error", err)
+		stream.fail(http.StatusBadRequest, gin.H{
 			"error": "Failed to read uploaded image tarball",
 		})
 		return
 	}
+	stream.progress(PushProgressEvent{Stage: "load", Complete: loaded.written}, true)
 
 	if err := tmpTar.Close(); err != nil {
-		slog.Error("Failed to close temp tar file", "error", err)
-		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{
+		logger.Error("Failed to close temp tar file", "error", err)
+		stream.fail(http.StatusInternalServerError, gin.H{
 			"error": "Failed to prepare image for upload",
 		})
 		return
@@ -139,65 +225,156 @@ func PushToRegistry(c *gin.Context) {
 
 	img, err := tarball.ImageFromPath(tmpTarPath, nil)
 	if err != nil {
-		slog.Error("Failed to parse image from tarball", "error", err)
-		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{
+		logger.Error("Failed to parse image from tarball", "error", err)
+		stream.fail(http.StatusBadRequest, gin.H{
 			"error": "Invalid image tarball. Ensure it is a valid docker save archive",
 		})
 		return
 	}
 
-	originalImageName := getImageNameFromTarballPath(tmpTarPath)
-	finalImageName := fmt.Sprintf("%s-%s", originalImageName, userClaims.UserMetadata.AppUser.Id)
+	originalImageName := requestedName
+	if originalImageName == "" {
+		originalImageName = getImageNameFromTarballPath(tmpTarPath)
+	}
+	finalImageName := userImagePath(originalImageName, userClaims.UserMetadata.AppUser.Id)
 
 	// Tag image for target registry
-	entropy := rand.New(rand.NewSource(time.Now().UnixNano()))
-	ms := ulid.Timestamp(time.Now())
-	id, err := ulid.New(ms, entropy)
-	if err != nil {
-		slog.Error("Failed to generate ULID for image tag", "error", err)
-		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{
-			"error": "Failed to generate unique image tag",
-		})
-		return
+	imageTag := requestedTag
+	if imageTag == "" {
+		entropy := rand.New(rand.NewSource(time.Now().UnixNano()))
+		ms := ulid.Timestamp(time.Now())
+		id, err := ulid.New(ms, entropy)
+		if err != nil {
+			logger.Error("Failed to generate ULID for image tag", "error", err)
+			stream.fail(http.StatusInternalServerError, gin.H{
+				"error": "Failed to generate unique image tag",
+			})
+			return
+		}
+		imageTag = strings.ToLower(id.String())
 	}
-	safeId := strings.ToLower(id.String())
 
-	arRepoUrl := os.Getenv("AR_REPO_URL")
-	targetTag := fmt.Sprintf("%s/%s:%s", arRepoUrl, finalImageName, safeId)
+	targetTag := fmt.Sprintf("%s/%s:%s", repoUrl, finalImageName, imageTag)
+	auditEntry.Target = targetTag
+	stream.progress(PushProgressEvent{Stage: "tag", Fqin: targetTag}, true)
 
 	imageRef, err := name.ParseReference(targetTag)
 	if err != nil {
-		slog.Error("Failed to parse source reference", "user_id", userClaims.UserMetadata.AppUser.Id, "user_email", userClaims.UserMetadata.AppUser.Email, "error", err)
-		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{
+		logger.Error("Failed to parse source reference", "user_id", userClaims.UserMetadata.AppUser.Id, "user_email", userClaims.UserMetadata.AppUser.Email, "error", err)
+		stream.fail(http.StatusInternalServerError, gin.H{
 			"error": fmt.Sprintf("Failed to parse source reference: %v", err),
 		})
 		return
 	}
 
-	// Push image to Artifact Registry using ADC for authentication
-	err = remote.Write(imageRef, img, remote.WithAuthFromKeychain(google.Keychain), remote.WithContext(ctx))
+	// Push image to Artifact Registry using ADC for authentication. The tarball is streamed straight to the
+	// registry, so no Docker daemon is involved.
+	pushOptions := []remote.Option{remote.WithAuthFromKeychain(google.Keychain), remote.WithContext(ctx)}
+	pushDone := make(chan struct{})
+	if stream.streaming {
+		// remote.Write closes updates when it returns, which ends the reporting goroutine
+		updates := make(chan v1.Update, 16)
+		pushOptions = append(pushOptions, remote.WithProgress(updates))
+		go func() {
+			defer close(pushDone)
+			for update := range updates {
+				stream.progress(PushProgressEvent{Stage: "push", Complete: update.Complete, Total: update.Total}, false)
+			}
+		}()
+	} else {
+		close(pushDone)
+	}
+	err = remote.Write(imageRef, img, pushOptions...)
+	<-pushDone
 	if err != nil {
-		slog.Error("Image push failed", "error", err)
-		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{
-			"error": fmt.Sprintf("Image push failed: %v", err),
-		})
+		logger.Error("Image push failed", "fqin", targetTag, "error", err)
+		stream.fail(pushErrorResponse(err))
 		return
 	}
 
-	// Record pushed image in database
+	// Record pushed image in database. A chosen tag may be pushed again, which moves the tag to the new image.
 	_, err = pool.Exec(ctx, `
 			INSERT INTO container_images (fqin, user_id)
 			VALUES ($1, $2)
+			ON CONFLICT (fqin) DO UPDATE SET updated_at = NOW()
 		`, targetTag, userClaims.UserMetadata.AppUser.Id)
 	if err != nil {
-		slog.Error("DB insert error", "user_id", userClaims.UserMetadata.AppUser.Id, "user_email", userClaims.UserMetadata.AppUser.Email, "error", err)
-		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{
+		logger.Error("DB insert error", "user_id", userClaims.UserMetadata.AppUser.Id, "user_email", userClaims.UserMetadata.AppUser.Email, "error", err)
+		stream.fail(http.StatusInternalServerError, gin.H{
 			"error": fmt.Sprintf("Failed to record image in database: %v", err),
 		})
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{
-		"fqin": targetTag,
-	})
+	if idempotencyKey != "" {
+		// Expired keys are overwritten so the same key can be reused once its window has passed
+		_, err = pool.Exec(ctx, `
+			INSERT INTO idempotency_keys (key, user_id, fqin)
+			VALUES ($1, $2, $3)
+			ON CONFLICT (user_id, key) DO UPDATE SET fqin = EXCLUDED.fqin, created_at = NOW()
+		`, idempotencyKey, userClaims.UserMetadata.AppUser.Id, targetTag)
+		if err != nil {
+			// The image is already pushed and recorded, so only a later retry loses its idempotency
+			logger.Error("Failed to record idempotency key", "user_id", userClaims.UserMetadata.AppUser.Id, "fqin", targetTag, "error", err)
+		}
+	}
+
+	auditEntry.Outcome = "succeeded"
+	stream.finish(targetTag)
+}
+
+// pushErrorResponse tells a registry that can't be reached apart from one that rejected the push partway through
+func pushErrorResponse(err error) (int, gin.H) {
+	var transportErr *transport.Error
+	if errors.As(err, &transportErr) {
+		if transportErr.StatusCode == http.StatusUnauthorized || transportErr.StatusCode == http.StatusForbidden {
+			return http.StatusBadGateway, gin.H{
+				"error": "Image push failed: the controller is not authorized to push to this repository",
+			}
+		}
+		return http.StatusBadGateway, gin.H{
+			"error": fmt.Sprintf("Image push failed: the registry rejected the upload: %v", err),
+		}
+	}
+
+	var netErr net.Error
+	if errors.As(err, &netErr) {
+		return http.StatusServiceUnavailable, gin.H{
+			"error": fmt.Sprintf("Image push failed: container registry unreachable: %v", err),
+		}
+	}
+
+	return http.StatusInternalServerError, gin.H{
+		"error": fmt.Sprintf("Image push failed: %v", err),
+	}
+}
+
+// resolveRepositoryUrl picks the Artifact Registry repository to push to. A requested repository must be AR_REPO_URL
+// or listed in AR_REPO_URLS, so users can't push into arbitrary registries with the controller's credentials.
+func resolveRepositoryUrl(requested string) (string, error) {
+	defaultRepoUrl := strings.TrimSuffix(config.Get().ArRepoUrl, "/")
+	requested = strings.TrimSuffix(requested, "/")
+	if requested == "" || requested == defaultRepoUrl {
+		return defaultRepoUrl, nil
+	}
+	for _, allowed := range sharedUtils.GetEnvList("AR_REPO_URLS") {
+		if requested == strings.TrimSuffix(allowed, "/") {
+			return requested, nil
+		}
+	}
+	return "", fmt.Errorf("Invalid repository: %s is not an allowed Artifact Registry repository", requested)
+}
+
+// userImagePath keeps each user's images in their own namespace within a repository, even with a chosen name.
+// With AR_PER_USER_PATH enabled the user ID is a path segment (<user>/<name>), otherwise a name suffix (<name>-<user>).
+func userImagePath(imageName string, userId string) string {
+	if config.Get().ArPerUserPath {
+		return userId + "/" + imageName
+	}
+	return fmt.Sprintf("%s-%s", imageName, userId)
+}
+
+// idempotencyKeyTtlHours is how long a push idempotency key is honored, configurable via IDEMPOTENCY_KEY_TTL_HOURS
+func idempotencyKeyTtlHours() int {
+	return sharedUtils.GetEnvInt("IDEMPOTENCY_KEY_TTL_HOURS", 24)
 }
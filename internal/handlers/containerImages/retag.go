@@ -0,0 +1,192 @@
+package containerImages
+
+import (
+	"errors"
+	"log/slog"
+	"math/rand"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/go-containerregistry/pkg/name"
+	"github.com/google/go-containerregistry/pkg/v1/google"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/oklog/ulid/v2"
+
+	"github.com/0p5dev/controller/internal/sharedUtils"
+)
+
+type RetagRequestBody struct {
+	// Tag is the new tag (default: a random one, which also makes the new reference hard to guess)
+	Tag string `json:"tag,omitempty"`
+	// RemoveOld deletes the old tag once the new one exists. It fails while deployments still use the old reference.
+	RemoveOld bool `json:"remove_old,omitempty"`
+}
+
+// @Summary Retag a container image
+// @Description Point a new tag at the same digest as an existing image without uploading it again, and optionally remove the old tag, e.g. to invalidate a leaked reference
+// @Tags container-images
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param fqin path string true "URL-encoded fully qualified image name"
+// @Param request body RetagRequestBody false "New tag"
+// @Success 200 {object} map[string]interface{} "New FQIN"
+// @Failure 400 {object} map[string]string "Invalid tag"
+// @Failure 401 {object} map[string]string "Unauthorized"
+// @Failure 404 {object} map[string]string "Image not found"
+// @Failure 409 {object} map[string]interface{} "Old tag is still used by deployments"
+// @Failure 500 {object} map[string]string "Failed to record image"
+// @Failure 502 {object} map[string]string "Registry rejected the tag"
+// @Router /container-images/{fqin}/retag [post]
+func Retag(c *gin.Context) {
+	userClaims := c.MustGet("UserClaims").(*sharedUtils.UserClaims)
+	logger := c.MustGet("Logger").(*slog.Logger)
+	pool := c.MustGet("Pool").(*pgxpool.Pool)
+	ctx := c.Request.Context()
+
+	fqin := c.Param("fqin")
+	userId := userClaims.UserMetadata.AppUser.Id
+
+	var reqBody RetagRequestBody
+	if c.Request.ContentLength != 0 {
+		if err := c.ShouldBindJSON(&reqBody); err != nil {
+			c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{
+				"error": "Invalid request body: " + err.Error(),
+			})
+			return
+		}
+	}
+	if reqBody.Tag != "" && !imageTagPattern.MatchString(reqBody.Tag) {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{
+			"error": "Invalid tag: must be at most 128 characters of letters, digits, '_', '.', or '-', not starting with '.' or '-'",
+		})
+		return
+	}
+
+	auditEntry := sharedUtils.NewAuditLogEntry(c, "container_image.retag", fqin)
+	defer func() { sharedUtils.RecordAuditLogEntry(pool, auditEntry) }()
+
+	var owned bool
+	err := pool.QueryRow(ctx, "SELECT EXISTS(SELECT 1 FROM container_images WHERE fqin = $1 AND user_id = $2)", fqin, userId).Scan(&owned)
+	if err != nil {
+		logger.Error("Failed to look up container image", "fqin", fqin, "error", err)
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to look up container image",
+		})
+		return
+	}
+	oldRef, err := name.ParseReference(fqin)
+	if !owned || err != nil {
+		c.AbortWithStatusJSON(http.StatusNotFound, gin.H{
+			"error": "container image " + fqin + " not found",
+		})
+		return
+	}
+
+	newTag := reqBody.Tag
+	if newTag == "" {
+		id, err := ulid.New(ulid.Timestamp(time.Now()), rand.New(rand.NewSource(time.Now().UnixNano())))
+		if err != nil {
+			logger.Error("Failed to generate ULID for image tag", "error", err)
+			c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{
+				"error": "Failed to generate unique image tag",
+			})
+			return
+		}
+		newTag = strings.ToLower(id.String())
+	}
+	newRef := oldRef.Context().Tag(newTag)
+	newFqin := newRef.Name()
+	if newFqin == fqin {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{
+			"error": "Invalid tag: the image already has tag " + newTag,
+		})
+		return
+	}
+	auditEntry.Target = fqin + " -> " + newFqin
+
+	// The records change in a transaction that is only committed once the registry has the new tag, so a
+	// deployment still using the old tag stops the retag before the registry is touched
+	tx, err := pool.BeginTx(ctx, pgx.TxOptions{})
+	if err != nil {
+		logger.Error("Failed to begin retag transaction", "fqin", fqin, "error", err)
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to record retagged image",
+		})
+		return
+	}
+	defer func() {
+		rollbackErr := tx.Rollback(ctx)
+		if rollbackErr != nil && !errors.Is(rollbackErr, pgx.ErrTxClosed) {
+			logger.Error("Failed to rollback retag transaction", "fqin", fqin, "error", rollbackErr.Error())
+		}
+	}()
+
+	// Like a push, a tag that already exists moves to this image
+	_, err = tx.Exec(ctx, `
+		INSERT INTO container_images (fqin, user_id)
+		VALUES ($1, $2)
+		ON CONFLICT (fqin) DO UPDATE SET updated_at = NOW()
+	`, newFqin, userId)
+	if err != nil {
+		logger.Error("Failed to record retagged image", "fqin", newFqin, "error", err)
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to record retagged image",
+		})
+		return
+	}
+	if reqBody.RemoveOld {
+		if _, err := tx.Exec(ctx, "DELETE FROM container_images WHERE fqin = $1 AND user_id = $2", fqin, userId); err != nil {
+			if sharedUtils.IsForeignKeyViolation(err) {
+				c.AbortWithStatusJSON(http.StatusConflict, gin.H{
+					"error":       "container image " + fqin + " is still used by deployments, so its tag can't be removed",
+					"deployments": referencingDeploymentNames(ctx, pool, userId, fqin),
+				})
+				return
+			}
+			logger.Error("Failed to delete container image record", "fqin", fqin, "error", err)
+			c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{
+				"error": "Failed to record retagged image",
+			})
+			return
+		}
+	}
+
+	descriptor, err := remote.Get(oldRef, remote.WithAuthFromKeychain(google.Keychain), remote.WithContext(ctx))
+	if err != nil {
+		registryErrorResponse(c, logger, fqin, err)
+		return
+	}
+	if err := remote.Tag(newRef, descriptor, remote.WithAuthFromKeychain(google.Keychain), remote.WithContext(ctx)); err != nil {
+		logger.Error("Image retag failed", "fqin", fqin, "new_fqin", newFqin, "error", err)
+		c.AbortWithStatusJSON(pushErrorResponse(err))
+		return
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		logger.Error("Failed to commit retag transaction", "fqin", fqin, "new_fqin", newFqin, "error", err)
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to record retagged image",
+		})
+		return
+	}
+
+	auditEntry.Outcome = "succeeded"
+
+	// The record is gone, so a registry failure only leaves an untracked tag behind
+	if reqBody.RemoveOld {
+		if err := remote.Delete(oldRef, remote.WithAuthFromKeychain(google.Keychain), remote.WithContext(ctx)); err != nil {
+			logger.Warn("Failed to delete old image tag from registry", "fqin", fqin, "error", err)
+		}
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"fqin":        newFqin,
+		"old_fqin":    fqin,
+		"old_removed": reqBody.RemoveOld,
+	})
+}
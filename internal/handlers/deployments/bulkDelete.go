@@ -0,0 +1,138 @@
+package deployments
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"slices"
+	"sync"
+
+	"github.com/0p5dev/controller/internal/sharedUtils"
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+const maxBulkDeleteNames = 50
+
+type BulkDeleteRequestBody struct {
+	Names []string `json:"names" binding:"required"`
+}
+
+type BulkDeleteResult struct {
+	Name    string `json:"name"`
+	Success bool   `json:"success"`
+	Message string `json:"message,omitempty"`
+	Error   string `json:"error,omitempty"`
+}
+
+// @Summary Delete multiple deployments
+// @Description Delete several deployments in one request. Each deployment is deleted independently, so a failure for one name does not abort the rest.
+// @Tags deployments
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param request body BulkDeleteRequestBody true "Names of the deployments to delete"
+// @Param environment query string false "Environment of the deployments (default: default)"
+// @Success 200 {array} BulkDeleteResult "Per-deployment delete results"
+// @Failure 400 {object} map[string]string "Invalid request body"
+// @Failure 401 {object} map[string]string "Unauthorized"
+// @Failure 500 {object} map[string]string "Failed to create Cloud Run client"
+// @Router /deployments/bulk-delete [post]
+func BulkDelete(c *gin.Context) {
+	userClaims := c.MustGet("UserClaims").(*sharedUtils.UserClaims)
+	logger := c.MustGet("Logger").(*slog.Logger)
+	pool := c.MustGet("Pool").(*pgxpool.Pool)
+
+	var reqBody BulkDeleteRequestBody
+	if err := c.ShouldBindJSON(&reqBody); err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{
+			"error": "invalid request body",
+		})
+		return
+	}
+
+	environment, ok := environmentParam(c)
+	if !ok {
+		return
+	}
+
+	// Drop blanks and duplicates so each deployment is only destroyed once
+	var names []string
+	for _, name := range reqBody.Names {
+		if name != "" && !slices.Contains(names, name) {
+			names = append(names, name)
+		}
+	}
+
+	if len(names) == 0 {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{
+			"error": "at least one deployment name is required",
+		})
+		return
+	}
+	if len(names) > maxBulkDeleteNames {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{
+			"error": fmt.Sprintf("at most %d deployments can be deleted per request", maxBulkDeleteNames),
+		})
+		return
+	}
+
+	// Each name gets its own audit log entry; the actor and source IP are captured before the workers start
+	auditEntry := sharedUtils.NewAuditLogEntry(c, "deployment.delete", "")
+
+	ctx := context.Background()
+
+	servicesClient, err := newCloudRunServices(ctx)
+	if err != nil {
+		abortWithCloudRunError(c, &CloudRunError{Operation: "NewServicesClient", Err: err})
+		return
+	}
+	defer servicesClient.Close()
+
+	concurrency := sharedUtils.GetEnvInt("BULK_DELETE_CONCURRENCY", 4)
+	if concurrency < 1 {
+		concurrency = 1
+	}
+
+	results := make([]BulkDeleteResult, len(names))
+	semaphore := make(chan struct{}, concurrency)
+	var wg sync.WaitGroup
+
+	for i, name := range names {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			semaphore <- struct{}{}
+			defer func() { <-semaphore }()
+
+			result := BulkDeleteResult{Name: name}
+			serviceAlreadyGone, err := destroyDeployment(ctx, logger, pool, servicesClient, userClaims.UserMetadata.AppUser.Id, name, environment)
+			switch {
+			case errors.Is(err, errDeploymentNotFound):
+				result.Error = "deployment not found"
+			case err != nil:
+				result.Error = err.Error()
+			case serviceAlreadyGone:
+				result.Success = true
+				result.Message = "deleted; Cloud Run resources were already gone"
+			default:
+				result.Success = true
+				result.Message = "deleted"
+			}
+			results[i] = result
+
+			nameAuditEntry := auditEntry
+			nameAuditEntry.Target = name
+			if result.Success {
+				nameAuditEntry.Outcome = "succeeded"
+			}
+			sharedUtils.RecordAuditLogEntry(pool, nameAuditEntry)
+		}()
+	}
+
+	wg.Wait()
+
+	c.JSON(http.StatusOK, results)
+}
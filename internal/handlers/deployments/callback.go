@@ -0,0 +1,153 @@
+package deployments
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/http"
+	"net/url"
+	"syscall"
+	"time"
+)
+
+const (
+	callbackMaxAttempts    = 3
+	callbackAttemptTimeout = 10 * time.Second
+)
+
+type DeploymentCallbackPayload struct {
+	JobId      string `json:"job_id"`
+	Name       string `json:"name"`
+	Status     string `json:"status"` // succeeded | failed
+	Action     string `json:"action"` // created | updated
+	ServiceUrl string `json:"service_url,omitempty"`
+	// RegionUrls maps each region that was deployed to its URL, for multi-region deployments
+	RegionUrls map[string]string `json:"region_urls,omitempty"`
+	Health     string            `json:"health,omitempty"` // ok | unreachable, only when a health check was requested
+	// Revision is the revision the job created. Rollout is ready or in_progress for updates that asked to wait for it.
+	Rollout  string `json:"rollout,omitempty"`
+	Revision string `json:"revision,omitempty"`
+	Error    string `json:"error,omitempty"`
+}
+
+// callbackClient refuses to connect to non-public addresses at dial time, so a hostname that
+// re-resolves to an internal address after validation still can't be reached
+var callbackClient = &http.Client{
+	Timeout: callbackAttemptTimeout,
+	Transport: &http.Transport{
+		DialContext: publicOnlyDialer("callback").DialContext,
+	},
+	CheckRedirect: func(req *http.Request, via []*http.Request) error {
+		return http.ErrUseLastResponse
+	},
+}
+
+// publicOnlyDialer refuses to connect to any address that isn't public, whatever the hostname it was resolved from
+func publicOnlyDialer(purpose string) *net.Dialer {
+	return &net.Dialer{
+		Timeout: 5 * time.Second,
+		Control: func(network, address string, _ syscall.RawConn) error {
+			host, _, err := net.SplitHostPort(address)
+			if err != nil {
+				return err
+			}
+			ip := net.ParseIP(host)
+			if ip == nil || !isPublicIP(ip) {
+				return fmt.Errorf("%s address %s is not a public address", purpose, host)
+			}
+			return nil
+		},
+	}
+}
+
+// validateCallbackUrl ensures a callback URL uses https and does not point at a private or internal address
+func validateCallbackUrl(ctx context.Context, rawUrl string) error {
+	parsed, err := url.Parse(rawUrl)
+	if err != nil {
+		return errors.New("callback_url is not a valid URL")
+	}
+	if parsed.Scheme != "https" {
+		return errors.New("callback_url must use https")
+	}
+
+	host := parsed.Hostname()
+	if host == "" {
+		return errors.New("callback_url must include a host")
+	}
+
+	ips, err := net.DefaultResolver.LookupIP(ctx, "ip", host)
+	if err != nil || len(ips) == 0 {
+		return errors.New("callback_url host could not be resolved")
+	}
+	for _, ip := range ips {
+		if !isPublicIP(ip) {
+			return errors.New("callback_url must not point to a private or internal address")
+		}
+	}
+
+	return nil
+}
+
+func isPublicIP(ip net.IP) bool {
+	return !(ip.IsPrivate() ||
+		ip.IsLoopback() ||
+		ip.IsLinkLocalUnicast() ||
+		ip.IsLinkLocalMulticast() ||
+		ip.IsInterfaceLocalMulticast() ||
+		ip.IsMulticast() ||
+		ip.IsUnspecified())
+}
+
+// sendDeploymentCallback POSTs the outcome of a provisioning job to the client's callback URL, retrying with backoff.
+// It is best-effort: failures are logged and never affect the provisioning job.
+func sendDeploymentCallback(callbackUrl string, payload DeploymentCallbackPayload) {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		slog.Error("Failed to marshal deployment callback payload", "job_id", payload.JobId, "error", err.Error())
+		return
+	}
+
+	backoff := time.Second
+	for attempt := 1; attempt <= callbackMaxAttempts; attempt++ {
+		err = postDeploymentCallback(callbackUrl, body)
+		if err == nil {
+			slog.Info("Delivered deployment callback", "job_id", payload.JobId, "attempt", attempt)
+			return
+		}
+
+		slog.Warn("Deployment callback attempt failed", "job_id", payload.JobId, "attempt", attempt, "error", err.Error())
+		if attempt < callbackMaxAttempts {
+			time.Sleep(backoff)
+			backoff *= 2
+		}
+	}
+
+	slog.Error("Giving up on deployment callback", "job_id", payload.JobId, "attempts", callbackMaxAttempts)
+}
+
+func postDeploymentCallback(callbackUrl string, body []byte) error {
+	ctx, cancel := context.WithTimeout(context.Background(), callbackAttemptTimeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, callbackUrl, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := callbackClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("callback returned status %d", resp.StatusCode)
+	}
+
+	return nil
+}
@@ -0,0 +1,70 @@
+package deployments
+
+import (
+	"log/slog"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5/pgxpool"
+
+	"github.com/0p5dev/controller/internal/sharedUtils"
+)
+
+// @Summary Cancel a deployment's in-progress job
+// @Description Stop the create or update job running for a deployment. The job is marked failed as canceled, which releases the deployment for other operations; Cloud Run changes already made are kept or cleaned up as for any other failure.
+// @Tags deployments
+// @Produce json
+// @Security BearerAuth
+// @Param name path string true "Deployment name"
+// @Param environment query string false "Deployment environment (default: default)"
+// @Success 202 {object} map[string]string "Cancellation requested"
+// @Failure 401 {object} map[string]string "Unauthorized"
+// @Failure 409 {object} map[string]string "No job in progress, or it is running on another controller instance"
+// @Failure 500 {object} map[string]string "Failed to check for pending provisioning jobs"
+// @Router /deployments/{name}/cancel [post]
+func CancelOneByName(c *gin.Context) {
+	userClaims := c.MustGet("UserClaims").(*sharedUtils.UserClaims)
+	pool := c.MustGet("Pool").(*pgxpool.Pool)
+	logger := c.MustGet("Logger").(*slog.Logger)
+	reqCtx := c.Request.Context()
+
+	deploymentName := c.Param("name")
+	environment, ok := environmentParam(c)
+	if !ok {
+		return
+	}
+
+	// A deployment that is still being created has no record yet, so its job is found by the service id alone
+	deploymentId := deploymentServiceId(deploymentName, environment, userClaims.UserMetadata.AppUser.Id)
+
+	auditEntry := sharedUtils.NewAuditLogEntry(c, "deployment.cancel", deploymentName)
+	defer func() { sharedUtils.RecordAuditLogEntry(pool, auditEntry) }()
+
+	jobId, err := pendingProvisioningJobId(reqCtx, pool, deploymentId)
+	if err != nil {
+		logger.Error("Failed to check for pending provisioning jobs", "deployment_id", deploymentId, "error", err)
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{
+			"error": "failed to check for pending provisioning jobs",
+		})
+		return
+	}
+	if jobId == "" {
+		c.AbortWithStatusJSON(http.StatusConflict, gin.H{
+			"error": "deployment " + deploymentName + " has no job in progress",
+		})
+		return
+	}
+
+	// Jobs run on the instance that accepted them, which is the only one that can stop them
+	if !cancelOperation(jobId) {
+		c.AbortWithStatusJSON(http.StatusConflict, gin.H{
+			"error": "the job for deployment " + deploymentName + " is running on another controller instance, try again",
+		})
+		return
+	}
+
+	auditEntry.Outcome = "succeeded"
+	c.JSON(http.StatusAccepted, gin.H{
+		"message": "Canceling the job in progress for deployment " + deploymentName,
+	})
+}
@@ -0,0 +1,81 @@
+package deployments
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"path"
+
+	"github.com/gin-gonic/gin"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// CloudRunError is a failed Cloud Run call, with the operation that failed and the service it was for, so every
+// handler reports and logs Cloud Run failures the same way
+type CloudRunError struct {
+	Operation string // e.g. CreateService, WaitForUpdate, DeleteService, NewServicesClient
+	Service   string // full service name; empty when the call wasn't about one service
+	Err       error
+}
+
+func (e *CloudRunError) Error() string {
+	if e.Service == "" {
+		return fmt.Sprintf("Cloud Run %s failed: %v", e.Operation, e.Err)
+	}
+	return fmt.Sprintf("Cloud Run %s failed for %s: %v", e.Operation, path.Base(e.Service), e.Err)
+}
+
+func (e *CloudRunError) Unwrap() error {
+	return e.Err
+}
+
+// HTTPStatus maps the underlying gRPC status to the response status. Failures on our side of the call, like a
+// missing client, are 500s, and failures of Cloud Run itself are 502 or 503.
+func (e *CloudRunError) HTTPStatus() int {
+	grpcStatus, ok := status.FromError(e.Err)
+	if !ok {
+		return http.StatusInternalServerError
+	}
+	switch grpcStatus.Code() {
+	case codes.NotFound:
+		return http.StatusNotFound
+	case codes.AlreadyExists, codes.Aborted:
+		return http.StatusConflict
+	case codes.InvalidArgument, codes.FailedPrecondition, codes.OutOfRange:
+		return http.StatusBadRequest
+	case codes.ResourceExhausted:
+		return http.StatusTooManyRequests
+	case codes.Unavailable, codes.DeadlineExceeded:
+		return http.StatusServiceUnavailable
+	default:
+		return http.StatusBadGateway
+	}
+}
+
+// newCloudRunError wraps err unless it already is a CloudRunError
+func newCloudRunError(operation string, service string, err error) error {
+	var cloudRunErr *CloudRunError
+	if errors.As(err, &cloudRunErr) {
+		return err
+	}
+	return &CloudRunError{Operation: operation, Service: service, Err: err}
+}
+
+// abortWithCloudRunError logs a Cloud Run failure with its operation as a field and responds with its status
+func abortWithCloudRunError(c *gin.Context, cloudRunErr *CloudRunError) {
+	logger := c.MustGet("Logger").(*slog.Logger)
+	logger.Error("Cloud Run call failed", "operation", cloudRunErr.Operation, "service", cloudRunErr.Service, "error", cloudRunErr.Err)
+
+	statusCode := cloudRunErr.HTTPStatus()
+	errMsg := fmt.Sprintf("Cloud Run %s failed", cloudRunErr.Operation)
+	if statusCode == http.StatusNotFound && cloudRunErr.Service != "" {
+		errMsg = "Cloud Run service not found"
+	}
+	c.AbortWithStatusJSON(statusCode, gin.H{
+		"error":     errMsg,
+		"operation": cloudRunErr.Operation,
+		"message":   cloudRunErr.Error(),
+	})
+}
@@ -0,0 +1,100 @@
+package deployments
+
+import (
+	"context"
+
+	iampb "cloud.google.com/go/iam/apiv1/iampb"
+	run "cloud.google.com/go/run/apiv2"
+	runpb "cloud.google.com/go/run/apiv2/runpb"
+)
+
+// cloudRunServices is the part of the Cloud Run services API the deployment handlers use. Calls that start a
+// long-running operation also wait for it, so callers and test fakes only deal in *runpb.Service values.
+type cloudRunServices interface {
+	// CreateService creates a service and waits until it's provisioned
+	CreateService(ctx context.Context, req *runpb.CreateServiceRequest) (*runpb.Service, error)
+	// UpdateService updates a service and waits until the update is rolled out
+	UpdateService(ctx context.Context, req *runpb.UpdateServiceRequest) (*runpb.Service, error)
+	// DeleteService deletes a service and waits until it's gone
+	DeleteService(ctx context.Context, name string) error
+	GetService(ctx context.Context, name string) (*runpb.Service, error)
+	GetIamPolicy(ctx context.Context, resource string) (*iampb.Policy, error)
+	SetIamPolicy(ctx context.Context, resource string, policy *iampb.Policy) error
+	Close() error
+}
+
+// newCloudRunServices connects to the Cloud Run services API; tests swap it for a fake
+var newCloudRunServices = func(ctx context.Context) (cloudRunServices, error) {
+	client, err := run.NewServicesClient(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return &gcpServicesClient{client: client}, nil
+}
+
+// gcpServicesClient is cloudRunServices backed by run.ServicesClient. Starting an operation is retried on transient
+// errors, and a failure is reported as a CloudRunError naming the phase that failed, e.g. UpdateService or
+// WaitForUpdate.
+type gcpServicesClient struct {
+	client *run.ServicesClient
+}
+
+func (s *gcpServicesClient) CreateService(ctx context.Context, req *runpb.CreateServiceRequest) (*runpb.Service, error) {
+	serviceFullName := req.Parent + "/services/" + req.ServiceId
+	createOp, err := withTransientRetry(ctx, "CreateService", func() (*run.CreateServiceOperation, error) {
+		return s.client.CreateService(ctx, req)
+	})
+	if err != nil {
+		return nil, newCloudRunError("CreateService", serviceFullName, err)
+	}
+	service, err := createOp.Wait(ctx)
+	if err != nil {
+		return nil, newCloudRunError("WaitForService", serviceFullName, err)
+	}
+	return service, nil
+}
+
+func (s *gcpServicesClient) UpdateService(ctx context.Context, req *runpb.UpdateServiceRequest) (*runpb.Service, error) {
+	serviceFullName := req.GetService().GetName()
+	updateOp, err := withTransientRetry(ctx, "UpdateService", func() (*run.UpdateServiceOperation, error) {
+		return s.client.UpdateService(ctx, req)
+	})
+	if err != nil {
+		return nil, newCloudRunError("UpdateService", serviceFullName, err)
+	}
+	service, err := updateOp.Wait(ctx)
+	if err != nil {
+		return nil, newCloudRunError("WaitForUpdate", serviceFullName, err)
+	}
+	return service, nil
+}
+
+func (s *gcpServicesClient) DeleteService(ctx context.Context, name string) error {
+	deleteOp, err := withTransientRetry(ctx, "DeleteService", func() (*run.DeleteServiceOperation, error) {
+		return s.client.DeleteService(ctx, &runpb.DeleteServiceRequest{Name: name})
+	})
+	if err != nil {
+		return newCloudRunError("DeleteService", name, err)
+	}
+	if _, err := deleteOp.Wait(ctx); err != nil {
+		return newCloudRunError("WaitForDelete", name, err)
+	}
+	return nil
+}
+
+func (s *gcpServicesClient) GetService(ctx context.Context, name string) (*runpb.Service, error) {
+	return s.client.GetService(ctx, &runpb.GetServiceRequest{Name: name})
+}
+
+func (s *gcpServicesClient) GetIamPolicy(ctx context.Context, resource string) (*iampb.Policy, error) {
+	return s.client.GetIamPolicy(ctx, &iampb.GetIamPolicyRequest{Resource: resource})
+}
+
+func (s *gcpServicesClient) SetIamPolicy(ctx context.Context, resource string, policy *iampb.Policy) error {
+	_, err := s.client.SetIamPolicy(ctx, &iampb.SetIamPolicyRequest{Resource: resource, Policy: policy})
+	return err
+}
+
+func (s *gcpServicesClient) Close() error {
+	return s.client.Close()
+}
@@ -0,0 +1,188 @@
+package deployments
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"path"
+	"slices"
+	"sync"
+	"testing"
+
+	iampb "cloud.google.com/go/iam/apiv1/iampb"
+	runpb "cloud.google.com/go/run/apiv2/runpb"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+)
+
+// fakeCloudRunServices is an in-memory cloudRunServices. Services are keyed by full name, and createErr, if set,
+// fails every create after recording the service, like a create whose operation fails partway.
+type fakeCloudRunServices struct {
+	mu        sync.Mutex
+	services  map[string]*runpb.Service
+	policies  map[string]*iampb.Policy
+	deleted   []string
+	createErr error
+}
+
+func newFakeCloudRunServices(existing ...string) *fakeCloudRunServices {
+	fake := &fakeCloudRunServices{services: map[string]*runpb.Service{}, policies: map[string]*iampb.Policy{}}
+	for _, name := range existing {
+		fake.services[name] = &runpb.Service{Name: name}
+	}
+	return fake
+}
+
+func (f *fakeCloudRunServices) CreateService(ctx context.Context, req *runpb.CreateServiceRequest) (*runpb.Service, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	name := req.Parent + "/services/" + req.ServiceId
+	if _, ok := f.services[name]; ok {
+		return nil, status.Error(codes.AlreadyExists, "service already exists")
+	}
+	service := proto.Clone(req.Service).(*runpb.Service)
+	service.Name = name
+	service.Uri = "https://" + req.ServiceId + ".a.run.app"
+	service.LatestCreatedRevision = name + "/revisions/" + req.ServiceId + "-00001"
+	f.services[name] = service
+	if f.createErr != nil {
+		return nil, f.createErr
+	}
+	return service, nil
+}
+
+func (f *fakeCloudRunServices) UpdateService(ctx context.Context, req *runpb.UpdateServiceRequest) (*runpb.Service, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if _, ok := f.services[req.Service.Name]; !ok {
+		return nil, status.Error(codes.NotFound, "service not found")
+	}
+	service := proto.Clone(req.Service).(*runpb.Service)
+	f.services[service.Name] = service
+	return service, nil
+}
+
+func (f *fakeCloudRunServices) DeleteService(ctx context.Context, name string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if _, ok := f.services[name]; !ok {
+		return status.Error(codes.NotFound, "service not found")
+	}
+	delete(f.services, name)
+	f.deleted = append(f.deleted, name)
+	return nil
+}
+
+func (f *fakeCloudRunServices) GetService(ctx context.Context, name string) (*runpb.Service, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	service, ok := f.services[name]
+	if !ok {
+		return nil, status.Error(codes.NotFound, "service not found")
+	}
+	return service, nil
+}
+
+func (f *fakeCloudRunServices) GetIamPolicy(ctx context.Context, resource string) (*iampb.Policy, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if policy, ok := f.policies[resource]; ok {
+		return proto.Clone(policy).(*iampb.Policy), nil
+	}
+	return &iampb.Policy{}, nil
+}
+
+func (f *fakeCloudRunServices) SetIamPolicy(ctx context.Context, resource string, policy *iampb.Policy) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.policies[resource] = proto.Clone(policy).(*iampb.Policy)
+	return nil
+}
+
+func (f *fakeCloudRunServices) Close() error {
+	return nil
+}
+
+func discardLogger() *slog.Logger {
+	return slog.New(slog.NewTextHandler(io.Discard, nil))
+}
+
+func TestProvisionRegionalServiceCreatesPublicService(t *testing.T) {
+	fake := newFakeCloudRunServices()
+	events := newDeploymentEventLog(discardLogger())
+	settings := revisionSettings{Image: "nginx:latest", Port: 8080, MaxInstances: 1}
+
+	url, revision, err := provisionRegionalService(context.Background(), discardLogger(), events, fake, "us-central1", "api-default-u1", "u1", settings)
+	if err != nil {
+		t.Fatalf("provisionRegionalService: %v", err)
+	}
+	if url != "https://api-default-u1.a.run.app" {
+		t.Errorf("url = %q", url)
+	}
+	if revision != "api-default-u1-00001" {
+		t.Errorf("revision = %q", revision)
+	}
+
+	serviceFullName := regionalServiceName("us-central1", "api-default-u1")
+	policy := fake.policies[serviceFullName]
+	public := policy != nil && slices.ContainsFunc(policy.Bindings, func(binding *iampb.Binding) bool {
+		return binding.Role == "roles/run.invoker" && slices.Contains(binding.Members, "allUsers")
+	})
+	if !public {
+		t.Errorf("policy = %v, want allUsers as run.invoker", policy)
+	}
+
+	var steps []string
+	for _, event := range events.snapshot() {
+		steps = append(steps, event.Step)
+	}
+	if !slices.Equal(steps, []string{"create_service", "set_iam_policy"}) {
+		t.Errorf("steps = %v", steps)
+	}
+}
+
+func TestProvisionRegionalServiceDeletesFailedService(t *testing.T) {
+	serviceFullName := regionalServiceName("us-central1", "api-default-u1")
+	fake := newFakeCloudRunServices()
+	fake.createErr = newCloudRunError("WaitForService", serviceFullName, status.Error(codes.Internal, "revision failed to start"))
+
+	_, _, err := provisionRegionalService(context.Background(), discardLogger(), newDeploymentEventLog(discardLogger()), fake, "us-central1", "api-default-u1", "u1", revisionSettings{})
+	if err == nil {
+		t.Fatal("provisionRegionalService succeeded, want the create error")
+	}
+	if !slices.Equal(fake.deleted, []string{serviceFullName}) {
+		t.Errorf("deleted = %v, want the half-created service removed", fake.deleted)
+	}
+}
+
+func TestProvisionRegionalServiceLeavesExistingServiceAlone(t *testing.T) {
+	serviceFullName := regionalServiceName("us-central1", "api-default-u1")
+	fake := newFakeCloudRunServices(serviceFullName)
+
+	_, _, err := provisionRegionalService(context.Background(), discardLogger(), newDeploymentEventLog(discardLogger()), fake, "us-central1", "api-default-u1", "u1", revisionSettings{})
+	if status.Code(err) != codes.AlreadyExists {
+		t.Fatalf("err = %v, want AlreadyExists", err)
+	}
+	if len(fake.deleted) > 0 {
+		t.Errorf("deleted = %v, want another job's service kept", fake.deleted)
+	}
+	if _, err := fake.GetService(context.Background(), serviceFullName); err != nil {
+		t.Errorf("existing service is gone: %v", err)
+	}
+}
+
+func TestWaitForLatestRevisionReady(t *testing.T) {
+	serviceFullName := regionalServiceName("us-central1", "api-default-u1")
+	fake := newFakeCloudRunServices()
+	fake.services[serviceFullName] = &runpb.Service{
+		Name:                  serviceFullName,
+		LatestCreatedRevision: serviceFullName + "/revisions/api-default-u1-00002",
+		LatestReadyRevision:   serviceFullName + "/revisions/api-default-u1-00002",
+	}
+
+	revision, ready, err := waitForLatestRevisionReady(context.Background(), fake, serviceFullName)
+	if err != nil || !ready || revision != path.Base(fake.services[serviceFullName].LatestCreatedRevision) {
+		t.Errorf("waitForLatestRevisionReady = %q, %v, %v; want the new revision ready", revision, ready, err)
+	}
+}
@@ -0,0 +1,53 @@
+package deployments
+
+import (
+	"github.com/jackc/pgx/v5"
+
+	"github.com/0p5dev/controller/internal/models"
+)
+
+// deploymentColumns must stay in the same order as the fields scanned in scanDeployment
+const deploymentColumns = "id, name, environment, url, container_image, image_digest, user_id, min_instances, max_instances, port, cpu_always_allocated, startup_cpu_boost, max_concurrency, request_timeout_seconds, vpc_connector, vpc_network, vpc_subnet, vpc_egress, sidecars, execution_environment, volumes, ingress, version, cpu, memory, revision, deletion_protection, region_urls, health, events, created_at, updated_at, deleted_at"
+
+func scanDeployment(row pgx.Row) (models.Deployment, error) {
+	var deployment models.Deployment
+	err := row.Scan(
+		&deployment.Id,
+		&deployment.Name,
+		&deployment.Environment,
+		&deployment.Url,
+		&deployment.ContainerImage,
+		&deployment.ImageDigest,
+		&deployment.UserId,
+		&deployment.MinInstances,
+		&deployment.MaxInstances,
+		&deployment.Port,
+		&deployment.CpuAlwaysAllocated,
+		&deployment.StartupCpuBoost,
+		&deployment.MaxConcurrency,
+		&deployment.RequestTimeoutSeconds,
+		&deployment.VpcConnector,
+		&deployment.VpcNetwork,
+		&deployment.VpcSubnet,
+		&deployment.VpcEgress,
+		&deployment.Sidecars,
+		&deployment.ExecutionEnvironment,
+		&deployment.Volumes,
+		&deployment.Ingress,
+		&deployment.Version,
+		&deployment.Cpu,
+		&deployment.Memory,
+		&deployment.Revision,
+		&deployment.DeletionProtection,
+		&deployment.RegionUrls,
+		&deployment.Health,
+		&deployment.Events,
+		&deployment.CreatedAt,
+		&deployment.UpdatedAt,
+		&deployment.DeletedAt,
+	)
+	if err != nil {
+		return models.Deployment{}, err
+	}
+	return deployment, nil
+}
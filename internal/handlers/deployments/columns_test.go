@@ -0,0 +1,84 @@
+package deployments
+
+import (
+	"fmt"
+	"reflect"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/0p5dev/controller/internal/models"
+)
+
+// markedRow is a row that sets only the column at index marked, to a non-zero value, and leaves the rest zero
+type markedRow struct {
+	marked int
+}
+
+func (r markedRow) Scan(dest ...any) error {
+	columns := strings.Split(deploymentColumns, ", ")
+	if len(dest) != len(columns) {
+		return fmt.Errorf("scanned %d fields for %d columns", len(dest), len(columns))
+	}
+	target := reflect.ValueOf(dest[r.marked]).Elem()
+	target.Set(nonZeroValue(target.Type()))
+	return nil
+}
+
+func nonZeroValue(t reflect.Type) reflect.Value {
+	if t == reflect.TypeFor[time.Time]() {
+		return reflect.ValueOf(time.Unix(1, 0))
+	}
+	value := reflect.New(t).Elem()
+	switch t.Kind() {
+	case reflect.String:
+		value.SetString("x")
+	case reflect.Int:
+		value.SetInt(1)
+	case reflect.Bool:
+		value.SetBool(true)
+	case reflect.Pointer:
+		value.Set(reflect.New(t.Elem()))
+	case reflect.Slice:
+		value.Set(reflect.MakeSlice(t, 1, 1))
+	case reflect.Map:
+		value.Set(reflect.MakeMap(t))
+	default:
+		panic("no non-zero value for " + t.String())
+	}
+	return value
+}
+
+func TestScanDeploymentMatchesColumns(t *testing.T) {
+	columns := strings.Split(deploymentColumns, ", ")
+	for i, column := range columns {
+		deployment, err := scanDeployment(markedRow{marked: i})
+		if err != nil {
+			t.Fatalf("scanDeployment: %v", err)
+		}
+
+		// The only field set should be the one whose JSON key matches the column
+		value := reflect.ValueOf(deployment)
+		var set []string
+		for field := range value.NumField() {
+			if !value.Field(field).IsZero() {
+				jsonName, _, _ := strings.Cut(value.Type().Field(field).Tag.Get("json"), ",")
+				set = append(set, jsonName)
+			}
+		}
+		if len(set) != 1 || set[0] != column {
+			t.Errorf("column %d (%s) scanned into %v", i, column, set)
+		}
+	}
+}
+
+func TestDeploymentColumnsCoverModel(t *testing.T) {
+	columns := strings.Split(deploymentColumns, ", ")
+	for field := range reflect.TypeFor[models.Deployment]().Fields() {
+		jsonName, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+		if !slices.Contains(columns, jsonName) {
+			t.Errorf("models.Deployment.%s (%s) is not in deploymentColumns", field.Name, jsonName)
+		}
+	}
+}
@@ -2,32 +2,73 @@ package deployments
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"log/slog"
 	"math/rand"
 	"net/http"
-	"os"
 	"slices"
 	"strings"
+	"sync"
 	"time"
 
 	iampb "cloud.google.com/go/iam/apiv1/iampb"
-	run "cloud.google.com/go/run/apiv2"
-	runpb "cloud.google.com/go/run/apiv2/runpb"
+	"github.com/0p5dev/controller/internal/config"
+	"github.com/0p5dev/controller/internal/models"
 	"github.com/0p5dev/controller/internal/sharedUtils"
 	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgxpool"
 	"github.com/oklog/ulid/v2"
-	"google.golang.org/grpc/codes"
-	"google.golang.org/grpc/status"
 )
 
 type CreateOneRequestBody struct {
-	Name           string `json:"name"`
-	ContainerImage string `json:"container_image"`
-	MinInstances   *int   `json:"min_instances,omitempty,string"`
-	MaxInstances   *int   `json:"max_instances,omitempty,string"`
-	Port           *int   `json:"port,omitempty,string"`
+	Name string `json:"name" binding:"required,dnslabel,max=20"`
+	// Environment lets one name be deployed more than once, e.g. as staging and production (default: default)
+	Environment    string `json:"environment,omitempty"`
+	ContainerImage string `json:"container_image" binding:"required,imageref"`
+	// RegistryCredentials reach a private image in an external registry such as Docker Hub or GHCR
+	RegistryCredentials *RegistryCredentials `json:"registry_credentials,omitempty"`
+	MinInstances        *int                 `json:"min_instances,omitempty,string"`
+	MaxInstances        *int                 `json:"max_instances,omitempty,string"`
+	Port                *int                 `json:"port,omitempty,string"`
+	CallbackUrl         string               `json:"callback_url,omitempty"`
+	// CpuAlwaysAllocated keeps CPU allocated between requests instead of only while serving them
+	CpuAlwaysAllocated bool `json:"cpu_always_allocated,omitempty"`
+	// StartupCpuBoost temporarily allocates extra CPU while instances start to reduce cold start latency
+	StartupCpuBoost bool `json:"startup_cpu_boost,omitempty"`
+	// MaxConcurrency caps concurrent requests per instance (default 80). Lower values make Cloud Run scale out to more instances under the same load.
+	MaxConcurrency *int `json:"max_concurrency,omitempty"`
+	// RequestTimeoutSeconds is how long a request may run before Cloud Run aborts it (default 300, max 3600)
+	RequestTimeoutSeconds *int `json:"request_timeout_seconds,omitempty"`
+	// VpcConnector routes egress through a Serverless VPC Access connector (projects/*/locations/*/connectors/*)
+	VpcConnector *string `json:"vpc_connector,omitempty"`
+	// VpcNetwork and VpcSubnet enable direct VPC egress as an alternative to a connector
+	VpcNetwork *string `json:"vpc_network,omitempty"`
+	VpcSubnet  *string `json:"vpc_subnet,omitempty"`
+	// VpcEgress is all-traffic or private-ranges-only, and requires a connector, network, or subnet
+	VpcEgress *string `json:"vpc_egress,omitempty"`
+	// HealthCheckPath enables a post-deploy GET against the service URL; the result is reported as health: ok|unreachable
+	HealthCheckPath           *string `json:"health_check_path,omitempty"`
+	HealthCheckTimeoutSeconds *int    `json:"health_check_timeout_seconds,omitempty"`
+	// Sidecars are extra containers, such as a logging agent or proxy, deployed next to the primary one
+	Sidecars []models.SidecarContainer `json:"sidecars,omitempty"`
+	// ExecutionEnvironment is gen1 or gen2 (default: chosen by Cloud Run). gen2 gives full Linux compatibility, including networking.
+	ExecutionEnvironment *string `json:"execution_environment,omitempty"`
+	// Volumes are mounted into the primary container: emptyDir (in-memory), gcs (a Cloud Storage bucket), or secret
+	Volumes []models.Volume `json:"volumes,omitempty"`
+	// Ingress is all (default), internal (VPC and internal load balancers only), or internal-and-cloud-load-balancing
+	Ingress *string `json:"ingress,omitempty"`
+	// Cpu and Memory limit the primary container, e.g. 1 and 512Mi. Precedence: the request's value, then the
+	// operator's DEFAULT_CPU or DEFAULT_MEMORY, then Cloud Run's default.
+	Cpu    *string `json:"cpu,omitempty"`
+	Memory *string `json:"memory,omitempty"`
+	// Version records the git SHA or release version being deployed; it is shown on the deployment and its revisions
+	Version *string `json:"version,omitempty" binding:"omitempty,max=128"`
+	// DeletionProtection makes deletes fail until it is turned off with an update (default: DEFAULT_DELETION_PROTECTION)
+	DeletionProtection *bool `json:"deletion_protection,omitempty"`
+	// Regions deploys the service to each listed region (default GCP_REGION); the first is the primary region
+	Regions []string `json:"regions,omitempty"`
 }
 
 // @Summary Create a new deployment
@@ -37,187 +78,458 @@ type CreateOneRequestBody struct {
 // @Produce json
 // @Security BearerAuth
 // @Param request body api.RequestBody true "Deployment details"
-// @Success 202 {object} map[string]string "Provisioning job accepted"
-// @Failure 400 {object} map[string]string "Invalid request payload"
+// @Success 202 {object} DeploymentResponse "Provisioning job accepted"
+// @Failure 400 {object} map[string]interface{} "Invalid request payload, image registry not allowed, or image not found or inaccessible"
 // @Failure 401 {object} map[string]string "Unauthorized"
-// @Failure 409 {object} map[string]string "Deployment already exists"
+// @Failure 403 {object} map[string]string "Container image does not belong to the user"
+// @Failure 409 {object} map[string]string "Deployment already exists, or another create for it is in progress"
+// @Failure 415 {object} map[string]interface{} "Content-Type is not application/json"
+// @Failure 422 {object} map[string]interface{} "Container image has blocking vulnerabilities"
 // @Failure 500 {object} map[string]string "Failed to queue deployment"
+// @Failure 502 {object} map[string]string "Failed to reach container registry"
+// @Failure 503 {object} map[string]string "Too many deployment operations in progress; see Retry-After"
 // @Router /deployments [post]
 func CreateOne(c *gin.Context) {
+	var reqBody CreateOneRequestBody
+	if err := c.ShouldBindJSON(&reqBody); err != nil {
+		c.JSON(http.StatusBadRequest, bindingErrorResponse(c, err))
+		return
+	}
+
+	createDeployment(c, reqBody).respond(c)
+}
+
+// createDeployment validates a bound create request and queues its provisioning job, shared by CreateOne and Import.
+// It only reads the request from c; the caller sends the result.
+func createDeployment(c *gin.Context, reqBody CreateOneRequestBody) deploymentResult {
 	userClaims := c.MustGet("UserClaims").(*sharedUtils.UserClaims)
+	userSettings := userClaims.DeploymentSettings()
+	logger := c.MustGet("Logger").(*slog.Logger)
 	pool := c.MustGet("Pool").(*pgxpool.Pool)
 
-	ctx := context.Background()
 	reqCtx := c.Request.Context()
 
-	var reqBody CreateOneRequestBody
-	if err := c.ShouldBindJSON(&reqBody); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{
-			"error":   "invalid request payload",
+	environment := reqBody.Environment
+	if environment == "" {
+		environment = defaultEnvironment
+	}
+	if err := validateEnvironment(reqBody.Name, environment); err != nil {
+		return deploymentError(http.StatusBadRequest, gin.H{
+			"error":   "invalid environment",
+			"message": err.Error(),
+		})
+	}
+
+	if err := validateImageRegistry(reqBody.ContainerImage); err != nil {
+		return deploymentError(http.StatusBadRequest, imageRegistryErrorResponse(err))
+	}
+	for _, sidecar := range reqBody.Sidecars {
+		if err := validateImageRegistry(sidecar.Image); err != nil {
+			return deploymentError(http.StatusBadRequest, imageRegistryErrorResponse(err))
+		}
+	}
+
+	effectiveMin, effectiveMax, err := sharedUtils.ValidateMinAndMaxInstances(reqBody.MinInstances, reqBody.MaxInstances, userSettings.MaxInstancesLimit)
+	if err != nil {
+		return deploymentError(http.StatusBadRequest, gin.H{
+			"error":   "invalid instance range",
 			"message": err.Error(),
 		})
-		return
 	}
 
-	if len(reqBody.Name) > 20 {
-		c.JSON(http.StatusBadRequest, gin.H{
-			"error":   "invalid deployment name",
-			"message": "name must be 20 characters or less",
+	if err := validateMaxConcurrency(reqBody.MaxConcurrency); err != nil {
+		return deploymentError(http.StatusBadRequest, gin.H{
+			"error":   "invalid max_concurrency",
+			"message": err.Error(),
+		})
+	}
+
+	if err := validateRequestTimeout(reqBody.RequestTimeoutSeconds); err != nil {
+		return deploymentError(http.StatusBadRequest, gin.H{
+			"error":   "invalid request_timeout_seconds",
+			"message": err.Error(),
+		})
+	}
+
+	if err := validateExecutionEnvironment(reqBody.ExecutionEnvironment); err != nil {
+		return deploymentError(http.StatusBadRequest, gin.H{
+			"error":   "invalid execution_environment",
+			"message": err.Error(),
+		})
+	}
+
+	if err := validateIngress(reqBody.Ingress); err != nil {
+		return deploymentError(http.StatusBadRequest, gin.H{
+			"error":   "invalid ingress",
+			"message": err.Error(),
+		})
+	}
+
+	if err := validateResourceLimits(reqBody.Cpu, reqBody.Memory); err != nil {
+		return deploymentError(http.StatusBadRequest, gin.H{
+			"error":   "invalid resource limits",
+			"message": err.Error(),
+		})
+	}
+
+	requestedRegions := reqBody.Regions
+	if len(requestedRegions) == 0 && userSettings.DefaultRegion != "" {
+		requestedRegions = []string{userSettings.DefaultRegion}
+	}
+	regions, err := resolveRegions(requestedRegions)
+	if err != nil {
+		return deploymentError(http.StatusBadRequest, gin.H{
+			"error":   "invalid regions",
+			"message": err.Error(),
+		})
+	}
+
+	if err := validateSidecars(reqBody.Sidecars, reqBody.Port != nil); err != nil {
+		return deploymentError(http.StatusBadRequest, gin.H{
+			"error":   "invalid sidecars",
+			"message": err.Error(),
 		})
-		return
+	}
+
+	if err := validateVolumes(reqBody.Volumes, reqBody.ExecutionEnvironment); err != nil {
+		return deploymentError(http.StatusBadRequest, gin.H{
+			"error":   "invalid volumes",
+			"message": err.Error(),
+		})
+	}
+
+	if err := validateHealthCheck(reqBody.HealthCheckPath, reqBody.HealthCheckTimeoutSeconds); err != nil {
+		return deploymentError(http.StatusBadRequest, gin.H{
+			"error":   "invalid health check settings",
+			"message": err.Error(),
+		})
+	}
+
+	if reqBody.CallbackUrl != "" {
+		if err := validateCallbackUrl(reqCtx, reqBody.CallbackUrl); err != nil {
+			return deploymentError(http.StatusBadRequest, gin.H{
+				"error":   "invalid callback_url",
+				"message": err.Error(),
+			})
+		}
 	}
 
 	var existingDeployment bool
-	err := pool.QueryRow(reqCtx, `SELECT EXISTS(SELECT 1 FROM deployments WHERE name=$1 AND user_id=$2)`, reqBody.Name, userClaims.UserMetadata.AppUser.Id).Scan(&existingDeployment)
+	err = pool.QueryRow(reqCtx, `SELECT EXISTS(SELECT 1 FROM deployments WHERE name=$1 AND user_id=$2 AND environment=$3 AND deleted_at IS NULL)`, reqBody.Name, userClaims.UserMetadata.AppUser.Id, environment).Scan(&existingDeployment)
 	if err != nil {
-		slog.Error("Failed to check existing deployments", "error", err.Error())
-		c.JSON(http.StatusInternalServerError, gin.H{
+		logger.Error("Failed to check existing deployments", "error", err.Error())
+		return deploymentError(http.StatusInternalServerError, gin.H{
 			"error":   "failed to check existing deployments",
 			"message": err.Error(),
 		})
-		return
 	}
 
 	if existingDeployment {
-		c.JSON(http.StatusConflict, gin.H{
-			"error": "deployment " + reqBody.Name + " already exists",
+		return deploymentError(http.StatusConflict, gin.H{
+			"error": "deployment " + reqBody.Name + " already exists in environment " + environment,
 		})
-		return
 	}
 
-	serviceId := fmt.Sprintf("%s-%s", reqBody.Name, userClaims.UserMetadata.AppUser.Id)
+	// Users may only deploy their own images or allowlisted public images. An external image the caller reaches with
+	// their own registry credentials counts as theirs; resolving it below proves the access.
+	if reqBody.RegistryCredentials == nil {
+		if err := authorizeContainerImage(reqCtx, pool, userClaims.UserMetadata.AppUser.Id, reqBody.ContainerImage); err != nil {
+			if errors.Is(err, errImageNotOwned) {
+				return deploymentError(http.StatusForbidden, gin.H{
+					"error": "container image " + reqBody.ContainerImage + " does not belong to you",
+				})
+			}
+			logger.Error("Failed to authorize container image", "image", reqBody.ContainerImage, "error", err.Error())
+			return deploymentError(http.StatusInternalServerError, gin.H{
+				"error": "failed to check container image ownership",
+			})
+		}
+	}
+
+	for _, sidecar := range reqBody.Sidecars {
+		if err := authorizeContainerImage(reqCtx, pool, userClaims.UserMetadata.AppUser.Id, sidecar.Image); err != nil {
+			if errors.Is(err, errImageNotOwned) {
+				return deploymentError(http.StatusForbidden, gin.H{
+					"error": "sidecar image " + sidecar.Image + " does not belong to you",
+				})
+			}
+			logger.Error("Failed to authorize sidecar image", "image", sidecar.Image, "error", err.Error())
+			return deploymentError(http.StatusInternalServerError, gin.H{
+				"error": "failed to check container image ownership",
+			})
+		}
+	}
+
+	// Confirm the image exists before provisioning, and pin the deployment to an immutable digest so a re-pushed tag can't change what is running
+	imageDigest, err := resolveImageDigest(reqCtx, reqBody.ContainerImage, reqBody.RegistryCredentials)
+	if err != nil {
+		logger.Warn("Failed to resolve container image", "image", reqBody.ContainerImage, "error", err.Error())
+		return deploymentError(imageResolutionErrorResponse(err))
+	}
+
+	if err := checkVolumeBuckets(reqCtx, reqBody.Volumes); err != nil {
+		logger.Warn("Failed to check volume buckets", "error", err.Error())
+		return deploymentError(volumeBucketErrorResponse(err))
+	}
+
+	if vulnerabilities := findBlockingVulnerabilities(reqCtx, imageDigest); len(vulnerabilities) > 0 {
+		return deploymentError(http.StatusUnprocessableEntity, gin.H{
+			"error":           "container image " + reqBody.ContainerImage + " has vulnerabilities at or above the allowed severity",
+			"vulnerabilities": vulnerabilities,
+		})
+	}
+
+	if err := recordContainerImageReference(reqCtx, pool, userClaims.UserMetadata.AppUser.Id, reqBody.ContainerImage); err != nil {
+		logger.Error("Failed to record container image reference", "image", reqBody.ContainerImage, "error", err.Error())
+		return deploymentError(http.StatusInternalServerError, gin.H{
+			"error": "failed to record container image",
+		})
+	}
+
+	serviceId := deploymentServiceId(reqBody.Name, environment, userClaims.UserMetadata.AppUser.Id)
+
+	effectivePort := 8080
+	if reqBody.Port != nil {
+		effectivePort = *reqBody.Port
+	}
+
+	// Sidecars are stored as JSON, so no sidecars is recorded as an empty list rather than null
+	sidecars := reqBody.Sidecars
+	if sidecars == nil {
+		sidecars = []models.SidecarContainer{}
+	}
+	volumes := reqBody.Volumes
+	if volumes == nil {
+		volumes = []models.Volume{}
+	}
+	deletionProtection := config.Get().DefaultDeletionProtection
+	if reqBody.DeletionProtection != nil {
+		deletionProtection = *reqBody.DeletionProtection
+	}
+
+	effectiveMaxConcurrency := defaultMaxConcurrency
+	if reqBody.MaxConcurrency != nil {
+		effectiveMaxConcurrency = *reqBody.MaxConcurrency
+	}
+
+	effectiveRequestTimeout := defaultRequestTimeoutSeconds
+	if reqBody.RequestTimeoutSeconds != nil {
+		effectiveRequestTimeout = *reqBody.RequestTimeoutSeconds
+	}
+
+	settings := revisionSettings{
+		Image:                imageDigest,
+		Port:                 effectivePort,
+		MinInstances:         effectiveMin,
+		MaxInstances:         effectiveMax,
+		CpuAlwaysAllocated:   reqBody.CpuAlwaysAllocated,
+		StartupCpuBoost:      reqBody.StartupCpuBoost,
+		MaxConcurrency:       effectiveMaxConcurrency,
+		RequestTimeout:       effectiveRequestTimeout,
+		VpcConnector:         optionalString(reqBody.VpcConnector),
+		VpcNetwork:           optionalString(reqBody.VpcNetwork),
+		VpcSubnet:            optionalString(reqBody.VpcSubnet),
+		VpcEgress:            optionalString(reqBody.VpcEgress),
+		Sidecars:             sidecars,
+		ExecutionEnvironment: optionalString(reqBody.ExecutionEnvironment),
+		Ingress:              optionalString(reqBody.Ingress),
+		Version:              optionalString(reqBody.Version),
+		Cpu:                  resourceLimit(reqBody.Cpu, config.Get().DefaultCpu),
+		Memory:               resourceLimit(reqBody.Memory, config.Get().DefaultMemory),
+		Volumes:              volumes,
+	}
+	if err := validateVpcSettings(settings, regions); err != nil {
+		return deploymentError(http.StatusBadRequest, gin.H{
+			"error":   "invalid VPC settings",
+			"message": err.Error(),
+		})
+	}
+
+	if !admitOperation() {
+		return operationsFullResult()
+	}
 
 	// Create entry in provisioning_jobs table and return job ID to client
 	entropy := rand.New(rand.NewSource(time.Now().UnixNano()))
 	ms := ulid.Timestamp(time.Now())
 	id, err := ulid.New(ms, entropy)
 	if err != nil {
-		slog.Error("Failed to generate ULID for provisioning job", "error", err.Error())
-		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{
+		logger.Error("Failed to generate ULID for provisioning job", "error", err.Error())
+		dismissOperation()
+		return deploymentError(http.StatusInternalServerError, gin.H{
 			"error": "failed to generate provisioning job ID",
 		})
-		return
 	}
 	safeId := strings.ToLower(id.String())
 
+	// The job claims the deployment's lock, and is only inserted while no deployment exists under this id. Together
+	// they make a create that races another create, or one that finished since the check above, fail with a 409
+	// instead of reaching Cloud Run.
 	var jobId string
-	err = pool.QueryRow(reqCtx, "INSERT INTO provisioning_jobs (id, resource_id, status) VALUES ($1, $2, 'pending') RETURNING id", safeId, serviceId).Scan(&jobId)
+	err = pool.QueryRow(reqCtx, `
+		INSERT INTO provisioning_jobs (id, resource_id, status)
+		SELECT $1, $2, 'pending'
+		WHERE NOT EXISTS(SELECT 1 FROM deployments WHERE id = $2 AND deleted_at IS NULL)
+		RETURNING id
+	`, safeId, serviceId).Scan(&jobId)
+	if errors.Is(err, pgx.ErrNoRows) {
+		dismissOperation()
+		return deploymentError(http.StatusConflict, gin.H{
+			"error": "deployment " + reqBody.Name + " already exists in environment " + environment,
+		})
+	}
+	if sharedUtils.IsUniqueViolation(err, pendingJobIndex) {
+		dismissOperation()
+		return deploymentError(http.StatusConflict, deploymentLockedResponse(reqBody.Name))
+	}
 	if err != nil {
-		slog.Error("Failed to create provisioning job", "resource_id", serviceId, "error", err)
-		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{
+		logger.Error("Failed to create provisioning job", "resource_id", serviceId, "error", err)
+		dismissOperation()
+		return deploymentError(http.StatusInternalServerError, gin.H{
 			"error": "failed to create provisioning job, update canceled",
 		})
-		return
 	}
 
-	c.JSON(http.StatusAccepted, gin.H{
-		"message": "Provisioning deployment " + reqBody.Name,
-		"job_id":  jobId,
-	})
+	auditEntry := sharedUtils.NewAuditLogEntry(c, "deployment.create", reqBody.Name)
+	ctx, finishOperation := startOperation(jobId)
+	response := DeploymentResponse{
+		Message:     "Provisioning deployment " + reqBody.Name,
+		Name:        reqBody.Name,
+		Environment: environment,
+		Action:      "created",
+		Status:      "pending",
+		JobId:       jobId,
+		Warnings:    cpuAllocationWarnings(settings),
+	}
 
 	go func() {
-		projectID := os.Getenv("GCP_PROJECT_ID")
-		region := os.Getenv("GCP_REGION")
-
-		parent := fmt.Sprintf("projects/%s/locations/%s", projectID, region)
-		serviceFullName := fmt.Sprintf("%s/services/%s", parent, serviceId)
+		defer finishOperation()
+
+		// Record the outcome so the optional callback and audit log can report it once the job finishes
+		callbackPayload := DeploymentCallbackPayload{JobId: jobId, Name: reqBody.Name, Status: "succeeded", Action: "created"}
+		failJob := func(errMsg string) {
+			errMsg = jobFailureMessage(ctx, errMsg)
+			callbackPayload.Status = "failed"
+			callbackPayload.Error = errMsg
+			cleanupCtx, cancelCleanup := cleanupContext(ctx)
+			defer cancelCleanup()
+			sharedUtils.FailProvisioningJob(cleanupCtx, pool, jobId, errMsg)
+		}
+		defer func() {
+			auditEntry.Outcome = callbackPayload.Status
+			sharedUtils.RecordAuditLogEntry(pool, auditEntry)
+		}()
+		if reqBody.CallbackUrl != "" {
+			defer func() { sendDeploymentCallback(reqBody.CallbackUrl, callbackPayload) }()
+		}
 
-		servicesClient, err := run.NewServicesClient(ctx)
+		releaseSlot, err := acquireOperationSlot(ctx)
 		if err != nil {
-			slog.Error("Failed to create Cloud Run client", "error", err.Error())
-			sharedUtils.FailProvisioningJob(ctx, pool, jobId, "failed to create Cloud Run client: "+err.Error())
+			logger.Error("Job gave up waiting for an operation slot", "resource_id", serviceId, "error", err.Error())
+			failJob("gave up waiting for other deployment operations to finish: " + err.Error())
 			return
 		}
-		defer servicesClient.Close()
+		defer releaseSlot()
 
-		effectiveMin, effectiveMax := sharedUtils.ValidateMinAndMaxInstances(reqBody.MinInstances, reqBody.MaxInstances)
-
-		effectivePort := 8080
-		if reqBody.Port != nil {
-			effectivePort = *reqBody.Port
+		servicesClient, err := newCloudRunServices(ctx)
+		if err != nil {
+			logger.Error("Failed to create Cloud Run client", "operation", "NewServicesClient", "error", err.Error())
+			failJob(newCloudRunError("NewServicesClient", "", err).Error())
+			return
 		}
+		defer servicesClient.Close()
 
-		serviceSpec := &runpb.Service{
-			Labels: map[string]string{
-				"created_by": "0p5dev_controller",
-				"user":       "user-" + userClaims.UserMetadata.AppUser.Id,
-			},
-			Scaling: &runpb.ServiceScaling{
-				MinInstanceCount: int32(effectiveMin),
-				MaxInstanceCount: int32(effectiveMax),
-			},
-			Template: &runpb.RevisionTemplate{
-				ServiceAccount: os.Getenv("SERVICE_ACCOUNT_EMAIL"),
-				Scaling: &runpb.RevisionScaling{
-					MinInstanceCount: int32(effectiveMin),
-					MaxInstanceCount: int32(effectiveMax),
-				},
-				Containers: []*runpb.Container{
-					{
-						Image: reqBody.ContainerImage,
-						Ports: []*runpb.ContainerPort{
-							{ContainerPort: int32(effectivePort)},
-						},
-					},
-				},
-			},
+		// Every region gets its own service with the same id, provisioned in parallel. A region that fails doesn't
+		// stop the others, so the outcome is reported per region.
+		events := newDeploymentEventLog(logger)
+		regionUrls := map[string]string{}
+		regionRevisions := map[string]string{}
+		regionErrors := map[string]string{}
+		var regionsMu sync.Mutex
+		var wg sync.WaitGroup
+		for _, region := range regions {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				regionUrl, revision, err := provisionRegionalService(ctx, logger, events, servicesClient, region, serviceId, userClaims.UserMetadata.AppUser.Id, settings)
+				regionsMu.Lock()
+				defer regionsMu.Unlock()
+				if err != nil {
+					regionErrors[region] = err.Error()
+					return
+				}
+				regionUrls[region] = regionUrl
+				regionRevisions[region] = revision
+			}()
 		}
+		wg.Wait()
 
-		createOp, err := servicesClient.CreateService(ctx, &runpb.CreateServiceRequest{
-			Parent:    parent,
-			Service:   serviceSpec,
-			ServiceId: serviceId,
-		})
-		if err != nil {
-			slog.Error("Failed to create Cloud Run service", "error", err.Error())
-			sharedUtils.FailProvisioningJob(ctx, pool, jobId, "failed to construct Cloud Run service: "+err.Error())
-			deleteCloudRunServiceIfExists(ctx, servicesClient, serviceFullName)
+		if len(regionUrls) == 0 {
+			failJob(describeRegionFailures(regionErrors))
 			return
 		}
-
-		service, err := createOp.Wait(ctx)
-		if err != nil {
-			slog.Error("Cloud Run service creation failed", "error", err.Error())
-			sharedUtils.FailProvisioningJob(ctx, pool, jobId, "Cloud Run service creation failed: "+err.Error())
-			deleteCloudRunServiceIfExists(ctx, servicesClient, serviceFullName)
-			return
+		if len(regions) > 1 {
+			callbackPayload.RegionUrls = regionUrls
 		}
 
+		// The first requested region that succeeded is the primary one, whose URL and revision are the deployment's
 		var serviceUrl string
-		if service != nil && service.Uri != "" {
-			serviceUrl = service.Uri
-		} else {
-			slog.Warn("serviceUrl not found in Cloud Run response", "deployment", reqBody.Name)
-			serviceUrl = "URL not available"
+		var revision string
+		for _, region := range regions {
+			if regionUrl, ok := regionUrls[region]; ok {
+				serviceUrl = regionUrl
+				revision = regionRevisions[region]
+				break
+			}
+		}
+		callbackPayload.ServiceUrl = serviceUrl
+		callbackPayload.Revision = revision
+		deleteRegionalServices := func() {
+			cleanupCtx, cancelCleanup := cleanupContext(ctx)
+			defer cancelCleanup()
+			for region := range regionUrls {
+				deleteCloudRunServiceIfExists(cleanupCtx, servicesClient, regionalServiceName(region, serviceId))
+			}
 		}
 
-		// Ensure public access using Cloud Run service IAM policy
-		if err := ensurePublicInvokerAccess(ctx, servicesClient, serviceFullName); err != nil {
-			slog.Error("Failed to set IAM policy", "error", err.Error())
-			// Attempt to delete the service since it's not publicly accessible and likely unusable for the user
-			sharedUtils.FailProvisioningJob(ctx, pool, jobId, "failed to set IAM policy for public access: "+err.Error())
-			deleteCloudRunServiceIfExists(ctx, servicesClient, serviceFullName)
-			return
+		// Probe the service when requested; an unhealthy result is recorded but doesn't fail the deployment
+		var health *string
+		if reqBody.HealthCheckPath != nil && serviceUrl != unavailableServiceUrl {
+			timeoutSeconds := defaultHealthCheckTimeoutSeconds
+			if reqBody.HealthCheckTimeoutSeconds != nil {
+				timeoutSeconds = *reqBody.HealthCheckTimeoutSeconds
+			}
+			result := probeServiceHealth(serviceUrl, *reqBody.HealthCheckPath, timeoutSeconds)
+			health = &result
+			callbackPayload.Health = result
 		}
 
 		// Record deployment in database
 		_, err = pool.Exec(ctx, `
-				INSERT INTO deployments (id, name, url, container_image, user_id, min_instances, max_instances, port)
-				VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
-			`, serviceId, reqBody.Name, serviceUrl, reqBody.ContainerImage, userClaims.UserMetadata.AppUser.Id, effectiveMin, effectiveMax, effectivePort)
+				INSERT INTO deployments (id, name, environment, url, container_image, image_digest, user_id, min_instances, max_instances, port, cpu_always_allocated, startup_cpu_boost, max_concurrency, request_timeout_seconds, vpc_connector, vpc_network, vpc_subnet, vpc_egress, sidecars, execution_environment, volumes, ingress, version, cpu, memory, revision, deletion_protection, region_urls, health, events)
+				VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16, $17, $18, $19, $20, $21, $22, $23, $24, $25, $26, $27, $28, $29, $30)
+			`, serviceId, reqBody.Name, environment, serviceUrl, reqBody.ContainerImage, imageDigest, userClaims.UserMetadata.AppUser.Id, effectiveMin, effectiveMax, effectivePort, reqBody.CpuAlwaysAllocated, reqBody.StartupCpuBoost, effectiveMaxConcurrency, effectiveRequestTimeout, settings.VpcConnector, settings.VpcNetwork, settings.VpcSubnet, settings.VpcEgress, sidecars, settings.ExecutionEnvironment, volumes, settings.Ingress, settings.Version, settings.Cpu, settings.Memory, optionalString(&revision), deletionProtection, regionUrls, health, events.snapshot())
 		if err != nil {
-			slog.Error("Failed to record deployment in database", "error", err.Error())
-			sharedUtils.FailProvisioningJob(ctx, pool, jobId, "failed to record deployment in database: "+err.Error())
-			deleteCloudRunServiceIfExists(ctx, servicesClient, serviceFullName)
+			logger.Error("Failed to record deployment in database", "error", err.Error())
+			failJob("failed to record deployment in database: " + err.Error())
+			deleteRegionalServices()
+			return
+		}
+
+		// The regions that succeeded stay deployed and recorded, so they can be used or deleted like any deployment
+		if len(regionErrors) > 0 {
+			failJob(fmt.Sprintf("deployed to %d of %d regions; failed in %s", len(regionUrls), len(regions), describeRegionFailures(regionErrors)))
 			return
 		}
 
 		sharedUtils.SucceedProvisioningJob(ctx, pool, jobId)
 	}()
+
+	return deploymentResult{Status: http.StatusAccepted, Response: response}
 }
 
-func ensurePublicInvokerAccess(ctx context.Context, servicesClient *run.ServicesClient, serviceFullName string) error {
-	policy, err := servicesClient.GetIamPolicy(ctx, &iampb.GetIamPolicyRequest{Resource: serviceFullName})
+func ensurePublicInvokerAccess(ctx context.Context, servicesClient cloudRunServices, serviceFullName string) error {
+	policy, err := servicesClient.GetIamPolicy(ctx, serviceFullName)
 	if err != nil {
 		return err
 	}
@@ -232,8 +544,7 @@ func ensurePublicInvokerAccess(ctx context.Context, servicesClient *run.Services
 		}
 
 		binding.Members = append(binding.Members, "allUsers")
-		_, err = servicesClient.SetIamPolicy(ctx, &iampb.SetIamPolicyRequest{Resource: serviceFullName, Policy: policy})
-		return err
+		return servicesClient.SetIamPolicy(ctx, serviceFullName, policy)
 	}
 
 	policy.Bindings = append(policy.Bindings, &iampb.Binding{
@@ -241,20 +552,11 @@ func ensurePublicInvokerAccess(ctx context.Context, servicesClient *run.Services
 		Members: []string{"allUsers"},
 	})
 
-	_, err = servicesClient.SetIamPolicy(ctx, &iampb.SetIamPolicyRequest{Resource: serviceFullName, Policy: policy})
-	return err
+	return servicesClient.SetIamPolicy(ctx, serviceFullName, policy)
 }
 
-func deleteCloudRunServiceIfExists(ctx context.Context, servicesClient *run.ServicesClient, serviceFullName string) {
-	deleteOp, err := servicesClient.DeleteService(ctx, &runpb.DeleteServiceRequest{Name: serviceFullName})
-	if err != nil {
-		slog.Error("Failed to initiate Cloud Run service deletion during cleanup", "service", serviceFullName, "error", err.Error())
-		return
-	}
-
-	_, err = deleteOp.Wait(ctx)
-	if err != nil && status.Code(err) != codes.NotFound {
-		slog.Error("Failed to wait for Cloud Run service deletion during cleanup", "service", serviceFullName, "error", err.Error())
-		return
+func deleteCloudRunServiceIfExists(ctx context.Context, servicesClient cloudRunServices, serviceFullName string) {
+	if _, err := deleteCloudRunService(ctx, servicesClient, serviceFullName); err != nil {
+		slog.Error("Failed to delete Cloud Run service during cleanup", "service", serviceFullName, "error", err.Error())
 	}
 }
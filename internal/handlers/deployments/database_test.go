@@ -0,0 +1,69 @@
+package deployments
+
+import (
+	"context"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/oklog/ulid/v2"
+
+	"github.com/0p5dev/controller/internal/models"
+)
+
+var migrateTestDatabase sync.Once
+
+// testPool connects to the Postgres at TEST_DATABASE_URL and builds the schema there, or skips the test when it
+// isn't set. Tests share the database, so each one works with its own users.
+func testPool(t *testing.T) *pgxpool.Pool {
+	t.Helper()
+	url := os.Getenv("TEST_DATABASE_URL")
+	if url == "" {
+		t.Skip("TEST_DATABASE_URL is not set")
+	}
+
+	pool, err := pgxpool.New(context.Background(), url)
+	if err != nil {
+		t.Fatalf("failed to connect to the test database: %v", err)
+	}
+	t.Cleanup(pool.Close)
+
+	var migrateErr error
+	migrateTestDatabase.Do(func() {
+		for _, migration := range models.TableMigrations {
+			if migrateErr = migration.Fn(pool); migrateErr != nil {
+				return
+			}
+		}
+	})
+	if migrateErr != nil {
+		t.Fatalf("failed to migrate the test database: %v", migrateErr)
+	}
+	return pool
+}
+
+// createTestUser adds a user that is removed, along with their deployments and images, when the test ends
+func createTestUser(t *testing.T, pool *pgxpool.Pool) string {
+	t.Helper()
+	ctx := context.Background()
+	userId := ulid.Make().String()
+	if _, err := pool.Exec(ctx, "INSERT INTO users (id, email) VALUES ($1, $2)", userId, strings.ToLower(userId)+"@example.com"); err != nil {
+		t.Fatalf("failed to create test user: %v", err)
+	}
+	t.Cleanup(func() {
+		pool.Exec(ctx, "DELETE FROM deployments WHERE user_id = $1", userId)
+		pool.Exec(ctx, "DELETE FROM container_images WHERE user_id = $1", userId)
+		pool.Exec(ctx, "DELETE FROM users WHERE id = $1", userId)
+	})
+	return userId
+}
+
+// createTestImage records an image as pushed by userId
+func createTestImage(t *testing.T, pool *pgxpool.Pool, userId string, image string) {
+	t.Helper()
+	if _, err := pool.Exec(context.Background(), "INSERT INTO container_images (fqin, user_id) VALUES ($1, $2)", image, userId); err != nil {
+		t.Fatalf("failed to create test image: %v", err)
+	}
+}
@@ -0,0 +1,25 @@
+package deployments
+
+import (
+	"context"
+	"time"
+
+	"github.com/0p5dev/controller/internal/sharedUtils"
+)
+
+// cleanupGracePeriod is how long a job that failed or timed out keeps running to mark itself failed and undo its
+// partial changes, deleting services included
+const cleanupGracePeriod = 2 * time.Minute
+
+// deploymentTimeout bounds all background work of a create or update, Cloud Run calls and database writes alike
+// (DEPLOYMENT_TIMEOUT_MINUTES, default 15). The client already has the job id when it starts, so it deliberately
+// isn't tied to the request context.
+func deploymentTimeout() time.Duration {
+	return time.Duration(sharedUtils.GetEnvInt("DEPLOYMENT_TIMEOUT_MINUTES", 15)) * time.Minute
+}
+
+// cleanupContext outlives ctx's deadline, so a job that timed out can still be marked failed and its
+// partial changes undone
+func cleanupContext(ctx context.Context) (context.Context, context.CancelFunc) {
+	return context.WithTimeout(context.WithoutCancel(ctx), cleanupGracePeriod)
+}
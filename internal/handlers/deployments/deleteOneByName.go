@@ -2,37 +2,42 @@ package deployments
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"log/slog"
 	"net/http"
-	"os"
 
-	run "cloud.google.com/go/run/apiv2"
-	runpb "cloud.google.com/go/run/apiv2/runpb"
 	"github.com/0p5dev/controller/internal/sharedUtils"
 	"github.com/gin-gonic/gin"
 	"github.com/jackc/pgx/v5/pgxpool"
-	"google.golang.org/grpc/codes"
-	"google.golang.org/grpc/status"
 )
 
 // @Summary Delete a deployment
-// @Description Delete a Cloud Run deployment and remove it from the database
+// @Description Delete a Cloud Run deployment and remove it from the database. With dry_run=true nothing is deleted, and a DeletionPreview of the Cloud Run services and custom domains a delete would remove is returned instead.
 // @Tags deployments
 // @Produce json
 // @Security BearerAuth
 // @Param name path string true "Deployment name"
-// @Success 200 {object} map[string]string "Deployment deleted successfully"
-// @Failure 400 {object} map[string]string "Deployment name is required"
+// @Param environment query string false "Deployment environment (default: default)"
+// @Param dry_run query bool false "List what would be deleted without deleting it"
+// @Param confirm query string false "The deployment name again; when given, the delete only goes ahead if it matches"
+// @Success 200 {object} DeploymentResponse "Deployment deleted successfully"
+// @Failure 400 {object} map[string]string "Deployment name is required, or confirm doesn't match it"
 // @Failure 401 {object} map[string]string "Unauthorized"
 // @Failure 404 {object} map[string]string "Deployment not found"
+// @Failure 409 {object} map[string]string "Deployment has deletion protection, or a create or update of it is in progress"
 // @Failure 500 {object} map[string]string "Failed to delete deployment"
 // @Router /deployments/{name} [delete]
 func DeleteOneByName(c *gin.Context) {
 	userClaims := c.MustGet("UserClaims").(*sharedUtils.UserClaims)
+	logger := c.MustGet("Logger").(*slog.Logger)
 	pool := c.MustGet("Pool").(*pgxpool.Pool)
 
 	deploymentName := c.Param("name")
+	environment, ok := environmentParam(c)
+	if !ok {
+		return
+	}
 	if deploymentName == "" {
 		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{
 			"error": "deployment name is required",
@@ -40,62 +45,86 @@ func DeleteOneByName(c *gin.Context) {
 		return
 	}
 
-	ctx := context.Background()
+	if c.Query("dry_run") == "true" {
+		preview, err := previewDestroyDeployment(c.Request.Context(), pool, userClaims.UserMetadata.AppUser.Id, deploymentName, environment)
+		if err != nil {
+			if errors.Is(err, errDeploymentNotFound) {
+				c.AbortWithStatusJSON(http.StatusNotFound, gin.H{
+					"error": "deployment not found",
+				})
+				return
+			}
+			logger.Error("Failed to preview deployment deletion", "deployment", deploymentName, "error", err)
+			c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{
+				"error": "failed to preview deployment deletion",
+			})
+			return
+		}
+		c.JSON(http.StatusOK, preview)
+		return
+	}
 
-	// Verify the deployment belongs to the authenticated user
-	var deploymentId string
-	err := pool.QueryRow(ctx, "SELECT id FROM deployments WHERE name = $1 AND user_id = $2", deploymentName, userClaims.UserMetadata.AppUser.Id).Scan(&deploymentId)
-	if err != nil {
-		slog.Error("Error finding deployment", "deployment", deploymentName, "user_id", userClaims.UserMetadata.AppUser.Id, "user_email", userClaims.UserMetadata.AppUser.Email, "error", err)
-		c.AbortWithStatusJSON(http.StatusNotFound, gin.H{
-			"error": "deployment not found",
+	// Retyping the name guards against deleting the wrong deployment, e.g. from a script run against production
+	if confirm, ok := c.GetQuery("confirm"); ok && confirm != deploymentName {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{
+			"error": "confirm must match the deployment name " + deploymentName,
 		})
 		return
 	}
 
-	projectID := os.Getenv("GCP_PROJECT_ID")
-	region := os.Getenv("GCP_REGION")
+	auditEntry := sharedUtils.NewAuditLogEntry(c, "deployment.delete", deploymentName)
+	defer func() { sharedUtils.RecordAuditLogEntry(pool, auditEntry) }()
 
-	serviceFullName := fmt.Sprintf("projects/%s/locations/%s/services/%s", projectID, region, deploymentId)
+	ctx := context.Background()
 
-	servicesClient, err := run.NewServicesClient(ctx)
+	servicesClient, err := newCloudRunServices(ctx)
 	if err != nil {
-		slog.Error("Failed to create Cloud Run client", "error", err)
-		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{
-			"error": fmt.Sprintf("Failed to create Cloud Run client: %v", err),
-		})
+		abortWithCloudRunError(c, &CloudRunError{Operation: "NewServicesClient", Err: err})
 		return
 	}
 	defer servicesClient.Close()
 
-	deleteOp, err := servicesClient.DeleteService(ctx, &runpb.DeleteServiceRequest{Name: serviceFullName})
+	serviceAlreadyGone, err := destroyDeployment(ctx, logger, pool, servicesClient, userClaims.UserMetadata.AppUser.Id, deploymentName, environment)
 	if err != nil {
-		slog.Error("Failed to delete Cloud Run service", "service", serviceFullName, "error", err)
+		if errors.Is(err, errDeploymentNotFound) {
+			logger.Error("Error finding deployment", "deployment", deploymentName, "user_id", userClaims.UserMetadata.AppUser.Id, "user_email", userClaims.UserMetadata.AppUser.Email, "error", err)
+			c.AbortWithStatusJSON(http.StatusNotFound, gin.H{
+				"error": "deployment not found",
+			})
+			return
+		}
+		if errors.Is(err, errDeletionProtected) {
+			c.AbortWithStatusJSON(http.StatusConflict, gin.H{
+				"error":   "deployment " + deploymentName + " has deletion protection",
+				"message": "set deletion_protection to false with PATCH /deployments/" + deploymentName + " before deleting it",
+			})
+			return
+		}
+		if errors.Is(err, errDeploymentLocked) {
+			c.AbortWithStatusJSON(http.StatusConflict, deploymentLockedResponse(deploymentName))
+			return
+		}
+		var cloudRunErr *CloudRunError
+		if errors.As(err, &cloudRunErr) {
+			abortWithCloudRunError(c, cloudRunErr)
+			return
+		}
 		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{
-			"error": fmt.Sprintf("Failed to destroy Cloud Run resources: %v", err),
+			"error": err.Error(),
 		})
 		return
 	}
+	auditEntry.Outcome = "succeeded"
 
-	if _, err := deleteOp.Wait(ctx); err != nil && status.Code(err) != codes.NotFound {
-		slog.Error("Failed waiting for Cloud Run deletion", "service", serviceFullName, "error", err)
-		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{
-			"error": fmt.Sprintf("Failed to destroy Cloud Run resources: %v", err),
-		})
-		return
+	response := DeploymentResponse{
+		Message:     fmt.Sprintf("Deployment '%s' deleted successfully", deploymentName),
+		Name:        deploymentName,
+		Environment: environment,
+		Action:      "deleted",
+		Status:      "succeeded",
 	}
-
-	// Delete the deployment from the database
-	_, err = pool.Exec(ctx, "DELETE FROM deployments WHERE id = $1", deploymentId)
-	if err != nil {
-		slog.Error("Failed to delete deployment from database", "deployment_id", deploymentId, "error", err)
-		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{
-			"error": fmt.Sprintf("Cloud Run resources destroyed but failed to delete database record: %v", err),
-		})
-		return
+	if serviceAlreadyGone {
+		response.Message += "; its Cloud Run resources were already gone"
 	}
-
-	c.JSON(http.StatusOK, gin.H{
-		"message": fmt.Sprintf("Deployment '%s' deleted successfully", deploymentName),
-	})
+	c.JSON(http.StatusOK, response)
 }
@@ -0,0 +1,159 @@
+package deployments
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strings"
+
+	"github.com/0p5dev/controller/internal/models"
+	"github.com/0p5dev/controller/internal/sharedUtils"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/oklog/ulid/v2"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+var (
+	errDeploymentNotFound = errors.New("deployment not found")
+	errDeletionProtected  = errors.New("deployment has deletion protection; turn it off with an update setting deletion_protection to false first")
+	errDeploymentLocked   = errors.New("deployment has a create, update, or delete in progress; wait for it to finish or cancel it")
+)
+
+// DeletionPreview lists what deleting a deployment would remove, without removing anything
+type DeletionPreview struct {
+	Name              string   `json:"name"`
+	Environment       string   `json:"environment"`
+	Services          []string `json:"services"`           // Cloud Run services, one per region
+	Domains           []string `json:"domains"`            // custom domains mapped to the deployment
+	DeletionProtected bool     `json:"deletion_protected"` // the delete would be refused until protection is turned off
+}
+
+// previewDestroyDeployment reports what destroyDeployment would remove for the same deployment
+func previewDestroyDeployment(ctx context.Context, pool *pgxpool.Pool, userId string, deploymentName string, environment string) (DeletionPreview, error) {
+	var deployment models.Deployment
+	err := pool.QueryRow(ctx, "SELECT id, url, region_urls, deletion_protection FROM deployments WHERE name = $1 AND user_id = $2 AND environment = $3 AND deleted_at IS NULL", deploymentName, userId, environment).Scan(&deployment.Id, &deployment.Url, &deployment.RegionUrls, &deployment.DeletionProtection)
+	if err != nil {
+		return DeletionPreview{}, fmt.Errorf("%w: %v", errDeploymentNotFound, err)
+	}
+
+	preview := DeletionPreview{
+		Name:              deploymentName,
+		Environment:       environment,
+		Services:          []string{},
+		Domains:           []string{},
+		DeletionProtected: deployment.DeletionProtection,
+	}
+	for _, region := range deploymentRegions(deployment) {
+		preview.Services = append(preview.Services, regionalServiceName(region, deployment.Id))
+	}
+
+	rows, err := pool.Query(ctx, "SELECT domain FROM domain_mappings WHERE deployment_id = $1 ORDER BY domain", deployment.Id)
+	if err != nil {
+		return DeletionPreview{}, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var domain string
+		if err := rows.Scan(&domain); err != nil {
+			return DeletionPreview{}, err
+		}
+		preview.Domains = append(preview.Domains, domain)
+	}
+	return preview, rows.Err()
+}
+
+// destroyDeployment deletes the Cloud Run service backing a user's deployment and removes its database record.
+// It reports whether the service had already been removed out-of-band. Callers map the returned error to a response.
+func destroyDeployment(ctx context.Context, logger *slog.Logger, pool *pgxpool.Pool, servicesClient cloudRunServices, userId string, deploymentName string, environment string) (bool, error) {
+	// Verify the deployment belongs to the user
+	var deployment models.Deployment
+	err := pool.QueryRow(ctx, "SELECT id, url, region_urls, deletion_protection FROM deployments WHERE name = $1 AND user_id = $2 AND environment = $3 AND deleted_at IS NULL", deploymentName, userId, environment).Scan(&deployment.Id, &deployment.Url, &deployment.RegionUrls, &deployment.DeletionProtection)
+	if err != nil {
+		return false, fmt.Errorf("%w: %v", errDeploymentNotFound, err)
+	}
+	if deployment.DeletionProtection {
+		return false, errDeletionProtected
+	}
+	deploymentId := deployment.Id
+
+	// The delete holds the deployment's lock for its whole run through a job of its own, so it can't start under a
+	// running create or update, and none can start against a service it is deleting
+	jobId := strings.ToLower(ulid.Make().String())
+	_, err = pool.Exec(ctx, "INSERT INTO provisioning_jobs (id, resource_id, status) VALUES ($1, $2, 'pending')", jobId, deploymentId)
+	if sharedUtils.IsUniqueViolation(err, pendingJobIndex) {
+		return false, errDeploymentLocked
+	}
+	if err != nil {
+		return false, fmt.Errorf("failed to claim the deployment's lock: %v", err)
+	}
+	jobCtx := context.WithoutCancel(ctx)
+
+	// An update that finished before the lock was claimed may have turned protection on
+	err = pool.QueryRow(ctx, "SELECT deletion_protection FROM deployments WHERE id = $1 AND deleted_at IS NULL", deploymentId).Scan(&deployment.DeletionProtection)
+	if err != nil || deployment.DeletionProtection {
+		sharedUtils.FailProvisioningJob(jobCtx, pool, jobId, "deployment changed before the delete started")
+		if err != nil {
+			return false, fmt.Errorf("%w: %v", errDeploymentNotFound, err)
+		}
+		return false, errDeletionProtected
+	}
+
+	// Domain mappings route to the service, so they are removed before it
+	if err := deleteDeploymentDomainMappings(ctx, pool, deploymentId); err != nil {
+		logger.Error("Failed to delete domain mappings", "deployment_id", deploymentId, "error", err)
+		sharedUtils.FailProvisioningJob(jobCtx, pool, jobId, err.Error())
+		return false, fmt.Errorf("Failed to remove custom domains: %v", err)
+	}
+
+	// If the services were already removed out-of-band, skip the destroy and still clean up the database record
+	serviceAlreadyGone := true
+	for _, region := range deploymentRegions(deployment) {
+		serviceFullName := regionalServiceName(region, deploymentId)
+		gone, err := deleteCloudRunService(ctx, servicesClient, serviceFullName)
+		if err != nil {
+			logger.Error("Failed to delete Cloud Run service", "operation", "DeleteService", "service", serviceFullName, "error", err)
+			sharedUtils.FailProvisioningJob(jobCtx, pool, jobId, err.Error())
+			return false, newCloudRunError("DeleteService", serviceFullName, err)
+		}
+		if gone {
+			logger.Warn("Cloud Run service not found during delete", "service", serviceFullName)
+		}
+		serviceAlreadyGone = serviceAlreadyGone && gone
+	}
+
+	// Soft-delete the record so it is kept for audit. Its id is retired so the same deployment name (and id) can be
+	// created again.
+	tag, err := pool.Exec(jobCtx, "UPDATE deployments SET id = id || '-deleted-' || EXTRACT(EPOCH FROM NOW())::BIGINT, deleted_at = NOW() WHERE id = $1 AND deleted_at IS NULL", deploymentId)
+	if err != nil {
+		logger.Error("Failed to delete deployment from database", "deployment_id", deploymentId, "error", err)
+		sharedUtils.FailProvisioningJob(jobCtx, pool, jobId, err.Error())
+		return serviceAlreadyGone, fmt.Errorf("Cloud Run resources destroyed but failed to delete database record: %v", err)
+	}
+	if tag.RowsAffected() == 0 {
+		sharedUtils.FailProvisioningJob(jobCtx, pool, jobId, "deployment record was already deleted")
+		return serviceAlreadyGone, errDeploymentNotFound
+	}
+
+	sharedUtils.SucceedProvisioningJob(jobCtx, pool, jobId)
+	return serviceAlreadyGone, nil
+}
+
+// deleteCloudRunService deletes a Cloud Run service and waits for the operation to finish.
+// A service that does not exist is not an error; it is reported as already gone.
+func deleteCloudRunService(ctx context.Context, servicesClient cloudRunServices, serviceFullName string) (bool, error) {
+	err := servicesClient.DeleteService(ctx, serviceFullName)
+	var cloudRunErr *CloudRunError
+	switch {
+	case err == nil:
+		return false, nil
+	case status.Code(err) != codes.NotFound:
+		return false, err
+	case errors.As(err, &cloudRunErr) && cloudRunErr.Operation == "WaitForDelete":
+		// Removed by someone else while the delete was running
+		return false, nil
+	default:
+		return true, nil
+	}
+}
@@ -0,0 +1,131 @@
+package deployments
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/oklog/ulid/v2"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/0p5dev/controller/internal/config"
+)
+
+func TestDeleteCloudRunService(t *testing.T) {
+	serviceFullName := regionalServiceName("us-central1", "api-default-u1")
+	fake := newFakeCloudRunServices(serviceFullName)
+
+	gone, err := deleteCloudRunService(context.Background(), fake, serviceFullName)
+	if err != nil || gone {
+		t.Fatalf("deleteCloudRunService = %v, %v; want the service deleted", gone, err)
+	}
+	if _, err := fake.GetService(context.Background(), serviceFullName); status.Code(err) != codes.NotFound {
+		t.Errorf("service still exists after delete")
+	}
+}
+
+func TestDeleteCloudRunServiceAlreadyGone(t *testing.T) {
+	serviceFullName := regionalServiceName("us-central1", "api-default-u1")
+
+	gone, err := deleteCloudRunService(context.Background(), newFakeCloudRunServices(), serviceFullName)
+	if err != nil {
+		t.Fatalf("deleteCloudRunService: %v, want a missing service not to be an error", err)
+	}
+	if !gone {
+		t.Error("gone = false, want a missing service reported as already gone")
+	}
+}
+
+// removedMidDelete is a service that disappears while its delete operation is running
+type removedMidDelete struct {
+	*fakeCloudRunServices
+}
+
+func (r removedMidDelete) DeleteService(ctx context.Context, name string) error {
+	return newCloudRunError("WaitForDelete", name, status.Error(codes.NotFound, "service not found"))
+}
+
+func TestDeleteCloudRunServiceRemovedWhileDeleting(t *testing.T) {
+	serviceFullName := regionalServiceName("us-central1", "api-default-u1")
+
+	gone, err := deleteCloudRunService(context.Background(), removedMidDelete{newFakeCloudRunServices(serviceFullName)}, serviceFullName)
+	if err != nil || gone {
+		t.Errorf("deleteCloudRunService = %v, %v; want the delete counted as ours", gone, err)
+	}
+}
+
+type failingDelete struct {
+	*fakeCloudRunServices
+	err error
+}
+
+func (f failingDelete) DeleteService(context.Context, string) error {
+	return f.err
+}
+
+func TestDeleteCloudRunServiceFailure(t *testing.T) {
+	serviceFullName := regionalServiceName("us-central1", "api-default-u1")
+	deleteErr := status.Error(codes.PermissionDenied, "caller lacks run.services.delete")
+
+	_, err := deleteCloudRunService(context.Background(), failingDelete{newFakeCloudRunServices(serviceFullName), deleteErr}, serviceFullName)
+	if !errors.Is(err, deleteErr) {
+		t.Errorf("err = %v, want %v", err, deleteErr)
+	}
+}
+
+// createLockTestDeployment adds a deployment named api to userId, and removes its provisioning jobs when the test ends
+func createLockTestDeployment(t *testing.T, pool *pgxpool.Pool, userId string) string {
+	t.Helper()
+	ctx := context.Background()
+	image := "us-docker.pkg.dev/project/repo/" + userId + ":v1"
+	createTestImage(t, pool, userId, image)
+	deploymentId := deploymentServiceId("api", defaultEnvironment, userId)
+	if _, err := pool.Exec(ctx, "INSERT INTO deployments (id, name, url, container_image, user_id) VALUES ($1, 'api', '', $2, $3)", deploymentId, image, userId); err != nil {
+		t.Fatalf("failed to create deployment: %v", err)
+	}
+	t.Cleanup(func() { pool.Exec(ctx, "DELETE FROM provisioning_jobs WHERE resource_id = $1", deploymentId) })
+	return deploymentId
+}
+
+func TestDestroyDeploymentHoldsTheLock(t *testing.T) {
+	pool := testPool(t)
+	useTestConfig(t, &config.Config{GcpProjectId: "project", GcpRegion: "us-central1"})
+	ctx := context.Background()
+	userId := createTestUser(t, pool)
+	deploymentId := createLockTestDeployment(t, pool, userId)
+	fake := newFakeCloudRunServices(regionalServiceName("us-central1", deploymentId))
+
+	// A job holding the lock turns the delete away before it touches Cloud Run
+	if _, err := pool.Exec(ctx, "INSERT INTO provisioning_jobs (id, resource_id, status) VALUES ($1, $2, 'pending')", ulid.Make().String(), deploymentId); err != nil {
+		t.Fatalf("failed to create job: %v", err)
+	}
+	if _, err := destroyDeployment(ctx, discardLogger(), pool, fake, userId, "api", defaultEnvironment); !errors.Is(err, errDeploymentLocked) {
+		t.Fatalf("err = %v, want the delete turned away by the pending job", err)
+	}
+	if len(fake.deleted) > 0 {
+		t.Fatalf("deleted %v under another job's lock", fake.deleted)
+	}
+
+	// Once the lock is free the delete claims it for itself, and releases it when done
+	if _, err := pool.Exec(ctx, "UPDATE provisioning_jobs SET status = 'failed' WHERE resource_id = $1", deploymentId); err != nil {
+		t.Fatalf("failed to finish job: %v", err)
+	}
+	if _, err := destroyDeployment(ctx, discardLogger(), pool, fake, userId, "api", defaultEnvironment); err != nil {
+		t.Fatalf("destroyDeployment: %v", err)
+	}
+	var statuses []string
+	rows, err := pool.Query(ctx, "SELECT status FROM provisioning_jobs WHERE resource_id = $1 ORDER BY created_at", deploymentId)
+	if err != nil {
+		t.Fatalf("failed to read jobs: %v", err)
+	}
+	for rows.Next() {
+		var jobStatus string
+		rows.Scan(&jobStatus)
+		statuses = append(statuses, jobStatus)
+	}
+	if len(statuses) != 2 || statuses[1] != "succeeded" {
+		t.Errorf("job statuses = %v, want the delete's own job to have succeeded", statuses)
+	}
+}
@@ -0,0 +1,162 @@
+package deployments
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"regexp"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+	"google.golang.org/api/googleapi"
+	"google.golang.org/api/option"
+	runv1 "google.golang.org/api/run/v1"
+
+	"github.com/0p5dev/controller/internal/config"
+)
+
+var domainPattern = regexp.MustCompile(`^([a-z0-9]([-a-z0-9]{0,61}[a-z0-9])?\.)+[a-z]{2,63}$`)
+
+var errDomainAlreadyMapped = errors.New("domain is already mapped")
+
+type DomainMappingStatus struct {
+	Domain     string            `json:"domain"`
+	Region     string            `json:"region"`
+	Ready      bool              `json:"ready"`
+	Message    string            `json:"message,omitempty"`
+	DnsRecords []DomainDnsRecord `json:"dns_records"`
+}
+
+// DomainDnsRecord is a record the user must add at their DNS provider for the mapping to become ready
+type DomainDnsRecord struct {
+	Name string `json:"name"`
+	Type string `json:"type"`
+	Data string `json:"data"`
+}
+
+// Domain mappings are only available through the regional endpoints of the Cloud Run Admin API v1
+func newDomainMappingsClient(ctx context.Context, region string) (*runv1.NamespacesDomainmappingsService, error) {
+	service, err := runv1.NewService(ctx, option.WithEndpoint(fmt.Sprintf("https://%s-run.googleapis.com/", region)))
+	if err != nil {
+		return nil, err
+	}
+	return service.Namespaces.Domainmappings, nil
+}
+
+func domainMappingName(domain string) string {
+	return fmt.Sprintf("namespaces/%s/domainmappings/%s", config.Get().GcpProjectId, domain)
+}
+
+// createDomainMapping maps a domain to a deployment's service in the given region. A domain that Cloud Run has
+// already mapped, to this or any other service, is reported as errDomainAlreadyMapped.
+func createDomainMapping(ctx context.Context, region string, serviceId string, domain string) (*runv1.DomainMapping, error) {
+	client, err := newDomainMappingsClient(ctx, region)
+	if err != nil {
+		return nil, err
+	}
+
+	mapping := &runv1.DomainMapping{
+		ApiVersion: "domains.cloudrun.com/v1",
+		Kind:       "DomainMapping",
+		Metadata: &runv1.ObjectMeta{
+			Name:      domain,
+			Namespace: config.Get().GcpProjectId,
+			Labels:    map[string]string{"created_by": "0p5dev_controller"},
+		},
+		Spec: &runv1.DomainMappingSpec{
+			RouteName:       serviceId,
+			CertificateMode: "AUTOMATIC",
+		},
+	}
+
+	created, err := withTransientRetry(ctx, "CreateDomainMapping", func() (*runv1.DomainMapping, error) {
+		return client.Create("namespaces/"+config.Get().GcpProjectId, mapping).Context(ctx).Do()
+	})
+	var apiErr *googleapi.Error
+	if errors.As(err, &apiErr) && apiErr.Code == http.StatusConflict {
+		return nil, errDomainAlreadyMapped
+	}
+	return created, err
+}
+
+func getDomainMapping(ctx context.Context, region string, domain string) (*runv1.DomainMapping, error) {
+	client, err := newDomainMappingsClient(ctx, region)
+	if err != nil {
+		return nil, err
+	}
+	return withTransientRetry(ctx, "GetDomainMapping", func() (*runv1.DomainMapping, error) {
+		return client.Get(domainMappingName(domain)).Context(ctx).Do()
+	})
+}
+
+// deleteDomainMapping removes a domain mapping; one that no longer exists is not an error
+func deleteDomainMapping(ctx context.Context, region string, domain string) error {
+	client, err := newDomainMappingsClient(ctx, region)
+	if err != nil {
+		return err
+	}
+	_, err = withTransientRetry(ctx, "DeleteDomainMapping", func() (*runv1.Status, error) {
+		return client.Delete(domainMappingName(domain)).Context(ctx).Do()
+	})
+	var apiErr *googleapi.Error
+	if errors.As(err, &apiErr) && apiErr.Code == http.StatusNotFound {
+		return nil
+	}
+	return err
+}
+
+// deleteDeploymentDomainMappings removes every domain mapped to a deployment, in Cloud Run and in the database
+func deleteDeploymentDomainMappings(ctx context.Context, pool *pgxpool.Pool, deploymentId string) error {
+	rows, err := pool.Query(ctx, "SELECT domain, region FROM domain_mappings WHERE deployment_id = $1", deploymentId)
+	if err != nil {
+		return err
+	}
+	type mappedDomain struct{ domain, region string }
+	var mappedDomains []mappedDomain
+	for rows.Next() {
+		var mapped mappedDomain
+		if err := rows.Scan(&mapped.domain, &mapped.region); err != nil {
+			rows.Close()
+			return err
+		}
+		mappedDomains = append(mappedDomains, mapped)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	for _, mapped := range mappedDomains {
+		if err := deleteDomainMapping(ctx, mapped.region, mapped.domain); err != nil {
+			return fmt.Errorf("failed to remove domain mapping for %s: %w", mapped.domain, err)
+		}
+		if _, err := pool.Exec(ctx, "DELETE FROM domain_mappings WHERE domain = $1", mapped.domain); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// domainMappingStatus summarizes a mapping's readiness and the DNS records Cloud Run asks for. Records may be
+// empty right after creation, until Cloud Run has provisioned the mapping.
+func domainMappingStatus(domain string, region string, mapping *runv1.DomainMapping) DomainMappingStatus {
+	status := DomainMappingStatus{Domain: domain, Region: region, DnsRecords: []DomainDnsRecord{}}
+	if mapping == nil || mapping.Status == nil {
+		return status
+	}
+
+	for _, condition := range mapping.Status.Conditions {
+		if condition.Type == "Ready" {
+			status.Ready = condition.Status == "True"
+			status.Message = condition.Message
+		}
+	}
+	for _, record := range mapping.Status.ResourceRecords {
+		name := record.Name
+		if name == "" {
+			name = domain
+		}
+		status.DnsRecords = append(status.DnsRecords, DomainDnsRecord{Name: name, Type: record.Type, Data: record.Rrdata})
+	}
+	return status
+}
@@ -0,0 +1,76 @@
+package deployments
+
+import (
+	"fmt"
+	"strings"
+
+	runpb "cloud.google.com/go/run/apiv2/runpb"
+	"github.com/0p5dev/controller/internal/models"
+)
+
+// detectDrift compares the settings recorded for a deployment with the live Cloud Run service
+func detectDrift(deployment models.Deployment, service *runpb.Service) []DeploymentDriftItem {
+	drift := []DeploymentDriftItem{}
+	addDrift := func(field string, stored, live any) {
+		storedStr, liveStr := fmt.Sprint(stored), fmt.Sprint(live)
+		if storedStr != liveStr {
+			drift = append(drift, DeploymentDriftItem{Field: field, Stored: storedStr, Live: liveStr})
+		}
+	}
+
+	template := service.GetTemplate()
+	addDrift("min_instances", deployment.MinInstances, template.GetScaling().GetMinInstanceCount())
+	addDrift("max_instances", deployment.MaxInstances, template.GetScaling().GetMaxInstanceCount())
+	addDrift("max_concurrency", deployment.MaxConcurrency, template.GetMaxInstanceRequestConcurrency())
+	addDrift("request_timeout_seconds", deployment.RequestTimeoutSeconds, int(template.GetTimeout().AsDuration().Seconds()))
+
+	var container *runpb.Container
+	if len(template.GetContainers()) > 0 {
+		container = template.GetContainers()[0]
+	}
+	if deployment.ImageDigest != nil {
+		addDrift("image", *deployment.ImageDigest, container.GetImage())
+	}
+	// The primary port is only exposed when no sidecar receives traffic instead
+	if ingressSidecar(deployment.Sidecars) == nil {
+		var livePort int32
+		if len(container.GetPorts()) > 0 {
+			livePort = container.GetPorts()[0].GetContainerPort()
+		}
+		addDrift("port", deployment.Port, livePort)
+	}
+	addDrift("cpu_always_allocated", deployment.CpuAlwaysAllocated, !container.GetResources().GetCpuIdle())
+	addDrift("startup_cpu_boost", deployment.StartupCpuBoost, container.GetResources().GetStartupCpuBoost())
+
+	vpcAccess := template.GetVpcAccess()
+	addDrift("vpc_connector", stringOrEmpty(deployment.VpcConnector), vpcAccess.GetConnector())
+	if deployment.VpcEgress != nil {
+		addDrift("vpc_egress", *deployment.VpcEgress, strings.ReplaceAll(strings.ToLower(vpcAccess.GetEgress().String()), "_", "-"))
+	}
+
+	if deployment.ExecutionEnvironment != nil {
+		addDrift("execution_environment", *deployment.ExecutionEnvironment, strings.TrimPrefix(strings.ToLower(template.GetExecutionEnvironment().String()), "execution_environment_"))
+	}
+
+	// Unset ingress is applied as all, so it is compared as all
+	storedIngress := "all"
+	if deployment.Ingress != nil {
+		storedIngress = *deployment.Ingress
+	}
+	liveIngress := service.GetIngress().String()
+	for value, ingress := range ingressValues {
+		if ingress == service.GetIngress() {
+			liveIngress = value
+		}
+	}
+	addDrift("ingress", storedIngress, liveIngress)
+
+	return drift
+}
+
+func stringOrEmpty(value *string) string {
+	if value == nil {
+		return ""
+	}
+	return *value
+}
@@ -0,0 +1,41 @@
+package deployments
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	// defaultEnvironment is the environment of every deployment created before environments existed, and of any
+	// request that doesn't name one
+	defaultEnvironment = "default"
+	// The service id must fit Cloud Run's 49 character limit, so a name and its environment share the 20 characters
+	// a name alone may use
+	maxNameAndEnvironmentLength = 20
+)
+
+// validateEnvironment checks an environment name together with the deployment name it is for
+func validateEnvironment(deploymentName string, environment string) error {
+	if !dnsLabelPattern.MatchString(environment) {
+		return fmt.Errorf("environment %q must be lowercase letters, digits, and hyphens, starting with a letter", environment)
+	}
+	if environment != defaultEnvironment && len(deploymentName)+1+len(environment) > maxNameAndEnvironmentLength {
+		return fmt.Errorf("name and environment must be %d characters or less combined", maxNameAndEnvironmentLength-1)
+	}
+	return nil
+}
+
+// environmentParam reads the environment query param that selects which variant of a named deployment a request is
+// about. It responds with a 400 and returns false when the environment is invalid.
+func environmentParam(c *gin.Context) (string, bool) {
+	environment := c.DefaultQuery("environment", defaultEnvironment)
+	if !dnsLabelPattern.MatchString(environment) {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{
+			"error": "invalid environment " + environment,
+		})
+		return "", false
+	}
+	return environment, true
+}
@@ -0,0 +1,81 @@
+package deployments
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// respondWithETag sends a deployment's body as JSON with its deploymentETag, or a bodiless 304 when the client's
+// If-None-Match already has it
+func respondWithETag(c *gin.Context, version time.Time, body any) {
+	encoded, err := json.Marshal(body)
+	if err != nil {
+		c.JSON(http.StatusOK, body)
+		return
+	}
+	etag := deploymentETag(version, encoded)
+
+	c.Header("ETag", etag)
+	if etagMatches(c.GetHeader("If-None-Match"), etag) {
+		c.Status(http.StatusNotModified)
+		return
+	}
+	c.Data(http.StatusOK, "application/json; charset=utf-8", encoded)
+}
+
+// deploymentETag is the deployment's version (its updated_at), which If-Match checks an update against, followed by
+// a hash of the response. Hashing the response rather than only the stored row means a change on either side, the
+// deployment row or the live Cloud Run service, produces a new ETag.
+func deploymentETag(version time.Time, encoded []byte) string {
+	sum := sha256.Sum256(encoded)
+	return `"` + strconv.FormatInt(version.UnixMicro(), 10) + "." + hex.EncodeToString(sum[:16]) + `"`
+}
+
+// etagMatches uses the weak comparison If-None-Match calls for, so W/ prefixes are ignored
+func etagMatches(header string, etag string) bool {
+	for _, candidate := range strings.Split(header, ",") {
+		candidate = strings.TrimPrefix(strings.TrimSpace(candidate), "W/")
+		if candidate == "*" || candidate == etag {
+			return true
+		}
+	}
+	return false
+}
+
+// ifMatchVersion reads the deployment version a client last saw from If-Match: the ETag of a deployment response,
+// or the deployment's updated_at as an RFC 3339 timestamp, quoted or not. It returns nil when the header is absent or
+// "*", in which case the update is unconditional.
+func ifMatchVersion(c *gin.Context) (*time.Time, error) {
+	header := strings.TrimSpace(c.GetHeader("If-Match"))
+	if header == "" || header == "*" {
+		return nil, nil
+	}
+	value := strings.Trim(strings.TrimPrefix(header, "W/"), `"`)
+	if version, err := time.Parse(time.RFC3339Nano, value); err == nil {
+		return &version, nil
+	}
+	// Only the version of an ETag matters: the rest hashes live state an update doesn't depend on
+	if micros, _, ok := strings.Cut(value, "."); ok {
+		if unixMicro, err := strconv.ParseInt(micros, 10, 64); err == nil {
+			version := time.UnixMicro(unixMicro)
+			return &version, nil
+		}
+	}
+	return nil, errors.New("If-Match must be an ETag from GET /deployments/{name}, or the deployment's updated_at as an RFC 3339 timestamp")
+}
+
+// versionMismatchResult is the 412 for an update whose If-Match version is no longer the deployment's
+func versionMismatchResult(deploymentName string, currentVersion time.Time) deploymentResult {
+	return deploymentError(http.StatusPreconditionFailed, gin.H{
+		"error":      "deployment " + deploymentName + " has changed since it was read",
+		"updated_at": currentVersion,
+	})
+}
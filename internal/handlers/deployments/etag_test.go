@@ -0,0 +1,85 @@
+package deployments
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+func etagTestContext(header string, value string) (*gin.Context, *httptest.ResponseRecorder) {
+	gin.SetMode(gin.TestMode)
+	recorder := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(recorder)
+	c.Request = httptest.NewRequest(http.MethodGet, "/api/v1/deployments/api", nil)
+	if value != "" {
+		c.Request.Header.Set(header, value)
+	}
+	return c, recorder
+}
+
+func TestETagIsAcceptedByIfMatch(t *testing.T) {
+	version := time.Date(2026, 10, 15, 7, 30, 12, 123456000, time.UTC)
+	c, recorder := etagTestContext("If-None-Match", "")
+	respondWithETag(c, version, gin.H{"name": "api"})
+	etag := recorder.Header().Get("ETag")
+	if recorder.Code != http.StatusOK || etag == "" {
+		t.Fatalf("status = %d, ETag = %q; want a 200 with an ETag", recorder.Code, etag)
+	}
+
+	// A client echoes the ETag it received, as is or as a weak validator
+	for _, header := range []string{etag, "W/" + etag} {
+		c, _ := etagTestContext("If-Match", header)
+		expected, err := ifMatchVersion(c)
+		if err != nil {
+			t.Fatalf("If-Match %s: %v", header, err)
+		}
+		if expected == nil || !expected.Equal(version) {
+			t.Errorf("If-Match %s: version = %v, want %v", header, expected, version)
+		}
+	}
+}
+
+func TestETagChangesWithVersionAndBody(t *testing.T) {
+	version := time.Date(2026, 10, 15, 7, 30, 12, 0, time.UTC)
+	etag := deploymentETag(version, []byte(`{"status":"Ready"}`))
+	if deploymentETag(version.Add(time.Microsecond), []byte(`{"status":"Ready"}`)) == etag {
+		t.Error("ETag unchanged by a new version")
+	}
+	if deploymentETag(version, []byte(`{"status":"NotReady"}`)) == etag {
+		t.Error("ETag unchanged by a change to the live service")
+	}
+
+	c, _ := etagTestContext("If-None-Match", `W/`+deploymentETag(version, []byte(`{"status":"Ready"}`)))
+	respondWithETag(c, version, gin.H{"status": "Ready"})
+	if c.Writer.Status() != http.StatusNotModified {
+		t.Errorf("status = %d, want a 304 for a matching If-None-Match", c.Writer.Status())
+	}
+}
+
+func TestIfMatchVersion(t *testing.T) {
+	version := time.Date(2026, 10, 15, 7, 30, 12, 123456000, time.UTC)
+	for _, header := range []string{"2026-10-15T07:30:12.123456Z", `"2026-10-15T09:30:12.123456+02:00"`} {
+		c, _ := etagTestContext("If-Match", header)
+		expected, err := ifMatchVersion(c)
+		if err != nil || expected == nil || !expected.Equal(version) {
+			t.Errorf("If-Match %s: version = %v, %v; want %v", header, expected, err, version)
+		}
+	}
+
+	for _, header := range []string{"", "*"} {
+		c, _ := etagTestContext("If-Match", header)
+		if expected, err := ifMatchVersion(c); expected != nil || err != nil {
+			t.Errorf("If-Match %q: version = %v, %v; want an unconditional update", header, expected, err)
+		}
+	}
+
+	for _, header := range []string{`"yesterday"`, `"abc.0123"`, "12345"} {
+		c, _ := etagTestContext("If-Match", header)
+		if _, err := ifMatchVersion(c); err == nil {
+			t.Errorf("If-Match %s accepted, want an error", header)
+		}
+	}
+}
@@ -0,0 +1,49 @@
+package deployments
+
+import (
+	"log/slog"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/0p5dev/controller/internal/models"
+)
+
+// deploymentEventLog collects the Cloud Run steps of one create or update. Regions are provisioned in parallel,
+// so it is safe for concurrent use.
+type deploymentEventLog struct {
+	mu     sync.Mutex
+	logger *slog.Logger
+	events []models.DeploymentEvent
+}
+
+func newDeploymentEventLog(logger *slog.Logger) *deploymentEventLog {
+	return &deploymentEventLog{logger: logger, events: []models.DeploymentEvent{}}
+}
+
+// record logs a step that started at started and just finished with err
+func (eventLog *deploymentEventLog) record(region string, step string, started time.Time, err error) {
+	event := models.DeploymentEvent{
+		Region:     region,
+		Step:       step,
+		Status:     "succeeded",
+		StartedAt:  started.UTC(),
+		DurationMs: time.Since(started).Milliseconds(),
+	}
+	if err != nil {
+		event.Status = "failed"
+		event.Error = err.Error()
+	}
+
+	eventLog.mu.Lock()
+	eventLog.events = append(eventLog.events, event)
+	eventLog.mu.Unlock()
+
+	eventLog.logger.Info("Deployment step finished", "region", region, "step", step, "status", event.Status, "duration_ms", event.DurationMs)
+}
+
+func (eventLog *deploymentEventLog) snapshot() []models.DeploymentEvent {
+	eventLog.mu.Lock()
+	defer eventLog.mu.Unlock()
+	return slices.Clone(eventLog.events)
+}
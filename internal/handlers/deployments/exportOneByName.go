@@ -0,0 +1,68 @@
+package deployments
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5/pgxpool"
+
+	"github.com/0p5dev/controller/internal/sharedUtils"
+)
+
+// @Summary Export a deployment's configuration
+// @Description Return the deployment as a create request body, so it can be recreated with POST /deployments elsewhere or kept in source control. Callback and health check settings aren't stored and are left out.
+// @Tags deployments
+// @Produce json
+// @Security BearerAuth
+// @Param name path string true "Deployment name"
+// @Param environment query string false "Deployment environment (default: default)"
+// @Success 200 {object} CreateOneRequestBody "Deployment spec"
+// @Failure 401 {object} map[string]string "Unauthorized"
+// @Failure 404 {object} map[string]string "Deployment not found"
+// @Router /deployments/{name}/export [get]
+func ExportOneByName(c *gin.Context) {
+	userClaims := c.MustGet("UserClaims").(*sharedUtils.UserClaims)
+	pool := c.MustGet("Pool").(*pgxpool.Pool)
+
+	deploymentName := c.Param("name")
+	environment, ok := environmentParam(c)
+	if !ok {
+		return
+	}
+
+	deployment, err := scanDeployment(pool.QueryRow(c.Request.Context(), "SELECT "+deploymentColumns+" FROM deployments WHERE name = $1 AND user_id = $2 AND environment = $3 AND deleted_at IS NULL", deploymentName, userClaims.UserMetadata.AppUser.Id, environment))
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusNotFound, gin.H{
+			"error": "deployment " + deploymentName + " not found",
+		})
+		return
+	}
+
+	spec := CreateOneRequestBody{
+		Name:                  deployment.Name,
+		Environment:           deployment.Environment,
+		ContainerImage:        deployment.ContainerImage,
+		MinInstances:          &deployment.MinInstances,
+		MaxInstances:          &deployment.MaxInstances,
+		Port:                  &deployment.Port,
+		CpuAlwaysAllocated:    deployment.CpuAlwaysAllocated,
+		StartupCpuBoost:       deployment.StartupCpuBoost,
+		MaxConcurrency:        &deployment.MaxConcurrency,
+		RequestTimeoutSeconds: &deployment.RequestTimeoutSeconds,
+		VpcConnector:          deployment.VpcConnector,
+		VpcNetwork:            deployment.VpcNetwork,
+		VpcSubnet:             deployment.VpcSubnet,
+		VpcEgress:             deployment.VpcEgress,
+		Sidecars:              deployment.Sidecars,
+		ExecutionEnvironment:  deployment.ExecutionEnvironment,
+		Volumes:               deployment.Volumes,
+		Ingress:               deployment.Ingress,
+		Cpu:                   deployment.Cpu,
+		Memory:                deployment.Memory,
+		Version:               deployment.Version,
+		DeletionProtection:    &deployment.DeletionProtection,
+		Regions:               deploymentRegions(deployment),
+	}
+
+	c.JSON(http.StatusOK, spec)
+}
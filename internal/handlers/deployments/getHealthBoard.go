@@ -0,0 +1,123 @@
+package deployments
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5/pgxpool"
+
+	"github.com/0p5dev/controller/internal/sharedUtils"
+)
+
+// DeploymentHealth is the outcome of probing one deployment's URL
+type DeploymentHealth struct {
+	Status     string `json:"status"`                // healthy | unreachable
+	HttpStatus int    `json:"http_status,omitempty"` // status the service answered with, if it answered
+}
+
+type HealthBoardResponse struct {
+	Deployments map[string]DeploymentHealth `json:"deployments"` // keyed by deployment name
+	CheckedAt   time.Time                   `json:"checked_at"`
+}
+
+// healthBoards caches each user's latest board per environment, so dashboard refreshes don't probe every service
+var healthBoards = struct {
+	sync.Mutex
+	byKey map[[2]string]HealthBoardResponse
+}{byKey: map[[2]string]HealthBoardResponse{}}
+
+// @Summary Check the health of all deployments
+// @Description Probe the URL of each of the caller's deployments in an environment and report which ones answer. Results are cached for DEPLOYMENT_HEALTH_CACHE_SECONDS (default 15).
+// @Tags deployments
+// @Produce json
+// @Security BearerAuth
+// @Param environment query string false "Deployment environment (default: default)"
+// @Success 200 {object} HealthBoardResponse "Health by deployment name"
+// @Failure 400 {object} map[string]string "Invalid environment"
+// @Failure 401 {object} map[string]string "Unauthorized"
+// @Failure 500 {object} map[string]string "Failed to list deployments"
+// @Router /deployments/health [get]
+func GetHealthBoard(c *gin.Context) {
+	userClaims := c.MustGet("UserClaims").(*sharedUtils.UserClaims)
+	pool := c.MustGet("Pool").(*pgxpool.Pool)
+	logger := c.MustGet("Logger").(*slog.Logger)
+	reqCtx := c.Request.Context()
+
+	environment, ok := environmentParam(c)
+	if !ok {
+		return
+	}
+	userId := userClaims.UserMetadata.AppUser.Id
+	cacheKey := [2]string{userId, environment}
+
+	cacheWindow := time.Duration(sharedUtils.GetEnvInt("DEPLOYMENT_HEALTH_CACHE_SECONDS", 15)) * time.Second
+	healthBoards.Lock()
+	cached, found := healthBoards.byKey[cacheKey]
+	healthBoards.Unlock()
+	if found && time.Since(cached.CheckedAt) < cacheWindow {
+		c.JSON(http.StatusOK, cached)
+		return
+	}
+
+	rows, err := pool.Query(reqCtx, "SELECT name, url FROM deployments WHERE user_id = $1 AND environment = $2 AND deleted_at IS NULL", userId, environment)
+	if err != nil {
+		logger.Error("Failed to list deployments for health check", "user_id", userId, "error", err)
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{
+			"error": "failed to list deployments",
+		})
+		return
+	}
+	urls := map[string]string{}
+	for rows.Next() {
+		var name, url string
+		if err := rows.Scan(&name, &url); err == nil {
+			urls[name] = url
+		}
+	}
+	rows.Close()
+
+	concurrency := sharedUtils.GetEnvInt("DEPLOYMENT_HEALTH_CONCURRENCY", 8)
+	if concurrency < 1 {
+		concurrency = 1
+	}
+	timeout := time.Duration(sharedUtils.GetEnvInt("DEPLOYMENT_HEALTH_TIMEOUT_SECONDS", 3)) * time.Second
+
+	board := HealthBoardResponse{Deployments: map[string]DeploymentHealth{}, CheckedAt: time.Now().UTC()}
+	var boardMu sync.Mutex
+	semaphore := make(chan struct{}, concurrency)
+	var wg sync.WaitGroup
+	for name, url := range urls {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			semaphore <- struct{}{}
+			defer func() { <-semaphore }()
+
+			health := DeploymentHealth{Status: "unreachable"}
+			if url != unavailableServiceUrl {
+				probeCtx, cancel := context.WithTimeout(reqCtx, timeout)
+				statusCode, err := probeUrl(probeCtx, url)
+				cancel()
+				health.HttpStatus = statusCode
+				if err == nil && statusCode < 400 {
+					health.Status = "healthy"
+				}
+			}
+
+			boardMu.Lock()
+			board.Deployments[name] = health
+			boardMu.Unlock()
+		}()
+	}
+	wg.Wait()
+
+	healthBoards.Lock()
+	healthBoards.byKey[cacheKey] = board
+	healthBoards.Unlock()
+
+	c.JSON(http.StatusOK, board)
+}
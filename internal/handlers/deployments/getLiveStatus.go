@@ -0,0 +1,124 @@
+package deployments
+
+import (
+	"context"
+	"net/http"
+	"path"
+	"time"
+
+	runpb "cloud.google.com/go/run/apiv2/runpb"
+	"github.com/0p5dev/controller/internal/sharedUtils"
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+type DeploymentLiveStatus struct {
+	Name                  string                `json:"name"`
+	Ready                 bool                  `json:"ready"`
+	Condition             *ServiceCondition     `json:"condition,omitempty"`
+	LatestReadyRevision   string                `json:"latest_ready_revision"`
+	LatestCreatedRevision string                `json:"latest_created_revision"`
+	Image                 string                `json:"image"`
+	MinInstances          int32                 `json:"min_instances"`
+	MaxInstances          int32                 `json:"max_instances"`
+	LastDeployedAt        string                `json:"last_deployed_at"`
+	Drifted               bool                  `json:"drifted"`
+	Drift                 []DeploymentDriftItem `json:"drift"`
+}
+
+type ServiceCondition struct {
+	State   string `json:"state"`
+	Reason  string `json:"reason,omitempty"`
+	Message string `json:"message,omitempty"`
+}
+
+// DeploymentDriftItem is a setting whose live Cloud Run value no longer matches what the controller recorded,
+// usually because it was changed by hand in the GCP console
+type DeploymentDriftItem struct {
+	Field  string `json:"field"`
+	Stored string `json:"stored"`
+	Live   string `json:"live"`
+}
+
+// @Summary Get live deployment status
+// @Description Read the deployed Cloud Run service and report its live state, flagging settings that have drifted from the stored deployment
+// @Tags deployments
+// @Produce json
+// @Security BearerAuth
+// @Param name path string true "Deployment name"
+// @Param environment query string false "Deployment environment (default: default)"
+// @Success 200 {object} DeploymentLiveStatus "Live deployment status"
+// @Failure 401 {object} map[string]string "Unauthorized"
+// @Failure 404 {object} map[string]string "Deployment or Cloud Run service not found"
+// @Failure 500 {object} map[string]string "Failed to read Cloud Run service"
+// @Router /deployments/{name}/status [get]
+func GetLiveStatus(c *gin.Context) {
+	userClaims := c.MustGet("UserClaims").(*sharedUtils.UserClaims)
+	pool := c.MustGet("Pool").(*pgxpool.Pool)
+
+	deploymentName := c.Param("name")
+	environment, ok := environmentParam(c)
+	if !ok {
+		return
+	}
+
+	deployment, err := scanDeployment(pool.QueryRow(c.Request.Context(), "SELECT "+deploymentColumns+" FROM deployments WHERE name = $1 AND user_id = $2 AND environment = $3 AND deleted_at IS NULL", deploymentName, userClaims.UserMetadata.AppUser.Id, environment))
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusNotFound, gin.H{
+			"error": "deployment " + deploymentName + " not found",
+		})
+		return
+	}
+
+	ctx := context.Background()
+
+	servicesClient, err := newCloudRunServices(ctx)
+	if err != nil {
+		abortWithCloudRunError(c, &CloudRunError{Operation: "NewServicesClient", Err: err})
+		return
+	}
+	defer servicesClient.Close()
+
+	// Multi-region deployments are checked in their primary region
+	serviceName := regionalServiceName(primaryRegion(deployment), deployment.Id)
+	service, err := withTransientRetry(ctx, "GetService", func() (*runpb.Service, error) {
+		return servicesClient.GetService(ctx, serviceName)
+	})
+	if err != nil {
+		abortWithCloudRunError(c, &CloudRunError{Operation: "GetService", Service: serviceName, Err: err})
+		return
+	}
+
+	status := DeploymentLiveStatus{
+		Name:                  deploymentName,
+		LatestReadyRevision:   path.Base(service.LatestReadyRevision),
+		LatestCreatedRevision: path.Base(service.LatestCreatedRevision),
+		LastDeployedAt:        service.UpdateTime.AsTime().Format(time.RFC3339),
+	}
+
+	if condition := service.TerminalCondition; condition != nil {
+		status.Ready = condition.State == runpb.Condition_CONDITION_SUCCEEDED
+		status.Condition = &ServiceCondition{
+			State:   condition.State.String(),
+			Message: condition.Message,
+		}
+		if reason := condition.GetReason(); reason != runpb.Condition_COMMON_REASON_UNDEFINED {
+			status.Condition.Reason = reason.String()
+		}
+	}
+
+	if service.Template != nil {
+		if len(service.Template.Containers) > 0 {
+			status.Image = service.Template.Containers[0].Image
+		}
+		if service.Template.Scaling != nil {
+			status.MinInstances = service.Template.Scaling.MinInstanceCount
+			status.MaxInstances = service.Template.Scaling.MaxInstanceCount
+		}
+	}
+
+	status.Drift = detectDrift(deployment, service)
+	status.Drifted = len(status.Drift) > 0
+
+	c.JSON(http.StatusOK, status)
+}
@@ -1,11 +1,14 @@
 package deployments
 
 import (
+	"encoding/base64"
+	"errors"
 	"fmt"
 	"log/slog"
 	"net/http"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/jackc/pgx/v5/pgxpool"
@@ -17,23 +20,84 @@ import (
 type PaginatedDeploymentsResponse struct {
 	Deployments []models.Deployment `json:"deployments"`
 	Count       int                 `json:"count"`
-	Page        int                 `json:"page"`
+	Page        int                 `json:"page,omitempty"`
 	Limit       int                 `json:"limit"`
 	TotalPages  int                 `json:"total_pages"`
+	Sort        string              `json:"sort"`
+	Order       string              `json:"order"`
+	NextCursor  string              `json:"next_cursor,omitempty"`
 }
 
+// deploymentCursor is the last row of a keyset-paginated page
+type deploymentCursor struct {
+	CreatedAt time.Time
+	Id        string
+}
+
+// Cursors are opaque to clients: base64url of "<created_at RFC3339Nano>|<id>"
+func encodeDeploymentCursor(deployment models.Deployment) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(deployment.CreatedAt.UTC().Format(time.RFC3339Nano) + "|" + deployment.Id))
+}
+
+func decodeDeploymentCursor(cursor string) (deploymentCursor, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return deploymentCursor{}, errors.New("invalid cursor")
+	}
+	createdAtStr, id, found := strings.Cut(string(raw), "|")
+	if !found || id == "" {
+		return deploymentCursor{}, errors.New("invalid cursor")
+	}
+	createdAt, err := time.Parse(time.RFC3339Nano, createdAtStr)
+	if err != nil {
+		return deploymentCursor{}, errors.New("invalid cursor")
+	}
+	return deploymentCursor{CreatedAt: createdAt, Id: id}, nil
+}
+
+// Column names are interpolated into the ORDER BY clause, so only allowlisted values may be used
+var sortableDeploymentColumns = map[string]bool{
+	"name":       true,
+	"created_at": true,
+	"updated_at": true,
+}
+
+var filterableDeploymentStatuses = map[string]bool{
+	"pending":   true,
+	"succeeded": true,
+	"failed":    true,
+}
+
+// latestJobStatusExpr resolves a deployment's status from its most recent provisioning job
+const latestJobStatusExpr = "(SELECT status FROM provisioning_jobs WHERE resource_id = deployments.id ORDER BY created_at DESC LIMIT 1)"
+
 // @Summary List deployments
 // @Description Get a paginated list of deployments for the authenticated user
 // @Tags deployments
 // @Produce json
 // @Security BearerAuth
-// @Param page query int false "Page number (default: 1)"
-// @Param limit query int false "Items per page (default: 10, max: 100)"
-// @Param search query string false "Search in name, url, and container_image"
+// @Param page query int false "Page number (default: 1), ignored in cursor mode"
+// @Param limit query int false "Items per page (default: 10, max: MAX_PAGE_SIZE, default 100)"
+// @Param search query string false "Search in name, url, container_image, and version"
+// @Param status query string false "Filter by status of the latest provisioning job: pending, succeeded, or failed"
+// @Param created_after query string false "Only deployments created at or after this RFC3339 timestamp"
+// @Param created_before query string false "Only deployments created before this RFC3339 timestamp"
+// @Param environment query string false "Only list deployments in this environment"
+// @Param include_deleted query bool false "Include soft-deleted deployments (admin only)"
+// @Param user_id query string false "List this user's deployments instead of the caller's (admin only)"
+// @Param sort query string false "Sort column: name, created_at, or updated_at (default: created_at)"
+// @Param order query string false "Sort order: asc or desc (default: desc)"
+// @Param count_only query bool false "Only return {\"count\": N} for the filters, without any rows"
+// @Param cursor query string false "Use keyset pagination instead of pages: pass an empty cursor for the first page, then each response's next_cursor. Requires sort=created_at."
 // @Success 200 {object} api.PaginatedDeploymentsResponse "Paginated list of deployments"
+// @Failure 400 {object} map[string]string "Invalid sort, order, status, date filter, or cursor, or limit above the maximum page size"
 // @Failure 401 {object} map[string]string "Unauthorized"
+// @Failure 403 {object} map[string]string "include_deleted or user_id requires admin"
 // @Failure 500 {object} map[string]string "Failed to retrieve deployments"
+// @Header 200 {integer} X-Total-Count "Number of deployments matching the filters (HEAD requests)"
+// @Header 200 {string} Link "RFC 8288 first, prev, next, and last page links (first and next in cursor mode)"
 // @Router /deployments [get]
+// @Router /deployments [head]
 func GetMany(c *gin.Context) {
 	userClaims := c.MustGet("UserClaims").(*sharedUtils.UserClaims)
 	pool := c.MustGet("Pool").(*pgxpool.Pool)
@@ -49,34 +113,167 @@ func GetMany(c *gin.Context) {
 		page = 1
 	}
 
+	// An unreadable limit falls back to the default, but one above the max is rejected rather than quietly shrunk,
+	// so a client paging with a fixed size finds out it won't get that many rows
+	maxLimit := sharedUtils.GetEnvInt("MAX_PAGE_SIZE", 100)
 	limit, err := strconv.Atoi(limitStr)
-	if err != nil || limit < 1 || limit > 100 {
-		limit = 10 // Default limit with max of 100
+	if err != nil || limit < 1 {
+		limit = 10
+	}
+	if limit > maxLimit {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{
+			"error": fmt.Sprintf("limit %d exceeds the maximum page size of %d", limit, maxLimit),
+		})
+		return
 	}
 
 	offset := (page - 1) * limit
 
-	// Parse search parameters
+	// Parse sorting parameters
+	sort := strings.ToLower(c.DefaultQuery("sort", "created_at"))
+	if !sortableDeploymentColumns[sort] {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{
+			"error": "invalid sort column " + sort + ", must be one of name, created_at, updated_at",
+		})
+		return
+	}
+
+	order := strings.ToLower(c.DefaultQuery("order", "desc"))
+	if order != "asc" && order != "desc" {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{
+			"error": "invalid sort order " + order + ", must be asc or desc",
+		})
+		return
+	}
+
+	// Any cursor param, even an empty one for the first page, switches to keyset pagination on (created_at, id),
+	// which doesn't skip or repeat rows when deployments change between pages
+	cursorStr, cursorMode := c.GetQuery("cursor")
+	var cursor *deploymentCursor
+	if cursorMode {
+		if sort != "created_at" {
+			c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{
+				"error": "cursor pagination only supports sort=created_at",
+			})
+			return
+		}
+		if cursorStr != "" {
+			parsed, err := decodeDeploymentCursor(cursorStr)
+			if err != nil {
+				c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{
+					"error": err.Error(),
+				})
+				return
+			}
+			cursor = &parsed
+		}
+	}
+
+	// Parse search and filter parameters
 	search := c.Query("search")
 
+	status := strings.ToLower(c.Query("status"))
+	if status != "" && !filterableDeploymentStatuses[status] {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{
+			"error": "invalid status " + status + ", must be one of pending, succeeded, failed",
+		})
+		return
+	}
+
+	// Without an environment param, deployments in every environment are listed
+	environment := c.Query("environment")
+	if environment != "" && !dnsLabelPattern.MatchString(environment) {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{
+			"error": "invalid environment " + environment,
+		})
+		return
+	}
+
+	var createdAfter, createdBefore *time.Time
+	if createdAfterStr := c.Query("created_after"); createdAfterStr != "" {
+		parsed, err := time.Parse(time.RFC3339, createdAfterStr)
+		if err != nil {
+			c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{
+				"error": "invalid created_after, must be an RFC3339 timestamp",
+			})
+			return
+		}
+		createdAfter = &parsed
+	}
+	if createdBeforeStr := c.Query("created_before"); createdBeforeStr != "" {
+		parsed, err := time.Parse(time.RFC3339, createdBeforeStr)
+		if err != nil {
+			c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{
+				"error": "invalid created_before, must be an RFC3339 timestamp",
+			})
+			return
+		}
+		createdBefore = &parsed
+	}
+
+	includeDeleted := c.Query("include_deleted") == "true"
+	if includeDeleted && !sharedUtils.IsAdmin(userClaims) {
+		c.AbortWithStatusJSON(http.StatusForbidden, gin.H{
+			"error": "include_deleted is only available to admins",
+		})
+		return
+	}
+
+	// Admins can list another user's deployments, e.g. to audit what they deleted
+	userId := c.DefaultQuery("user_id", userClaims.UserMetadata.AppUser.Id)
+	if userId != userClaims.UserMetadata.AppUser.Id && !sharedUtils.IsAdmin(userClaims) {
+		c.AbortWithStatusJSON(http.StatusForbidden, gin.H{
+			"error": "user_id is only available to admins",
+		})
+		return
+	}
+
 	// Build dynamic WHERE clause and args
 	var whereConditions []string
 	var args []interface{}
 	argIndex := 1
 
-	// Always filter by authenticated user's deployments (users can only see their own)
+	// Always filter by one user's deployments: the caller's own, unless an admin asked for another user's
 	whereConditions = append(whereConditions, fmt.Sprintf("user_id = $%d", argIndex))
-	args = append(args, userClaims.UserMetadata.AppUser.Id)
+	args = append(args, userId)
 	argIndex++
 
-	// Add search filter (searches across name, url, and container_image)
+	if !includeDeleted {
+		whereConditions = append(whereConditions, "deleted_at IS NULL")
+	}
+
+	if environment != "" {
+		whereConditions = append(whereConditions, fmt.Sprintf("environment = $%d", argIndex))
+		args = append(args, environment)
+		argIndex++
+	}
+
+	// Add search filter (searches across name, url, container_image, and version)
 	if search != "" {
 		searchPattern := "%" + strings.ToLower(search) + "%"
-		whereConditions = append(whereConditions, fmt.Sprintf("(LOWER(name) LIKE $%d OR LOWER(url) LIKE $%d OR LOWER(container_image) LIKE $%d)", argIndex, argIndex, argIndex))
+		whereConditions = append(whereConditions, fmt.Sprintf("(LOWER(name) LIKE $%d OR LOWER(url) LIKE $%d OR LOWER(container_image) LIKE $%d OR LOWER(version) LIKE $%d)", argIndex, argIndex, argIndex, argIndex))
 		args = append(args, searchPattern)
 		argIndex++
 	}
 
+	if status != "" {
+		whereConditions = append(whereConditions, fmt.Sprintf("%s = $%d", latestJobStatusExpr, argIndex))
+		args = append(args, status)
+		argIndex++
+	}
+
+	if createdAfter != nil {
+		whereConditions = append(whereConditions, fmt.Sprintf("created_at >= $%d", argIndex))
+		args = append(args, *createdAfter)
+		argIndex++
+	}
+
+	if createdBefore != nil {
+		whereConditions = append(whereConditions, fmt.Sprintf("created_at < $%d", argIndex))
+		args = append(args, *createdBefore)
+		argIndex++
+	}
+
 	whereClause := ""
 	if len(whereConditions) > 0 {
 		whereClause = "WHERE " + strings.Join(whereConditions, " AND ")
@@ -94,16 +291,53 @@ func GetMany(c *gin.Context) {
 		return
 	}
 
+	// Clients that only need the total (e.g. a badge) can skip fetching and scanning rows
+	if c.Request.Method == http.MethodHead {
+		c.Header("X-Total-Count", strconv.Itoa(totalCount))
+		c.Status(http.StatusOK)
+		return
+	}
+	if c.Query("count_only") == "true" {
+		c.JSON(http.StatusOK, gin.H{
+			"count": totalCount,
+		})
+		return
+	}
+
 	// Get deployments with pagination
-	query := fmt.Sprintf(`
-		SELECT id, name, url, container_image, user_id, min_instances, max_instances, port, created_at, updated_at FROM deployments
-		%s
-		ORDER BY name ASC
-		LIMIT $%d OFFSET $%d
-	`, whereClause, argIndex, argIndex+1)
+	var query string
+	if cursorMode {
+		direction := strings.ToUpper(order)
+		keysetClause := whereClause
+		if cursor != nil {
+			comparison := "<"
+			if order == "asc" {
+				comparison = ">"
+			}
+			keysetClause += fmt.Sprintf(" AND (created_at, id) %s ($%d, $%d)", comparison, argIndex, argIndex+1)
+			args = append(args, cursor.CreatedAt, cursor.Id)
+			argIndex += 2
+		}
+		query = fmt.Sprintf(`
+			SELECT %s FROM deployments
+			%s
+			ORDER BY created_at %s, id %s
+			LIMIT $%d
+		`, deploymentColumns, keysetClause, direction, direction, argIndex)
+
+		// Fetch one extra row to know whether there is a next page
+		args = append(args, limit+1)
+	} else {
+		query = fmt.Sprintf(`
+			SELECT %s FROM deployments
+			%s
+			ORDER BY %s %s, id ASC
+			LIMIT $%d OFFSET $%d
+		`, deploymentColumns, whereClause, sort, strings.ToUpper(order), argIndex, argIndex+1)
 
-	// Add limit and offset to args
-	args = append(args, limit, offset)
+		// Add limit and offset to args
+		args = append(args, limit, offset)
+	}
 
 	rows, err := pool.Query(ctx, query, args...)
 	if err != nil {
@@ -117,19 +351,7 @@ func GetMany(c *gin.Context) {
 
 	deployments := []models.Deployment{}
 	for rows.Next() {
-		var deployment models.Deployment
-		err := rows.Scan(
-			&deployment.Id,
-			&deployment.Name,
-			&deployment.Url,
-			&deployment.ContainerImage,
-			&deployment.UserId,
-			&deployment.MinInstances,
-			&deployment.MaxInstances,
-			&deployment.Port,
-			&deployment.CreatedAt,
-			&deployment.UpdatedAt,
-		)
+		deployment, err := scanDeployment(rows)
 		if err != nil {
 			slog.Error("Error scanning deployment row", "error", err)
 			c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{
@@ -151,6 +373,15 @@ func GetMany(c *gin.Context) {
 	// Calculate total pages
 	totalPages := (totalCount + limit - 1) / limit // Ceiling division
 
+	nextCursor := ""
+	if cursorMode {
+		page = 0
+		if len(deployments) > limit {
+			deployments = deployments[:limit]
+			nextCursor = encodeDeploymentCursor(deployments[limit-1])
+		}
+	}
+
 	// Build response
 	response := PaginatedDeploymentsResponse{
 		Deployments: deployments,
@@ -158,7 +389,15 @@ func GetMany(c *gin.Context) {
 		Page:        page,
 		Limit:       limit,
 		TotalPages:  totalPages,
+		Sort:        sort,
+		Order:       order,
+		NextCursor:  nextCursor,
 	}
 
+	if cursorMode {
+		sharedUtils.SetCursorLinks(c, nextCursor)
+	} else {
+		sharedUtils.SetPageLinks(c, page, totalPages)
+	}
 	c.JSON(http.StatusOK, response)
 }
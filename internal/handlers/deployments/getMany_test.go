@@ -0,0 +1,180 @@
+package deployments
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5/pgxpool"
+
+	"github.com/0p5dev/controller/internal/models"
+	"github.com/0p5dev/controller/internal/sharedUtils"
+)
+
+func TestDeploymentCursorRoundTrip(t *testing.T) {
+	deployment := models.Deployment{
+		Id:        "api-default-01J9Z3Q8X7",
+		CreatedAt: time.Date(2026, 10, 15, 7, 30, 12, 123456789, time.FixedZone("CEST", 2*60*60)),
+	}
+
+	cursor, err := decodeDeploymentCursor(encodeDeploymentCursor(deployment))
+	if err != nil {
+		t.Fatalf("decodeDeploymentCursor: %v", err)
+	}
+	if cursor.Id != deployment.Id || !cursor.CreatedAt.Equal(deployment.CreatedAt) {
+		t.Errorf("cursor = %+v, want %s at %s", cursor, deployment.Id, deployment.CreatedAt)
+	}
+}
+
+func TestDecodeDeploymentCursorRejectsGarbage(t *testing.T) {
+	for _, cursor := range []string{
+		"not base64!",
+		base64.RawURLEncoding.EncodeToString([]byte("no separator")),
+		base64.RawURLEncoding.EncodeToString([]byte("2026-10-15T07:30:12Z|")),
+		base64.RawURLEncoding.EncodeToString([]byte("yesterday|api-default-u1")),
+	} {
+		if _, err := decodeDeploymentCursor(cursor); err == nil {
+			t.Errorf("decodeDeploymentCursor(%q) succeeded, want an error", cursor)
+		}
+	}
+}
+
+// serveGetMany calls GetMany as user with the given query string
+func serveGetMany(pool *pgxpool.Pool, user models.User, query string) *httptest.ResponseRecorder {
+	gin.SetMode(gin.TestMode)
+	recorder := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(recorder)
+	c.Request = httptest.NewRequest(http.MethodGet, "/api/v1/deployments?"+query, nil)
+	c.Set("Pool", pool)
+	c.Set("UserClaims", &sharedUtils.UserClaims{OauthClaims: sharedUtils.OauthClaims{
+		UserMetadata: sharedUtils.UserMetadata{AppUser: &user},
+	}})
+
+	GetMany(c)
+	return recorder
+}
+
+// listDeployments calls GetMany as userId with the given query string
+func listDeployments(t *testing.T, pool *pgxpool.Pool, userId string, query string) PaginatedDeploymentsResponse {
+	t.Helper()
+	return listDeploymentsAs(t, pool, models.User{Id: userId}, query)
+}
+
+func listDeploymentsAs(t *testing.T, pool *pgxpool.Pool, user models.User, query string) PaginatedDeploymentsResponse {
+	t.Helper()
+	recorder := serveGetMany(pool, user, query)
+	if recorder.Code != http.StatusOK {
+		t.Fatalf("GET ?%s: status = %d: %s", query, recorder.Code, recorder.Body.String())
+	}
+	var response PaginatedDeploymentsResponse
+	if err := json.Unmarshal(recorder.Body.Bytes(), &response); err != nil {
+		t.Fatalf("GET ?%s: invalid response: %v", query, err)
+	}
+	return response
+}
+
+func deploymentIds(deployments []models.Deployment) []string {
+	var ids []string
+	for _, deployment := range deployments {
+		ids = append(ids, deployment.Id)
+	}
+	return ids
+}
+
+func TestCursorAndOffsetPaginationAgree(t *testing.T) {
+	pool := testPool(t)
+	ctx := context.Background()
+	userId := createTestUser(t, pool)
+	image := "us-docker.pkg.dev/project/repo/" + userId + ":v1"
+	createTestImage(t, pool, userId, image)
+
+	// Pairs of deployments share a created_at, so the id tiebreak decides their order
+	base := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	for i := range 7 {
+		name := fmt.Sprintf("app%d", i)
+		createdAt := base.Add(time.Duration(i/2) * time.Hour)
+		_, err := pool.Exec(ctx, "INSERT INTO deployments (id, name, url, container_image, user_id, created_at) VALUES ($1, $2, '', $3, $4, $5)", deploymentServiceId(name, defaultEnvironment, userId), name, image, userId, createdAt)
+		if err != nil {
+			t.Fatalf("failed to create deployment: %v", err)
+		}
+	}
+
+	for _, order := range []string{"desc", "asc"} {
+		var offsetIds []string
+		for page := 1; ; page++ {
+			response := listDeployments(t, pool, userId, fmt.Sprintf("limit=3&order=%s&page=%d", order, page))
+			offsetIds = append(offsetIds, deploymentIds(response.Deployments)...)
+			if page >= response.TotalPages {
+				break
+			}
+		}
+
+		var cursorIds []string
+		cursor := ""
+		for range 10 {
+			response := listDeployments(t, pool, userId, fmt.Sprintf("limit=3&order=%s&cursor=%s", order, cursor))
+			cursorIds = append(cursorIds, deploymentIds(response.Deployments)...)
+			if cursor = response.NextCursor; cursor == "" {
+				break
+			}
+		}
+
+		if len(offsetIds) != 7 || !slices.Equal(cursorIds, offsetIds) {
+			t.Errorf("order %s: cursor pages %v, offset pages %v; want the same 7 deployments in the same order", order, cursorIds, offsetIds)
+		}
+	}
+}
+
+func TestListingAnotherUsersDeploymentsRequiresAdmin(t *testing.T) {
+	t.Setenv("ADMIN_EMAILS", "admin@example.com")
+	user := models.User{Id: "01j9z3q8x7user", Email: "user@example.com"}
+
+	for _, query := range []string{"user_id=01j9z3q8x7other", "include_deleted=true"} {
+		if code := serveGetMany(nil, user, query).Code; code != http.StatusForbidden {
+			t.Errorf("GET ?%s as a user: status = %d, want 403", query, code)
+		}
+	}
+}
+
+func TestAdminListsAnotherUsersDeletedDeployments(t *testing.T) {
+	pool := testPool(t)
+	ctx := context.Background()
+	userId := createTestUser(t, pool)
+	image := "us-docker.pkg.dev/project/repo/" + userId + ":v1"
+	createTestImage(t, pool, userId, image)
+	for _, name := range []string{"live", "gone"} {
+		_, err := pool.Exec(ctx, "INSERT INTO deployments (id, name, url, container_image, user_id) VALUES ($1, $2, '', $3, $4)", deploymentServiceId(name, defaultEnvironment, userId), name, image, userId)
+		if err != nil {
+			t.Fatalf("failed to create deployment: %v", err)
+		}
+	}
+	if _, err := pool.Exec(ctx, "UPDATE deployments SET deleted_at = NOW() WHERE id = $1", deploymentServiceId("gone", defaultEnvironment, userId)); err != nil {
+		t.Fatalf("failed to delete deployment: %v", err)
+	}
+
+	admin := models.User{Id: createTestUser(t, pool), Email: "admin@example.com"}
+	t.Setenv("ADMIN_EMAILS", admin.Email)
+
+	response := listDeploymentsAs(t, pool, admin, "include_deleted=true&sort=name&order=asc&user_id="+userId)
+	if names := deploymentNames(response.Deployments); !slices.Equal(names, []string{"gone", "live"}) {
+		t.Errorf("deployments = %v, want the user's live and deleted deployments", names)
+	}
+	if own := listDeploymentsAs(t, pool, admin, "include_deleted=true"); own.Count != 0 {
+		t.Errorf("admin's own list has %d deployments, want none without user_id", own.Count)
+	}
+}
+
+func deploymentNames(deployments []models.Deployment) []string {
+	var names []string
+	for _, deployment := range deployments {
+		names = append(names, deployment.Name)
+	}
+	return names
+}
@@ -5,11 +5,10 @@ import (
 	"fmt"
 	"log/slog"
 	"net/http"
-	"os"
 	"time"
 
-	run "cloud.google.com/go/run/apiv2"
 	"cloud.google.com/go/run/apiv2/runpb"
+	"github.com/0p5dev/controller/internal/config"
 	"github.com/0p5dev/controller/internal/sharedUtils"
 	"github.com/gin-gonic/gin"
 	"github.com/jackc/pgx/v5/pgxpool"
@@ -23,6 +22,7 @@ type CloudRunServiceDetails struct {
 	Location    string         `json:"location"`
 	CreatedTime string         `json:"created_time"`
 	UpdatedTime string         `json:"updated_time"`
+	Revision    string         `json:"revision,omitempty"` // revision recorded by the last create or update
 	Scaling     ServiceScaling `json:"scaling"`
 	// Metrics     ServiceMetrics `json:"metrics"`
 }
@@ -43,7 +43,11 @@ type ServiceMetrics struct {
 // @Produce json
 // @Security BearerAuth
 // @Param name path string true "Deployment name"
+// @Param environment query string false "Deployment environment (default: default)"
+// @Param If-None-Match header string false "ETag from a previous response; a 304 is returned if it still matches"
 // @Success 200 {object} api.CloudRunServiceDetails "Deployment details"
+// @Success 304 "Deployment unchanged since the ETag in If-None-Match"
+// @Header 200 {string} ETag "Changes whenever any field of the response changes; send it in If-Match to update only this version"
 // @Failure 400 {object} map[string]string "Deployment name is required"
 // @Failure 401 {object} map[string]string "Unauthorized"
 // @Failure 404 {object} map[string]string "Deployment not found"
@@ -54,6 +58,10 @@ func GetOne(c *gin.Context) {
 	pool := c.MustGet("Pool").(*pgxpool.Pool)
 
 	deploymentName := c.Param("name")
+	environment, ok := environmentParam(c)
+	if !ok {
+		return
+	}
 	if deploymentName == "" {
 		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{
 			"error": "deployment name is required",
@@ -62,13 +70,15 @@ func GetOne(c *gin.Context) {
 	}
 
 	ctx := context.Background()
-	projectID := os.Getenv("GCP_PROJECT_ID")
-	location := os.Getenv("GCP_REGION")
+	projectID := config.Get().GcpProjectId
+	location := config.Get().GcpRegion
 
 	// Verify the deployment belongs to the authenticated user
 	dbCtx := c.Request.Context()
 	var deploymentId string
-	err := pool.QueryRow(dbCtx, "SELECT id FROM deployments WHERE name = $1 AND user_id = $2", deploymentName, userClaims.UserMetadata.AppUser.Id).Scan(&deploymentId)
+	var revision *string
+	var version time.Time
+	err := pool.QueryRow(dbCtx, "SELECT id, revision, updated_at FROM deployments WHERE name = $1 AND user_id = $2 AND environment = $3 AND deleted_at IS NULL", deploymentName, userClaims.UserMetadata.AppUser.Id, environment).Scan(&deploymentId, &revision, &version)
 	if err != nil {
 		slog.Error("Error finding deployment", "deployment", deploymentName, "user_id", userClaims.UserMetadata.AppUser.Id, "user_email", userClaims.UserMetadata.AppUser.Email, "error", err)
 		c.AbortWithStatusJSON(http.StatusNotFound, gin.H{
@@ -78,12 +88,9 @@ func GetOne(c *gin.Context) {
 	}
 
 	// Create Cloud Run client
-	runClient, err := run.NewServicesClient(ctx)
+	runClient, err := newCloudRunServices(ctx)
 	if err != nil {
-		slog.Error("Failed to create Cloud Run client", "error", err)
-		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{
-			"error": "failed to initialize Cloud Run client",
-		})
+		abortWithCloudRunError(c, &CloudRunError{Operation: "NewServicesClient", Err: err})
 		return
 	}
 	defer runClient.Close()
@@ -91,16 +98,9 @@ func GetOne(c *gin.Context) {
 	// Get Cloud Run service details
 	serviceName := fmt.Sprintf("projects/%s/locations/%s/services/%s", projectID, location, deploymentId)
 
-	req := &runpb.GetServiceRequest{
-		Name: serviceName,
-	}
-
-	service, err := runClient.GetService(ctx, req)
+	service, err := runClient.GetService(ctx, serviceName)
 	if err != nil {
-		slog.Error("Failed to get service", "service", serviceName, "error", err)
-		c.AbortWithStatusJSON(http.StatusNotFound, gin.H{
-			"error": "Cloud Run service not found",
-		})
+		abortWithCloudRunError(c, &CloudRunError{Operation: "GetService", Service: serviceName, Err: err})
 		return
 	}
 	// fmt.Println("service: ", service)
@@ -149,6 +149,9 @@ func GetOne(c *gin.Context) {
 		},
 		// Metrics: metrics,
 	}
+	if revision != nil {
+		details.Revision = *revision
+	}
 
 	// Determine status
 	if len(service.Conditions) > 0 {
@@ -167,7 +170,7 @@ func GetOne(c *gin.Context) {
 		details.Status = "Unknown"
 	}
 
-	c.JSON(http.StatusOK, details)
+	respondWithETag(c, version, details)
 }
 
 // func getServiceMetrics(ctx context.Context, projectID, location, serviceName string) (ServiceMetrics, error) {
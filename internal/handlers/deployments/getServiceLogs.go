@@ -0,0 +1,164 @@
+package deployments
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"time"
+
+	"cloud.google.com/go/logging/logadmin"
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"google.golang.org/api/iterator"
+	"google.golang.org/protobuf/types/known/structpb"
+
+	"github.com/0p5dev/controller/internal/config"
+	"github.com/0p5dev/controller/internal/sharedUtils"
+)
+
+const (
+	defaultServiceLogLimit = 100
+	maxServiceLogLimit     = 1000
+)
+
+// ServiceLogEntry is one line written by a deployment's containers, as recorded by Cloud Logging
+type ServiceLogEntry struct {
+	Timestamp time.Time      `json:"timestamp"`
+	Severity  string         `json:"severity"`
+	Region    string         `json:"region"`
+	Revision  string         `json:"revision"`
+	Message   string         `json:"message,omitempty"`
+	Payload   map[string]any `json:"payload,omitempty"` // structured (JSON) log lines
+}
+
+// @Summary Get service logs for a deployment
+// @Description Read the most recent stdout/stderr entries written by the deployment's Cloud Run service in any of its regions, newest first
+// @Tags deployments
+// @Produce json
+// @Security BearerAuth
+// @Param name path string true "Deployment name"
+// @Param environment query string false "Deployment environment (default: default)"
+// @Param limit query int false "Maximum number of entries (default 100, max 1000)"
+// @Param since query string false "Only entries at or after this time, as RFC 3339 or a duration such as 15m"
+// @Success 200 {array} ServiceLogEntry "Log entries"
+// @Failure 400 {object} map[string]string "Invalid limit or since"
+// @Failure 401 {object} map[string]string "Unauthorized"
+// @Failure 404 {object} map[string]string "Deployment not found"
+// @Failure 502 {object} map[string]string "Failed to read logs from Cloud Logging"
+// @Router /deployments/{name}/service-logs [get]
+func GetServiceLogs(c *gin.Context) {
+	userClaims := c.MustGet("UserClaims").(*sharedUtils.UserClaims)
+	pool := c.MustGet("Pool").(*pgxpool.Pool)
+	logger := c.MustGet("Logger").(*slog.Logger)
+	ctx := c.Request.Context()
+
+	deploymentName := c.Param("name")
+	environment, ok := environmentParam(c)
+	if !ok {
+		return
+	}
+
+	limit := defaultServiceLogLimit
+	if limitParam := c.Query("limit"); limitParam != "" {
+		parsed, err := strconv.Atoi(limitParam)
+		if err != nil || parsed < 1 || parsed > maxServiceLogLimit {
+			c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{
+				"error": fmt.Sprintf("limit must be between 1 and %d", maxServiceLogLimit),
+			})
+			return
+		}
+		limit = parsed
+	}
+
+	var since time.Time
+	if sinceParam := c.Query("since"); sinceParam != "" {
+		parsed, err := parseLogsSince(sinceParam)
+		if err != nil {
+			c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{
+				"error":   "invalid since",
+				"message": err.Error(),
+			})
+			return
+		}
+		since = parsed
+	}
+
+	// Only the caller's own deployments are found, so the service name below can't belong to someone else
+	deployment, err := scanDeployment(pool.QueryRow(ctx, "SELECT "+deploymentColumns+" FROM deployments WHERE name = $1 AND user_id = $2 AND environment = $3 AND deleted_at IS NULL", deploymentName, userClaims.UserMetadata.AppUser.Id, environment))
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusNotFound, gin.H{
+			"error": "deployment " + deploymentName + " not found",
+		})
+		return
+	}
+
+	filter := fmt.Sprintf(`resource.type="cloud_run_revision" AND resource.labels.service_name=%q AND (log_id("run.googleapis.com/stdout") OR log_id("run.googleapis.com/stderr"))`, deployment.Id)
+	if !since.IsZero() {
+		filter += fmt.Sprintf(` AND timestamp>=%q`, since.UTC().Format(time.RFC3339Nano))
+	}
+
+	// Logging is read with the controller's own service account credentials
+	client, err := logadmin.NewClient(ctx, config.Get().GcpProjectId)
+	if err != nil {
+		logger.Error("Failed to create logging client", "error", err)
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{
+			"error": "failed to create logging client",
+		})
+		return
+	}
+	defer client.Close()
+
+	entries := []ServiceLogEntry{}
+	it := client.Entries(ctx, logadmin.Filter(filter), logadmin.NewestFirst(), logadmin.PageSize(int32(limit)))
+	for len(entries) < limit {
+		entry, err := it.Next()
+		if errors.Is(err, iterator.Done) {
+			break
+		}
+		if err != nil {
+			logger.Error("Failed to read service logs", "deployment", deploymentName, "error", err)
+			c.AbortWithStatusJSON(http.StatusBadGateway, gin.H{
+				"error":   "failed to read logs from Cloud Logging",
+				"message": err.Error(),
+			})
+			return
+		}
+
+		logEntry := ServiceLogEntry{
+			Timestamp: entry.Timestamp,
+			Severity:  entry.Severity.String(),
+		}
+		if entry.Resource != nil {
+			logEntry.Region = entry.Resource.Labels["location"]
+			logEntry.Revision = entry.Resource.Labels["revision_name"]
+		}
+		switch payload := entry.Payload.(type) {
+		case string:
+			logEntry.Message = payload
+		case *structpb.Struct:
+			logEntry.Payload = payload.AsMap()
+		default:
+			logEntry.Message = fmt.Sprint(payload)
+		}
+		entries = append(entries, logEntry)
+	}
+
+	c.JSON(http.StatusOK, entries)
+}
+
+// parseLogsSince accepts an RFC 3339 time or a duration before now
+func parseLogsSince(value string) (time.Time, error) {
+	if duration, err := time.ParseDuration(value); err == nil {
+		if duration <= 0 {
+			return time.Time{}, errors.New("since duration must be positive")
+		}
+		return time.Now().Add(-duration), nil
+	}
+	parsed, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return time.Time{}, errors.New("since must be an RFC 3339 time or a duration such as 15m")
+	}
+	return parsed, nil
+}
@@ -0,0 +1,123 @@
+package deployments
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+
+	"github.com/0p5dev/controller/internal/sharedUtils"
+)
+
+type DeploymentStats struct {
+	Scope                 string                     `json:"scope"`
+	Total                 int                        `json:"total"`
+	ByStatus              map[string]int             `json:"by_status"`
+	UniqueContainerImages int                        `json:"unique_container_images"`
+	MostRecentlyUpdated   *RecentlyUpdatedDeployment `json:"most_recently_updated"`
+}
+
+type RecentlyUpdatedDeployment struct {
+	Name      string    `json:"name"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// @Summary Get deployment statistics
+// @Description Aggregate counts for the dashboard: total deployments, counts by latest provisioning job status, unique container images, and the most recently updated deployment
+// @Tags deployments
+// @Produce json
+// @Security BearerAuth
+// @Param scope query string false "user (default) or global (admin only)"
+// @Success 200 {object} DeploymentStats "Deployment statistics"
+// @Failure 400 {object} map[string]string "Invalid scope"
+// @Failure 401 {object} map[string]string "Unauthorized"
+// @Failure 403 {object} map[string]string "Global stats require admin"
+// @Failure 500 {object} map[string]string "Failed to compute statistics"
+// @Router /deployments/stats [get]
+func GetStats(c *gin.Context) {
+	userClaims := c.MustGet("UserClaims").(*sharedUtils.UserClaims)
+	pool := c.MustGet("Pool").(*pgxpool.Pool)
+	ctx := c.Request.Context()
+
+	scope := c.DefaultQuery("scope", "user")
+	whereClause := "WHERE deleted_at IS NULL AND user_id = $1"
+	args := []interface{}{userClaims.UserMetadata.AppUser.Id}
+	switch scope {
+	case "user":
+	case "global":
+		if !sharedUtils.IsAdmin(userClaims) {
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{
+				"error": "global stats are only available to admins",
+			})
+			return
+		}
+		whereClause = "WHERE deleted_at IS NULL"
+		args = nil
+	default:
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{
+			"error": "invalid scope " + scope + ", must be user or global",
+		})
+		return
+	}
+
+	stats := DeploymentStats{Scope: scope, ByStatus: map[string]int{}}
+
+	err := pool.QueryRow(ctx, fmt.Sprintf("SELECT COUNT(*), COUNT(DISTINCT container_image) FROM deployments %s", whereClause), args...).Scan(&stats.Total, &stats.UniqueContainerImages)
+	if err != nil {
+		slog.Error("Error counting deployments", "error", err)
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to count deployments",
+		})
+		return
+	}
+
+	// Deployments whose jobs have all been purged have no status and are counted as unknown
+	rows, err := pool.Query(ctx, fmt.Sprintf("SELECT COALESCE(%s, 'unknown') AS status, COUNT(*) FROM deployments %s GROUP BY status", latestJobStatusExpr, whereClause), args...)
+	if err != nil {
+		slog.Error("Error counting deployments by status", "error", err)
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to count deployments by status",
+		})
+		return
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var status string
+		var count int
+		if err := rows.Scan(&status, &count); err != nil {
+			slog.Error("Error scanning deployment status count", "error", err)
+			c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{
+				"error": "Failed to count deployments by status",
+			})
+			return
+		}
+		stats.ByStatus[status] = count
+	}
+	if err := rows.Err(); err != nil {
+		slog.Error("Error iterating deployment status counts", "error", err)
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to count deployments by status",
+		})
+		return
+	}
+
+	var recent RecentlyUpdatedDeployment
+	err = pool.QueryRow(ctx, fmt.Sprintf("SELECT name, updated_at FROM deployments %s ORDER BY updated_at DESC LIMIT 1", whereClause), args...).Scan(&recent.Name, &recent.UpdatedAt)
+	switch {
+	case err == nil:
+		stats.MostRecentlyUpdated = &recent
+	case !errors.Is(err, pgx.ErrNoRows):
+		slog.Error("Error finding most recently updated deployment", "error", err)
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to find most recently updated deployment",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, stats)
+}
@@ -0,0 +1,79 @@
+package deployments
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strings"
+	"time"
+)
+
+const (
+	defaultHealthCheckTimeoutSeconds = 10
+	maxHealthCheckTimeoutSeconds     = 60
+)
+
+// validateHealthCheck checks the optional post-deploy probe settings
+func validateHealthCheck(path *string, timeoutSeconds *int) error {
+	if path != nil && !strings.HasPrefix(*path, "/") {
+		return errors.New("health_check_path must start with /")
+	}
+	if timeoutSeconds != nil {
+		if path == nil {
+			return errors.New("health_check_timeout_seconds requires health_check_path")
+		}
+		if *timeoutSeconds < 1 || *timeoutSeconds > maxHealthCheckTimeoutSeconds {
+			return fmt.Errorf("health_check_timeout_seconds must be between 1 and %d", maxHealthCheckTimeoutSeconds)
+		}
+	}
+	return nil
+}
+
+// probeServiceHealth GETs a path on a freshly deployed service and reports "ok" for a non-error response,
+// or "unreachable" otherwise. Cloud Run can report a revision as created while its container crash-loops,
+// so this is the only signal that the service actually serves traffic. The result never fails the deployment.
+func probeServiceHealth(serviceUrl string, path string, timeoutSeconds int) string {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(timeoutSeconds)*time.Second)
+	defer cancel()
+
+	statusCode, err := probeUrl(ctx, strings.TrimSuffix(serviceUrl, "/")+path)
+	if err != nil {
+		slog.Warn("Post-deploy health check failed", "service_url", serviceUrl, "path", path, "error", err.Error())
+		return "unreachable"
+	}
+	if statusCode >= 400 {
+		slog.Warn("Post-deploy health check returned an error status", "service_url", serviceUrl, "path", path, "status", statusCode)
+		return "unreachable"
+	}
+
+	return "ok"
+}
+
+// probeClient is used for every request to a user's service. The service decides where a redirect goes and the
+// status code is reported back to the user, so redirects aren't followed and only public addresses are dialed.
+var probeClient = &http.Client{
+	Transport: &http.Transport{
+		DialContext: publicOnlyDialer("health check").DialContext,
+	},
+	CheckRedirect: func(req *http.Request, via []*http.Request) error {
+		return http.ErrUseLastResponse
+	},
+}
+
+// probeUrl GETs a URL and returns the status code it answered with. A redirect is reported as its own status.
+func probeUrl(ctx context.Context, url string) (int, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return 0, fmt.Errorf("failed to build health check request: %w", err)
+	}
+
+	resp, err := probeClient.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+
+	return resp.StatusCode, nil
+}
@@ -0,0 +1,49 @@
+package deployments
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestProbeUrlRefusesPrivateAddresses(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	_, err := probeUrl(context.Background(), server.URL)
+	if err == nil || !strings.Contains(err.Error(), "not a public address") {
+		t.Errorf("err = %v, want the loopback address refused", err)
+	}
+}
+
+func TestProbeUrlDoesNotFollowRedirects(t *testing.T) {
+	internalHit := false
+	internal := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		internalHit = true
+	}))
+	defer internal.Close()
+	service := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, internal.URL+"/metadata", http.StatusFound)
+	}))
+	defer service.Close()
+
+	// The test servers are on loopback, so dial them directly and check only the redirect policy
+	transport := probeClient.Transport
+	probeClient.Transport = http.DefaultTransport
+	t.Cleanup(func() { probeClient.Transport = transport })
+
+	statusCode, err := probeUrl(context.Background(), service.URL)
+	if err != nil {
+		t.Fatalf("probeUrl: %v", err)
+	}
+	if statusCode != http.StatusFound {
+		t.Errorf("status = %d, want the redirect itself reported", statusCode)
+	}
+	if internalHit {
+		t.Error("the probe followed the redirect")
+	}
+}
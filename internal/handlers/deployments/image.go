@@ -0,0 +1,87 @@
+package deployments
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/go-containerregistry/pkg/name"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+	"github.com/google/go-containerregistry/pkg/v1/remote/transport"
+)
+
+var (
+	errInvalidImageReference = errors.New("invalid image reference")
+	errImageInaccessible     = errors.New("image not found or inaccessible")
+)
+
+// resolveImageDigest confirms an image manifest exists in its registry and that Cloud Run can pull it, and resolves
+// the reference to an immutable digest reference (repo@sha256:...), so a deployed revision always runs exactly the
+// requested image. Credentials, when given, are used to reach an external registry.
+func resolveImageDigest(ctx context.Context, image string, credentials *RegistryCredentials) (string, error) {
+	ref, err := name.ParseReference(image)
+	if err != nil {
+		return "", fmt.Errorf("%w %q: %v", errInvalidImageReference, image, err)
+	}
+	if credentials != nil && isGoogleRegistry(ref.Context().RegistryStr()) {
+		return "", errCredentialsForGoogleRegistry
+	}
+
+	// HEAD only fetches the manifest descriptor, which is enough to confirm the image exists and read its digest.
+	// Retries are left to withRegistryRetry, so status codes aren't also retried inside the registry client.
+	descriptor, err := withRegistryRetry(ctx, "HeadImage", func() (*v1.Descriptor, error) {
+		return remote.Head(ref, registryAuth(credentials), remote.WithContext(ctx), remote.WithRetryStatusCodes())
+	})
+	if err != nil {
+		var transportErr *transport.Error
+		if errors.As(err, &transportErr) {
+			switch transportErr.StatusCode {
+			case http.StatusNotFound, http.StatusUnauthorized, http.StatusForbidden:
+				return "", fmt.Errorf("%w: %s", errImageInaccessible, image)
+			}
+		}
+		return "", fmt.Errorf("failed to resolve image %q: %w", image, err)
+	}
+
+	digest := ref.Context().Digest(descriptor.Digest.String())
+	if err := checkCloudRunPullAccess(ctx, digest); err != nil {
+		return "", err
+	}
+
+	return digest.Name(), nil
+}
+
+// imageResolutionErrorResponse maps a resolveImageDigest error to a status code and response body
+func imageResolutionErrorResponse(err error) (int, gin.H) {
+	switch {
+	case errors.Is(err, errInvalidImageReference), errors.Is(err, errCredentialsForGoogleRegistry):
+		return http.StatusBadRequest, gin.H{
+			"error":   "invalid container image reference",
+			"message": err.Error(),
+		}
+	case errors.Is(err, errImageInaccessible):
+		return http.StatusBadRequest, gin.H{
+			"error":   "image not found or inaccessible",
+			"message": err.Error(),
+		}
+	case errors.Is(err, errImageNotPullable):
+		return http.StatusBadRequest, gin.H{
+			"error":   "image cannot be pulled by cloud run",
+			"message": err.Error(),
+		}
+	default:
+		return http.StatusBadGateway, gin.H{
+			"error":   "failed to reach container registry",
+			"message": err.Error(),
+		}
+	}
+}
+
+// isDigestReference reports whether an image reference already pins a digest rather than a tag
+func isDigestReference(image string) bool {
+	_, err := name.NewDigest(image)
+	return err == nil
+}
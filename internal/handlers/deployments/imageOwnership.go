@@ -0,0 +1,91 @@
+package deployments
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/0p5dev/controller/internal/sharedUtils"
+	"github.com/google/go-containerregistry/pkg/name"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+var errImageNotOwned = errors.New("container image does not belong to the requesting user")
+
+// isPublicImage reports whether an image matches one of the PUBLIC_IMAGE_PREFIXES registry prefixes
+// (e.g. "docker.io/library/") that any user may deploy without having pushed it themselves
+func isPublicImage(image string) bool {
+	for _, prefix := range sharedUtils.GetEnvList("PUBLIC_IMAGE_PREFIXES") {
+		if strings.HasPrefix(image, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// authorizeContainerImage ensures the user may deploy an image: either they pushed it, it is a digest of
+// a repository they pushed to, or it matches the public image allowlist
+func authorizeContainerImage(ctx context.Context, pool *pgxpool.Pool, userId string, image string) error {
+	if isPublicImage(image) {
+		return nil
+	}
+
+	var owned bool
+	err := pool.QueryRow(ctx, "SELECT EXISTS(SELECT 1 FROM container_images WHERE fqin = $1 AND user_id = $2)", image, userId).Scan(&owned)
+	if err != nil {
+		return fmt.Errorf("failed to check container image ownership: %w", err)
+	}
+	if owned {
+		return nil
+	}
+
+	digest, err := name.NewDigest(image)
+	if err != nil {
+		return errImageNotOwned
+	}
+
+	repository := digest.Context().Name()
+	err = pool.QueryRow(ctx, `
+		SELECT EXISTS(
+			SELECT 1 FROM container_images
+			WHERE user_id = $1 AND (starts_with(fqin, $2 || ':') OR starts_with(fqin, $2 || '@'))
+		)
+	`, userId, repository).Scan(&owned)
+	if err != nil {
+		return fmt.Errorf("failed to check container image ownership: %w", err)
+	}
+	if !owned {
+		return errImageNotOwned
+	}
+
+	return nil
+}
+
+// recordContainerImageReference records digest, public, and external image references, which the push endpoint
+// never records, so the deployment's container_image foreign key is satisfied. Public images are recorded without an
+// owner; an external image got here through the caller's own registry credentials, so it is recorded as theirs.
+func recordContainerImageReference(ctx context.Context, pool *pgxpool.Pool, userId string, image string) error {
+	var ownerId *string
+	switch {
+	case isPublicImage(image):
+		ownerId = nil
+	case isDigestReference(image), isExternalImage(image):
+		ownerId = &userId
+	default:
+		return nil
+	}
+
+	_, err := pool.Exec(ctx, `
+		INSERT INTO container_images (fqin, user_id)
+		VALUES ($1, $2)
+		ON CONFLICT (fqin) DO NOTHING
+	`, image, ownerId)
+	return err
+}
+
+// isExternalImage reports whether an image lives outside Artifact Registry and Container Registry
+func isExternalImage(image string) bool {
+	ref, err := name.ParseReference(image)
+	return err == nil && !isGoogleRegistry(ref.Context().RegistryStr())
+}
@@ -0,0 +1,60 @@
+package deployments
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestIsPublicImage(t *testing.T) {
+	t.Setenv("PUBLIC_IMAGE_PREFIXES", "docker.io/library/, gcr.io/distroless/")
+	tests := map[string]bool{
+		"docker.io/library/nginx:latest":         true,
+		"gcr.io/distroless/static:nonroot":       true,
+		"docker.io/someone/nginx:latest":         false,
+		"us-docker.pkg.dev/project/repo/api:v1":  false,
+		"docker.io/library-lookalike/nginx:1.27": false,
+	}
+	for image, want := range tests {
+		if got := isPublicImage(image); got != want {
+			t.Errorf("isPublicImage(%q) = %v, want %v", image, got, want)
+		}
+	}
+}
+
+func TestAuthorizeContainerImageDeniesOtherUsersImages(t *testing.T) {
+	pool := testPool(t)
+	ctx := context.Background()
+	owner := createTestUser(t, pool)
+	other := createTestUser(t, pool)
+
+	repository := "us-docker.pkg.dev/project/repo/" + strings.ToLower(owner)
+	createTestImage(t, pool, owner, repository+":v1")
+	digest := repository + "@sha256:" + strings.Repeat("a", 64)
+
+	for _, image := range []string{repository + ":v1", digest} {
+		if err := authorizeContainerImage(ctx, pool, owner, image); err != nil {
+			t.Errorf("owner deploying %s: %v", image, err)
+		}
+		if err := authorizeContainerImage(ctx, pool, other, image); !errors.Is(err, errImageNotOwned) {
+			t.Errorf("other user deploying %s: err = %v, want %v", image, err, errImageNotOwned)
+		}
+	}
+
+	// A repository whose name only starts with the owner's isn't theirs
+	lookalike := repository + "-lookalike@sha256:" + strings.Repeat("b", 64)
+	if err := authorizeContainerImage(ctx, pool, owner, lookalike); !errors.Is(err, errImageNotOwned) {
+		t.Errorf("owner deploying %s: err = %v, want %v", lookalike, err, errImageNotOwned)
+	}
+}
+
+func TestAuthorizeContainerImageAllowsPublicImages(t *testing.T) {
+	pool := testPool(t)
+	t.Setenv("PUBLIC_IMAGE_PREFIXES", "docker.io/library/")
+	user := createTestUser(t, pool)
+
+	if err := authorizeContainerImage(context.Background(), pool, user, "docker.io/library/nginx:latest"); err != nil {
+		t.Errorf("public image denied: %v", err)
+	}
+}
@@ -0,0 +1,184 @@
+package deployments
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"sync"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+	"github.com/jackc/pgx/v5/pgxpool"
+
+	"github.com/0p5dev/controller/internal/sharedUtils"
+)
+
+const maxImportSpecs = 50
+
+type ImportResult struct {
+	Name        string `json:"name"`
+	Environment string `json:"environment"`
+	Status      int    `json:"status"`           // HTTP status the create or update endpoint would have returned
+	Action      string `json:"action,omitempty"` // created | updated | unchanged
+	JobId       string `json:"job_id,omitempty"`
+	Error       string `json:"error,omitempty"`
+	// Fields lists every invalid field when the spec itself failed validation
+	Fields []FieldError `json:"fields,omitempty"`
+}
+
+// @Summary Import deployments
+// @Description Create or update a deployment for each spec, in the shape returned by the export endpoint. Existing deployments are updated to match the spec, except for their regions, sidecars, and volumes. Each spec is handled independently, so a failure for one does not abort the rest; a spec whose deployment already has a create or update in progress gets a 409.
+// @Tags deployments
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param request body []CreateOneRequestBody true "Deployment specs"
+// @Success 200 {array} ImportResult "Per-spec results"
+// @Failure 400 {object} map[string]string "Invalid request body"
+// @Failure 401 {object} map[string]string "Unauthorized"
+// @Failure 500 {object} map[string]string "Failed to check existing deployments"
+// @Router /deployments/import [post]
+func ImportMany(c *gin.Context) {
+	userClaims := c.MustGet("UserClaims").(*sharedUtils.UserClaims)
+	pool := c.MustGet("Pool").(*pgxpool.Pool)
+
+	// Specs are decoded without binding validation so that an invalid spec fails on its own, not the whole import
+	var specs []CreateOneRequestBody
+	if err := json.NewDecoder(c.Request.Body).Decode(&specs); err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, bindingErrorResponse(c, err))
+		return
+	}
+	if len(specs) == 0 {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{
+			"error": "at least one deployment spec is required",
+		})
+		return
+	}
+	if len(specs) > maxImportSpecs {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{
+			"error": fmt.Sprintf("at most %d deployments can be imported per request", maxImportSpecs),
+		})
+		return
+	}
+
+	// A user's deployments are identified by name and environment together
+	existing := map[[2]string]bool{}
+	rows, err := pool.Query(c.Request.Context(), "SELECT name, environment FROM deployments WHERE user_id = $1 AND deleted_at IS NULL", userClaims.UserMetadata.AppUser.Id)
+	if err != nil {
+		slog.Error("Failed to list existing deployments", "error", err)
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{
+			"error": "failed to check existing deployments",
+		})
+		return
+	}
+	for rows.Next() {
+		var name, environment string
+		if err := rows.Scan(&name, &environment); err == nil {
+			existing[[2]string{name, environment}] = true
+		}
+	}
+	rows.Close()
+
+	concurrency := sharedUtils.GetEnvInt("IMPORT_CONCURRENCY", 4)
+	if concurrency < 1 {
+		concurrency = 1
+	}
+
+	results := make([]ImportResult, len(specs))
+	semaphore := make(chan struct{}, concurrency)
+	var wg sync.WaitGroup
+	seen := map[[2]string]bool{}
+
+	for i, spec := range specs {
+		if spec.Environment == "" {
+			spec.Environment = defaultEnvironment
+		}
+		key := [2]string{spec.Name, spec.Environment}
+
+		// Only the first of two specs for the same deployment is applied; the second would just find its lock taken
+		if seen[key] {
+			results[i] = ImportResult{Name: spec.Name, Environment: spec.Environment, Status: http.StatusConflict, Error: "deployment " + spec.Name + " appears more than once in the import for environment " + spec.Environment}
+			continue
+		}
+		seen[key] = true
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			semaphore <- struct{}{}
+			defer func() { <-semaphore }()
+
+			results[i] = importSpec(c.Copy(), spec, existing[key])
+		}()
+	}
+	wg.Wait()
+
+	c.JSON(http.StatusOK, results)
+}
+
+// importSpec runs one spec through the create or update endpoint's logic, and turns its outcome into a result
+func importSpec(c *gin.Context, spec CreateOneRequestBody, exists bool) ImportResult {
+	if err := binding.Validator.ValidateStruct(spec); err != nil {
+		result := ImportResult{Name: spec.Name, Environment: spec.Environment, Status: http.StatusBadRequest, Error: "invalid request payload"}
+		if fields, ok := fieldErrors(err); ok {
+			result.Fields = fields
+		} else {
+			result.Error += ": " + err.Error()
+		}
+		return result
+	}
+
+	// A version in If-Match belongs to at most one deployment, so imports always update unconditionally
+	var outcome deploymentResult
+	if exists {
+		outcome = updateDeployment(c, spec.Name, spec.Environment, nil, updateBodyFromSpec(spec))
+	} else {
+		outcome = createDeployment(c, spec)
+	}
+
+	result := ImportResult{Name: spec.Name, Environment: spec.Environment, Status: outcome.Status}
+	if outcome.Error != nil {
+		result.Error = outcome.errorMessage()
+		return result
+	}
+	result.Action = outcome.Response.Action
+	result.JobId = outcome.Response.JobId
+	return result
+}
+
+// updateBodyFromSpec makes an update that brings an existing deployment in line with a spec. Unlike a regular
+// update, VPC settings, the execution environment, and ingress missing from the spec are removed.
+func updateBodyFromSpec(spec CreateOneRequestBody) UpdateDeploymentRequestBody {
+	emptyIfNil := func(value *string) *string {
+		if value == nil {
+			return new(string)
+		}
+		return value
+	}
+	body := UpdateDeploymentRequestBody{
+		ContainerImage:        &spec.ContainerImage,
+		RegistryCredentials:   spec.RegistryCredentials,
+		MinInstances:          spec.MinInstances,
+		MaxInstances:          spec.MaxInstances,
+		Port:                  spec.Port,
+		CpuAlwaysAllocated:    &spec.CpuAlwaysAllocated,
+		StartupCpuBoost:       &spec.StartupCpuBoost,
+		MaxConcurrency:        spec.MaxConcurrency,
+		RequestTimeoutSeconds: spec.RequestTimeoutSeconds,
+		VpcConnector:          emptyIfNil(spec.VpcConnector),
+		VpcNetwork:            emptyIfNil(spec.VpcNetwork),
+		VpcSubnet:             emptyIfNil(spec.VpcSubnet),
+		VpcEgress:             emptyIfNil(spec.VpcEgress),
+		ExecutionEnvironment:  emptyIfNil(spec.ExecutionEnvironment),
+		Ingress:               emptyIfNil(spec.Ingress),
+		Cpu:                   emptyIfNil(spec.Cpu),
+		Memory:                emptyIfNil(spec.Memory),
+		Version:               emptyIfNil(spec.Version),
+		DeletionProtection:    spec.DeletionProtection,
+	}
+	if spec.CallbackUrl != "" {
+		body.CallbackUrl = &spec.CallbackUrl
+	}
+	return body
+}
@@ -0,0 +1,175 @@
+package deployments
+
+import (
+	"errors"
+	"log/slog"
+	"net/http"
+	"strings"
+
+	"github.com/0p5dev/controller/internal/sharedUtils"
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+type MapDomainRequestBody struct {
+	Domain string `json:"domain" binding:"required"`
+}
+
+// @Summary Map a custom domain to a deployment
+// @Description Serve a deployment on a custom domain. The response lists the DNS records to configure; the mapping becomes ready once they resolve and a certificate is issued. The domain must be verified for the project in Google Search Console.
+// @Tags deployments
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param name path string true "Deployment name"
+// @Param environment query string false "Deployment environment (default: default)"
+// @Param request body MapDomainRequestBody true "Domain to map"
+// @Success 201 {object} DomainMappingStatus "Domain mapping created"
+// @Failure 400 {object} map[string]string "Invalid domain"
+// @Failure 401 {object} map[string]string "Unauthorized"
+// @Failure 404 {object} map[string]string "Deployment not found"
+// @Failure 409 {object} map[string]string "Domain is already mapped"
+// @Failure 500 {object} map[string]string "Failed to create domain mapping"
+// @Router /deployments/{name}/domain [post]
+func MapDomain(c *gin.Context) {
+	userClaims := c.MustGet("UserClaims").(*sharedUtils.UserClaims)
+	logger := c.MustGet("Logger").(*slog.Logger)
+	pool := c.MustGet("Pool").(*pgxpool.Pool)
+	reqCtx := c.Request.Context()
+
+	deploymentName := c.Param("name")
+	environment, ok := environmentParam(c)
+	if !ok {
+		return
+	}
+
+	var reqBody MapDomainRequestBody
+	if err := c.ShouldBindJSON(&reqBody); err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{
+			"error": "invalid request body",
+		})
+		return
+	}
+	domain := strings.TrimSuffix(strings.ToLower(strings.TrimSpace(reqBody.Domain)), ".")
+	if len(domain) > 253 || !domainPattern.MatchString(domain) {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{
+			"error": "invalid domain " + reqBody.Domain,
+		})
+		return
+	}
+
+	deployment, err := scanDeployment(pool.QueryRow(reqCtx, "SELECT "+deploymentColumns+" FROM deployments WHERE name = $1 AND user_id = $2 AND environment = $3 AND deleted_at IS NULL", deploymentName, userClaims.UserMetadata.AppUser.Id, environment))
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusNotFound, gin.H{
+			"error": "deployment " + deploymentName + " not found",
+		})
+		return
+	}
+
+	// Claim the domain first so two deployments can't race to map it
+	region := primaryRegion(deployment)
+	result, err := pool.Exec(reqCtx, `
+		INSERT INTO domain_mappings (domain, deployment_id, user_id, region)
+		VALUES ($1, $2, $3, $4)
+		ON CONFLICT (domain) DO NOTHING
+	`, domain, deployment.Id, userClaims.UserMetadata.AppUser.Id, region)
+	if err != nil {
+		logger.Error("Failed to record domain mapping", "domain", domain, "error", err)
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{
+			"error": "failed to record domain mapping",
+		})
+		return
+	}
+	if result.RowsAffected() == 0 {
+		c.AbortWithStatusJSON(http.StatusConflict, gin.H{
+			"error": "domain " + domain + " is already mapped to a deployment",
+		})
+		return
+	}
+
+	mapping, err := createDomainMapping(reqCtx, region, deployment.Id, domain)
+	if err != nil {
+		if _, deleteErr := pool.Exec(reqCtx, "DELETE FROM domain_mappings WHERE domain = $1", domain); deleteErr != nil {
+			logger.Error("Failed to release domain mapping claim", "domain", domain, "error", deleteErr)
+		}
+		if errors.Is(err, errDomainAlreadyMapped) {
+			c.AbortWithStatusJSON(http.StatusConflict, gin.H{
+				"error": "domain " + domain + " is already mapped to another Cloud Run service",
+			})
+			return
+		}
+		logger.Error("Failed to create domain mapping", "domain", domain, "deployment_id", deployment.Id, "error", err)
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{
+			"error": "failed to create domain mapping: " + err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusCreated, domainMappingStatus(domain, region, mapping))
+}
+
+// @Summary List a deployment's custom domains
+// @Description Report each custom domain mapped to a deployment with its live readiness and required DNS records
+// @Tags deployments
+// @Produce json
+// @Security BearerAuth
+// @Param name path string true "Deployment name"
+// @Param environment query string false "Deployment environment (default: default)"
+// @Success 200 {array} DomainMappingStatus "Domain mappings"
+// @Failure 401 {object} map[string]string "Unauthorized"
+// @Failure 404 {object} map[string]string "Deployment not found"
+// @Failure 500 {object} map[string]string "Failed to read domain mappings"
+// @Router /deployments/{name}/domain [get]
+func GetDomains(c *gin.Context) {
+	userClaims := c.MustGet("UserClaims").(*sharedUtils.UserClaims)
+	logger := c.MustGet("Logger").(*slog.Logger)
+	pool := c.MustGet("Pool").(*pgxpool.Pool)
+	reqCtx := c.Request.Context()
+
+	deploymentName := c.Param("name")
+	environment, ok := environmentParam(c)
+	if !ok {
+		return
+	}
+
+	var deploymentId string
+	err := pool.QueryRow(reqCtx, "SELECT id FROM deployments WHERE name = $1 AND user_id = $2 AND environment = $3 AND deleted_at IS NULL", deploymentName, userClaims.UserMetadata.AppUser.Id, environment).Scan(&deploymentId)
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusNotFound, gin.H{
+			"error": "deployment " + deploymentName + " not found",
+		})
+		return
+	}
+
+	rows, err := pool.Query(reqCtx, "SELECT domain, region FROM domain_mappings WHERE deployment_id = $1 ORDER BY domain", deploymentId)
+	if err != nil {
+		logger.Error("Failed to query domain mappings", "deployment_id", deploymentId, "error", err)
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{
+			"error": "failed to read domain mappings",
+		})
+		return
+	}
+	defer rows.Close()
+
+	statuses := []DomainMappingStatus{}
+	for rows.Next() {
+		var domain, region string
+		if err := rows.Scan(&domain, &region); err != nil {
+			logger.Error("Failed to scan domain mapping", "deployment_id", deploymentId, "error", err)
+			c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{
+				"error": "failed to read domain mappings",
+			})
+			return
+		}
+
+		mapping, err := getDomainMapping(reqCtx, region, domain)
+		if err != nil {
+			logger.Warn("Failed to read domain mapping status", "domain", domain, "error", err)
+			statuses = append(statuses, DomainMappingStatus{Domain: domain, Region: region, Message: "status unavailable", DnsRecords: []DomainDnsRecord{}})
+			continue
+		}
+		statuses = append(statuses, domainMappingStatus(domain, region, mapping))
+	}
+
+	c.JSON(http.StatusOK, statuses)
+}
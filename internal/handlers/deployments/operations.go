@@ -0,0 +1,134 @@
+package deployments
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"sync"
+	"sync/atomic"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/0p5dev/controller/internal/sharedUtils"
+)
+
+// operationRetryAfterSeconds is the Retry-After sent when the operation queue is full
+const operationRetryAfterSeconds = 30
+
+var errOperationCanceled = errors.New("canceled by user")
+
+// operations tracks the create and update jobs running on this instance by job id, so they can be canceled
+var operations = &operationRegistry{cancels: map[string]context.CancelCauseFunc{}}
+
+type operationRegistry struct {
+	mu      sync.Mutex
+	cancels map[string]context.CancelCauseFunc
+}
+
+// startOperation gives a provisioning job its context, bounded by deploymentTimeout and registered so cancelOperation
+// can stop it. It must be called before the job is announced to the client, so a cancel right after the 202 finds it.
+// The returned func must be called when the job finishes.
+func startOperation(jobId string) (context.Context, context.CancelFunc) {
+	ctx, cancelCause := context.WithCancelCause(context.Background())
+	ctx, cancelTimeout := context.WithTimeout(ctx, deploymentTimeout())
+
+	operations.mu.Lock()
+	operations.cancels[jobId] = cancelCause
+	operations.mu.Unlock()
+
+	return ctx, func() {
+		operations.mu.Lock()
+		delete(operations.cancels, jobId)
+		operations.mu.Unlock()
+		cancelTimeout()
+		cancelCause(nil)
+	}
+}
+
+// cancelOperation cancels a job running on this instance, and reports whether it was running here
+func cancelOperation(jobId string) bool {
+	operations.mu.Lock()
+	defer operations.mu.Unlock()
+
+	cancel, ok := operations.cancels[jobId]
+	if ok {
+		cancel(errOperationCanceled)
+	}
+	return ok
+}
+
+// hasOperation reports whether a job is running on this instance
+func hasOperation(jobId string) bool {
+	operations.mu.Lock()
+	defer operations.mu.Unlock()
+
+	_, ok := operations.cancels[jobId]
+	return ok
+}
+
+// jobFailureMessage marks the failure of a job that was canceled, since it otherwise only reports a context error
+func jobFailureMessage(ctx context.Context, errMsg string) string {
+	if errors.Is(context.Cause(ctx), errOperationCanceled) {
+		return errOperationCanceled.Error() + ": " + errMsg
+	}
+	return errMsg
+}
+
+// operationSlots bounds the create and update jobs running Cloud Run operations at once on this instance, to
+// MAX_CONCURRENT_OPERATIONS (default 10). Jobs beyond that wait for a slot, up to OPERATION_QUEUE_SIZE (default 50)
+// of them, and requests past the queue are turned away.
+var operationSlots struct {
+	once     sync.Once
+	running  chan struct{}
+	admitted atomic.Int64 // running and waiting jobs
+	capacity int64
+}
+
+func initOperationSlots() {
+	operationSlots.once.Do(func() {
+		concurrency := max(sharedUtils.GetEnvInt("MAX_CONCURRENT_OPERATIONS", 10), 1)
+		queueSize := max(sharedUtils.GetEnvInt("OPERATION_QUEUE_SIZE", 50), 0)
+		operationSlots.running = make(chan struct{}, concurrency)
+		operationSlots.capacity = int64(concurrency + queueSize)
+	})
+}
+
+// admitOperation reserves a place for a new job, or reports false when every slot and queue place is taken. An
+// admitted job must either acquire its slot or be dismissed.
+func admitOperation() bool {
+	initOperationSlots()
+	if operationSlots.admitted.Add(1) > operationSlots.capacity {
+		operationSlots.admitted.Add(-1)
+		return false
+	}
+	return true
+}
+
+// operationsFullResult is the 503 for a job turned away by admitOperation
+func operationsFullResult() deploymentResult {
+	result := deploymentError(http.StatusServiceUnavailable, gin.H{
+		"error": "too many deployment operations in progress, try again later",
+	})
+	result.RetryAfter = operationRetryAfterSeconds
+	return result
+}
+
+// dismissOperation gives back the place of an admitted job that was never started
+func dismissOperation() {
+	operationSlots.admitted.Add(-1)
+}
+
+// acquireOperationSlot waits for an admitted job's turn to run. Waiting counts against the job's deploymentTimeout and
+// can be canceled like the job itself. The returned func frees the slot once the job is done.
+func acquireOperationSlot(ctx context.Context) (func(), error) {
+	select {
+	case operationSlots.running <- struct{}{}:
+		return func() {
+			<-operationSlots.running
+			operationSlots.admitted.Add(-1)
+		}, nil
+	case <-ctx.Done():
+		operationSlots.admitted.Add(-1)
+		return nil, ctx.Err()
+	}
+}
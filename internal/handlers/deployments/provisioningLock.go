@@ -0,0 +1,39 @@
+package deployments
+
+import (
+	"context"
+	"errors"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// pendingJobIndex is the unique index that allows each deployment at most one pending provisioning job. Inserting a
+// job claims the deployment's lock, and a unique violation on this index means another job holds it.
+const pendingJobIndex = "provisioning_jobs_one_pending"
+
+// hasPendingProvisioningJob reports whether a create or update is still running for a deployment.
+// A pending provisioning job acts as the deployment's lock: other operations must not race it.
+func hasPendingProvisioningJob(ctx context.Context, pool *pgxpool.Pool, deploymentId string) (bool, error) {
+	var pending bool
+	err := pool.QueryRow(ctx, "SELECT EXISTS(SELECT 1 FROM provisioning_jobs WHERE resource_id = $1 AND status = 'pending')", deploymentId).Scan(&pending)
+	return pending, err
+}
+
+// pendingProvisioningJobId returns the id of a deployment's pending job, or "" when it has none
+func pendingProvisioningJobId(ctx context.Context, pool *pgxpool.Pool, deploymentId string) (string, error) {
+	var jobId string
+	err := pool.QueryRow(ctx, "SELECT id FROM provisioning_jobs WHERE resource_id = $1 AND status = 'pending'", deploymentId).Scan(&jobId)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return "", nil
+	}
+	return jobId, err
+}
+
+// deploymentLockedResponse is the 409 body for an operation turned away by another job's lock
+func deploymentLockedResponse(deploymentName string) gin.H {
+	return gin.H{
+		"error": "deployment " + deploymentName + " has a create or update in progress; wait for it to finish or cancel it",
+	}
+}
@@ -0,0 +1,97 @@
+package deployments
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+	"time"
+
+	runpb "cloud.google.com/go/run/apiv2/runpb"
+	"github.com/0p5dev/controller/internal/sharedUtils"
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+type DeploymentRefreshResponse struct {
+	Name      string                `json:"name"`
+	Drifted   bool                  `json:"drifted"`
+	Drift     []DeploymentDriftItem `json:"drift"`
+	CheckedAt string                `json:"checked_at"`
+}
+
+// @Summary Check a deployment for drift
+// @Description Compare every stored setting of a deployment with the live Cloud Run service and report the ones changed out-of-band. Nothing is modified.
+// @Tags deployments
+// @Produce json
+// @Security BearerAuth
+// @Param name path string true "Deployment name"
+// @Param environment query string false "Deployment environment (default: default)"
+// @Success 200 {object} DeploymentRefreshResponse "Drift report"
+// @Failure 401 {object} map[string]string "Unauthorized"
+// @Failure 404 {object} map[string]string "Deployment or Cloud Run service not found"
+// @Failure 409 {object} map[string]string "A provisioning job is in progress for the deployment"
+// @Failure 500 {object} map[string]string "Failed to read Cloud Run service"
+// @Router /deployments/{name}/refresh [post]
+func RefreshOneByName(c *gin.Context) {
+	userClaims := c.MustGet("UserClaims").(*sharedUtils.UserClaims)
+	pool := c.MustGet("Pool").(*pgxpool.Pool)
+	reqCtx := c.Request.Context()
+
+	deploymentName := c.Param("name")
+	environment, ok := environmentParam(c)
+	if !ok {
+		return
+	}
+
+	deployment, err := scanDeployment(pool.QueryRow(reqCtx, "SELECT "+deploymentColumns+" FROM deployments WHERE name = $1 AND user_id = $2 AND environment = $3 AND deleted_at IS NULL", deploymentName, userClaims.UserMetadata.AppUser.Id, environment))
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusNotFound, gin.H{
+			"error": "deployment " + deploymentName + " not found",
+		})
+		return
+	}
+
+	// A running update would show up as drift, so wait for it to finish
+	pending, err := hasPendingProvisioningJob(reqCtx, pool, deployment.Id)
+	if err != nil {
+		slog.Error("Failed to check for pending provisioning jobs", "deployment_id", deployment.Id, "error", err)
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{
+			"error": "failed to check for pending provisioning jobs",
+		})
+		return
+	}
+	if pending {
+		c.AbortWithStatusJSON(http.StatusConflict, gin.H{
+			"error": "deployment " + deploymentName + " has a provisioning job in progress, try again once it completes",
+		})
+		return
+	}
+
+	ctx := context.Background()
+
+	servicesClient, err := newCloudRunServices(ctx)
+	if err != nil {
+		abortWithCloudRunError(c, &CloudRunError{Operation: "NewServicesClient", Err: err})
+		return
+	}
+	defer servicesClient.Close()
+
+	// Multi-region deployments are checked in their primary region
+	serviceName := regionalServiceName(primaryRegion(deployment), deployment.Id)
+	service, err := withTransientRetry(ctx, "GetService", func() (*runpb.Service, error) {
+		return servicesClient.GetService(ctx, serviceName)
+	})
+	if err != nil {
+		abortWithCloudRunError(c, &CloudRunError{Operation: "GetService", Service: serviceName, Err: err})
+		return
+	}
+
+	drift := detectDrift(deployment, service)
+
+	c.JSON(http.StatusOK, DeploymentRefreshResponse{
+		Name:      deploymentName,
+		Drifted:   len(drift) > 0,
+		Drift:     drift,
+		CheckedAt: time.Now().UTC().Format(time.RFC3339),
+	})
+}
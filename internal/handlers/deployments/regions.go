@@ -0,0 +1,162 @@
+package deployments
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"maps"
+	"path"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+
+	runpb "cloud.google.com/go/run/apiv2/runpb"
+	"github.com/0p5dev/controller/internal/config"
+	"github.com/0p5dev/controller/internal/models"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+const (
+	maxDeploymentRegions = 10
+	// unavailableServiceUrl is recorded when Cloud Run doesn't report a URL for a new service
+	unavailableServiceUrl = "URL not available"
+)
+
+var regionPattern = regexp.MustCompile(`^[a-z]+-[a-z]+[0-9]+$`)
+
+// resolveRegions validates the requested regions, defaulting to GCP_REGION. The first region is the primary one,
+// whose URL is the deployment's url.
+func resolveRegions(regions []string) ([]string, error) {
+	if len(regions) == 0 {
+		return []string{config.Get().GcpRegion}, nil
+	}
+	if len(regions) > maxDeploymentRegions {
+		return nil, fmt.Errorf("at most %d regions are allowed", maxDeploymentRegions)
+	}
+
+	var resolved []string
+	for _, region := range regions {
+		if !regionPattern.MatchString(region) {
+			return nil, fmt.Errorf("region %q is not a valid Cloud Run region such as us-central1", region)
+		}
+		if slices.Contains(resolved, region) {
+			return nil, fmt.Errorf("region %s is listed more than once", region)
+		}
+		resolved = append(resolved, region)
+	}
+	return resolved, nil
+}
+
+// deploymentRegions lists the regions a deployment's services run in, primary first. Deployments created before
+// multi-region support have no region URLs and run only in GCP_REGION.
+func deploymentRegions(deployment models.Deployment) []string {
+	if len(deployment.RegionUrls) == 0 {
+		return []string{config.Get().GcpRegion}
+	}
+	primary := primaryRegion(deployment)
+	regions := []string{primary}
+	for _, region := range slices.Sorted(maps.Keys(deployment.RegionUrls)) {
+		if region != primary {
+			regions = append(regions, region)
+		}
+	}
+	return regions
+}
+
+// primaryRegion is the region whose URL is the deployment's url
+func primaryRegion(deployment models.Deployment) string {
+	for _, region := range slices.Sorted(maps.Keys(deployment.RegionUrls)) {
+		if deployment.RegionUrls[region] == deployment.Url {
+			return region
+		}
+	}
+	return config.Get().GcpRegion
+}
+
+// deploymentServiceId is the Cloud Run service id, and provisioning job resource id, of a user's deployment.
+// Every (user, name, environment) gets its own id: the id embeds the full user id rather than a truncated hash, and
+// since names and environments are shorter than a user id and user ids have no hyphens, the user id splits an id
+// back into exactly one name and environment. Deployments in the default environment keep the id they had before
+// environments existed; other environments follow the user id.
+func deploymentServiceId(deploymentName string, environment string, userId string) string {
+	if environment == defaultEnvironment {
+		return fmt.Sprintf("%s-%s", deploymentName, userId)
+	}
+	return fmt.Sprintf("%s-%s-%s", deploymentName, userId, environment)
+}
+
+func regionalServiceName(region string, serviceId string) string {
+	return fmt.Sprintf("projects/%s/locations/%s/services/%s", config.Get().GcpProjectId, region, serviceId)
+}
+
+// provisionRegionalService creates a deployment's Cloud Run service in one region and makes it public, returning its
+// URL and the name of its first revision. A service that fails partway is deleted so no unusable service is left behind.
+func provisionRegionalService(ctx context.Context, logger *slog.Logger, events *deploymentEventLog, servicesClient cloudRunServices, region string, serviceId string, userId string, settings revisionSettings) (string, string, error) {
+	parent := fmt.Sprintf("projects/%s/locations/%s", config.Get().GcpProjectId, region)
+	serviceFullName := regionalServiceName(region, serviceId)
+
+	template := buildRevisionTemplate(settings)
+	template.ServiceAccount = config.Get().ServiceAccountEmail
+
+	serviceSpec := &runpb.Service{
+		Labels: map[string]string{
+			"created_by": "0p5dev_controller",
+			"user":       "user-" + userId,
+		},
+		// Autoscaling lives only on the revision template; a service-level Scaling block duplicates it and
+		// a service-level min_instance_count overrides the template's, so it is deliberately left unset
+		Template: template,
+		Ingress:  buildIngress(settings),
+	}
+
+	started := time.Now()
+	service, err := servicesClient.CreateService(ctx, &runpb.CreateServiceRequest{
+		Parent:    parent,
+		Service:   serviceSpec,
+		ServiceId: serviceId,
+	})
+	events.record(region, "create_service", started, err)
+	if err != nil {
+		logger.Error("Failed to create Cloud Run service", "region", region, "error", err.Error())
+		// A service that already exists isn't this job's to clean up
+		if status.Code(err) != codes.AlreadyExists {
+			deleteCloudRunServiceIfExists(ctx, servicesClient, serviceFullName)
+		}
+		return "", "", fmt.Errorf("%w%s", newCloudRunError("CreateService", serviceFullName, err), vpcErrorHint(settings, region, err))
+	}
+
+	// Ensure public access using Cloud Run service IAM policy. The whole read-modify-write is retried, since a
+	// concurrent policy change makes SetIamPolicy fail with Aborted on the stale etag.
+	started = time.Now()
+	_, err = withTransientRetry(ctx, "SetIamPolicy", func() (struct{}, error) {
+		return struct{}{}, ensurePublicInvokerAccess(ctx, servicesClient, serviceFullName)
+	})
+	events.record(region, "set_iam_policy", started, err)
+	if err != nil {
+		logger.Error("Failed to set IAM policy", "operation", "SetIamPolicy", "region", region, "error", err.Error())
+		// Delete the service since it's not publicly accessible and likely unusable for the user
+		deleteCloudRunServiceIfExists(ctx, servicesClient, serviceFullName)
+		return "", "", newCloudRunError("SetIamPolicy", serviceFullName, err)
+	}
+
+	var revision string
+	if service != nil {
+		revision = path.Base(service.LatestCreatedRevision)
+	}
+	if service == nil || service.Uri == "" {
+		logger.Warn("serviceUrl not found in Cloud Run response", "service", serviceFullName)
+		return unavailableServiceUrl, revision, nil
+	}
+	return service.Uri, revision, nil
+}
+
+// describeRegionFailures summarizes per-region errors in a stable order
+func describeRegionFailures(regionErrors map[string]string) string {
+	var failures []string
+	for _, region := range slices.Sorted(maps.Keys(regionErrors)) {
+		failures = append(failures, region+": "+regionErrors[region])
+	}
+	return strings.Join(failures, "; ")
+}
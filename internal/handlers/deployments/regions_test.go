@@ -0,0 +1,68 @@
+package deployments
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/oklog/ulid/v2"
+)
+
+func TestDeploymentServiceIdsAreDistinct(t *testing.T) {
+	users := []string{strings.ToLower(ulid.Make().String()), strings.ToLower(ulid.Make().String())}
+	// Names and environments that line up when joined with hyphens
+	deployments := []struct{ name, environment string }{
+		{"api", defaultEnvironment},
+		{"api", "staging"},
+		{"api-staging", defaultEnvironment},
+		{"api-staging", "production"},
+		{"api", "staging-production"},
+		{"a", "b-c"},
+		{"a-b", "c"},
+		{"a-b-c", defaultEnvironment},
+	}
+
+	type owner struct{ userId, name, environment string }
+	owners := map[string]owner{}
+	for _, userId := range users {
+		for _, deployment := range deployments {
+			serviceId := deploymentServiceId(deployment.name, deployment.environment, userId)
+			if !strings.Contains(serviceId, "-"+userId) {
+				t.Errorf("service id %s doesn't contain its user id %s", serviceId, userId)
+			}
+			if other, ok := owners[serviceId]; ok {
+				t.Errorf("service id %s is shared by %+v and %+v", serviceId, other, owner{userId, deployment.name, deployment.environment})
+			}
+			owners[serviceId] = owner{userId, deployment.name, deployment.environment}
+		}
+	}
+}
+
+func TestDeploymentServiceIdDoesNotCollideForOneUser(t *testing.T) {
+	userId := strings.ToLower(ulid.Make().String())
+	deployments := []struct{ name, environment string }{
+		{"app", "staging"},
+		{"app-staging", defaultEnvironment},
+	}
+
+	first := deploymentServiceId(deployments[0].name, deployments[0].environment, userId)
+	second := deploymentServiceId(deployments[1].name, deployments[1].environment, userId)
+	if first == second {
+		t.Errorf("%s in %s and %s in %s share service id %s", deployments[0].name, deployments[0].environment, deployments[1].name, deployments[1].environment, first)
+	}
+}
+
+func TestDeploymentServiceIdFitsCloudRun(t *testing.T) {
+	userId := ulid.Make().String()
+	longest := []string{
+		deploymentServiceId(strings.Repeat("a", 20), defaultEnvironment, userId),
+		deploymentServiceId(strings.Repeat("a", 10), strings.Repeat("b", maxNameAndEnvironmentLength-11), userId),
+	}
+	for _, serviceId := range longest {
+		if len(serviceId) > 49 {
+			t.Errorf("service id %s is %d characters, over Cloud Run's 49", serviceId, len(serviceId))
+		}
+	}
+	if deploymentServiceId("api", defaultEnvironment, userId) != "api-"+userId {
+		t.Error("default environment ids changed from the pre-environment format")
+	}
+}
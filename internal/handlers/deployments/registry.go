@@ -0,0 +1,125 @@
+package deployments
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/go-containerregistry/pkg/authn"
+	"github.com/google/go-containerregistry/pkg/name"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/google"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+	"github.com/google/go-containerregistry/pkg/v1/remote/transport"
+
+	"github.com/0p5dev/controller/internal/config"
+	"github.com/0p5dev/controller/internal/sharedUtils"
+)
+
+// RegistryCredentials authenticate the pre-flight image checks against an external registry such as Docker Hub or
+// GHCR. They are only used for the request and never stored.
+type RegistryCredentials struct {
+	Username string `json:"username" binding:"required"`
+	Password string `json:"password" binding:"required"` // password or access token
+}
+
+var (
+	errCredentialsForGoogleRegistry = errors.New("registry credentials are only used for external registries")
+	errImageNotPullable             = errors.New("cloud run cannot pull this image")
+	errRegistryNotAllowed           = errors.New("container image registry is not allowed")
+)
+
+// isGoogleRegistry reports whether a registry is Artifact Registry or Container Registry, which the controller and
+// Cloud Run reach with their own service accounts
+func isGoogleRegistry(registry string) bool {
+	return strings.HasSuffix(registry, "-docker.pkg.dev") || registry == "gcr.io" || strings.HasSuffix(registry, ".gcr.io")
+}
+
+// registryAuth picks how the controller authenticates to an image's registry: the given credentials, or the
+// controller's own Google credentials
+func registryAuth(credentials *RegistryCredentials) remote.Option {
+	if credentials == nil {
+		return remote.WithAuthFromKeychain(google.Keychain)
+	}
+	return remote.WithAuth(&authn.Basic{Username: credentials.Username, Password: credentials.Password})
+}
+
+// checkCloudRunPullAccess confirms Cloud Run will be able to pull an external image. Cloud Run pulls from Google
+// registries with its service agent, but from elsewhere only public Docker Hub images, anonymously.
+func checkCloudRunPullAccess(ctx context.Context, ref name.Reference) error {
+	registry := ref.Context().RegistryStr()
+	if isGoogleRegistry(registry) {
+		return nil
+	}
+	if registry != name.DefaultRegistry {
+		return fmt.Errorf("%w: %s is not a registry Cloud Run can pull from; create an Artifact Registry remote repository for it, with these credentials if it is private, and deploy the image through that repository", errImageNotPullable, registry)
+	}
+
+	_, err := withRegistryRetry(ctx, "HeadImage", func() (*v1.Descriptor, error) {
+		return remote.Head(ref, remote.WithAuth(authn.Anonymous), remote.WithContext(ctx), remote.WithRetryStatusCodes())
+	})
+	if err != nil {
+		var transportErr *transport.Error
+		if errors.As(err, &transportErr) {
+			switch transportErr.StatusCode {
+			case http.StatusNotFound, http.StatusUnauthorized, http.StatusForbidden:
+				return fmt.Errorf("%w: %s is private on Docker Hub and Cloud Run only pulls public Docker Hub images; create an Artifact Registry remote repository for Docker Hub with these credentials and deploy the image through that repository", errImageNotPullable, ref.Name())
+			}
+		}
+		return fmt.Errorf("failed to check public access to image %q: %w", ref.Name(), err)
+	}
+	return nil
+}
+
+// allowedImageRegistries lists the registry hosts deployments may use: ALLOWED_IMAGE_REGISTRIES, or by default the
+// registries of AR_REPO_URL, AR_REPO_URLS, and PUBLIC_IMAGE_PREFIXES. Docker Hub is listed as index.docker.io.
+func allowedImageRegistries() []string {
+	sources := sharedUtils.GetEnvList("ALLOWED_IMAGE_REGISTRIES")
+	if len(sources) == 0 {
+		sources = append([]string{config.Get().ArRepoUrl}, sharedUtils.GetEnvList("AR_REPO_URLS")...)
+		sources = append(sources, sharedUtils.GetEnvList("PUBLIC_IMAGE_PREFIXES")...)
+	}
+
+	registries := []string{}
+	for _, source := range sources {
+		host, _, _ := strings.Cut(source, "/")
+		registry, err := name.NewRegistry(host)
+		if err != nil || slices.Contains(registries, registry.RegistryStr()) {
+			continue
+		}
+		registries = append(registries, registry.RegistryStr())
+	}
+	return registries
+}
+
+// validateImageRegistry rejects malformed image references and images outside the allowed registries up front,
+// instead of leaving Cloud Run to fail on them
+func validateImageRegistry(image string) error {
+	ref, err := name.ParseReference(image)
+	if err != nil {
+		return fmt.Errorf("%w %q: %v", errInvalidImageReference, image, err)
+	}
+	if !slices.Contains(allowedImageRegistries(), ref.Context().RegistryStr()) {
+		return fmt.Errorf("%w: %s", errRegistryNotAllowed, ref.Context().RegistryStr())
+	}
+	return nil
+}
+
+// imageRegistryErrorResponse maps a validateImageRegistry error to a 400 response body
+func imageRegistryErrorResponse(err error) gin.H {
+	if errors.Is(err, errRegistryNotAllowed) {
+		return gin.H{
+			"error":              "container image registry is not allowed",
+			"message":            err.Error(),
+			"allowed_registries": allowedImageRegistries(),
+		}
+	}
+	return gin.H{
+		"error":   "invalid container image reference",
+		"message": err.Error(),
+	}
+}
@@ -0,0 +1,66 @@
+package deployments
+
+import (
+	"errors"
+	"slices"
+	"testing"
+
+	"github.com/0p5dev/controller/internal/config"
+)
+
+func useTestConfig(t *testing.T, cfg *config.Config) {
+	t.Helper()
+	config.Set(cfg)
+	t.Cleanup(func() { config.Set(nil) })
+}
+
+func TestValidateImageRegistryDefaults(t *testing.T) {
+	useTestConfig(t, &config.Config{ArRepoUrl: "us-central1-docker.pkg.dev/project/images"})
+	t.Setenv("ALLOWED_IMAGE_REGISTRIES", "")
+	t.Setenv("AR_REPO_URLS", "europe-west1-docker.pkg.dev/project/images")
+	t.Setenv("PUBLIC_IMAGE_PREFIXES", "docker.io/library/")
+
+	tests := []struct {
+		image string
+		want  error
+	}{
+		{"us-central1-docker.pkg.dev/project/images/api:v1", nil},
+		{"europe-west1-docker.pkg.dev/project/images/api:v1", nil},
+		{"nginx:latest", nil},
+		{"docker.io/library/nginx@sha256:0123456789abcdef0123456789abcdef0123456789abcdef0123456789abcdef", nil},
+		{"ghcr.io/someone/api:v1", errRegistryNotAllowed},
+		{"us-central1-docker.pkg.dev.example.com/project/images/api:v1", errRegistryNotAllowed},
+		{"asia-east1-docker.pkg.dev/project/images/api:v1", errRegistryNotAllowed},
+		{"Not An Image", errInvalidImageReference},
+	}
+	for _, tt := range tests {
+		err := validateImageRegistry(tt.image)
+		if (tt.want == nil && err != nil) || (tt.want != nil && !errors.Is(err, tt.want)) {
+			t.Errorf("validateImageRegistry(%q) = %v, want %v", tt.image, err, tt.want)
+		}
+	}
+}
+
+func TestValidateImageRegistryAllowlist(t *testing.T) {
+	useTestConfig(t, &config.Config{ArRepoUrl: "us-central1-docker.pkg.dev/project/images"})
+	t.Setenv("ALLOWED_IMAGE_REGISTRIES", "ghcr.io, docker.io")
+
+	if registries := allowedImageRegistries(); !slices.Equal(registries, []string{"ghcr.io", "index.docker.io"}) {
+		t.Errorf("allowedImageRegistries = %v", registries)
+	}
+	if err := validateImageRegistry("ghcr.io/someone/api:v1"); err != nil {
+		t.Errorf("allowlisted registry rejected: %v", err)
+	}
+	err := validateImageRegistry("us-central1-docker.pkg.dev/project/images/api:v1")
+	if !errors.Is(err, errRegistryNotAllowed) {
+		t.Fatalf("err = %v, want the AR registry left out once an allowlist is set", err)
+	}
+
+	response := imageRegistryErrorResponse(err)
+	if response["error"] != "container image registry is not allowed" {
+		t.Errorf("error = %v", response["error"])
+	}
+	if allowed, _ := response["allowed_registries"].([]string); !slices.Equal(allowed, []string{"ghcr.io", "index.docker.io"}) {
+		t.Errorf("allowed_registries = %v", response["allowed_registries"])
+	}
+}
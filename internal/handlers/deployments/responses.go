@@ -0,0 +1,59 @@
+package deployments
+
+import (
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+// DeploymentResponse is the body of a successful create, update, or delete. Creates and updates run as background
+// jobs, so their status is pending and the job status stream reports the outcome; the URL and revision are only
+// included when they are already known. A job's new revision is reported in its callback and on the deployment.
+type DeploymentResponse struct {
+	Message       string   `json:"message"`
+	Name          string   `json:"name"`
+	Environment   string   `json:"environment"`
+	Action        string   `json:"action"` // created | updated | unchanged | deleted
+	Status        string   `json:"status"` // pending while a job runs, otherwise succeeded
+	JobId         string   `json:"job_id,omitempty"`
+	Url           string   `json:"url,omitempty"`
+	Revision      string   `json:"revision,omitempty"`
+	ChangedFields []string `json:"changed_fields,omitempty"` // request fields an update changes
+	Warnings      []string `json:"warnings,omitempty"`
+}
+
+// deploymentResult is the outcome of createDeployment or updateDeployment: the response for a job that was queued or a
+// change that needed none, or the status and error body the request was turned away with. Handlers send it as is,
+// while an import reports one per spec.
+type deploymentResult struct {
+	Status   int
+	Response DeploymentResponse
+	Error    gin.H
+	// RetryAfter is the Retry-After, in seconds, of a request turned away while the operation queue is full
+	RetryAfter int
+}
+
+func deploymentError(status int, body gin.H) deploymentResult {
+	return deploymentResult{Status: status, Error: body}
+}
+
+// respond sends the result as the response to c
+func (r deploymentResult) respond(c *gin.Context) {
+	if r.Error != nil {
+		if r.RetryAfter > 0 {
+			c.Header("Retry-After", strconv.Itoa(r.RetryAfter))
+		}
+		c.AbortWithStatusJSON(r.Status, r.Error)
+		return
+	}
+	c.JSON(r.Status, r.Response)
+}
+
+// errorMessage is the error of a result that was turned away, with its detail message when it has one
+func (r deploymentResult) errorMessage() string {
+	message, _ := r.Error["error"].(string)
+	if detail, ok := r.Error["message"].(string); ok && detail != "" {
+		message += ": " + detail
+	}
+	return message
+}
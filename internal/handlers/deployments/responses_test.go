@@ -0,0 +1,43 @@
+package deployments
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestDeploymentResultRespond(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	recorder := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(recorder)
+	deploymentResult{Status: http.StatusAccepted, Response: DeploymentResponse{Name: "api", Action: "created", JobId: "job1"}}.respond(c)
+	var response DeploymentResponse
+	if err := json.Unmarshal(recorder.Body.Bytes(), &response); err != nil || recorder.Code != http.StatusAccepted || response.JobId != "job1" {
+		t.Errorf("status = %d, body = %s; want the 202 response", recorder.Code, recorder.Body.String())
+	}
+
+	recorder = httptest.NewRecorder()
+	c, _ = gin.CreateTestContext(recorder)
+	operationsFullResult().respond(c)
+	if recorder.Code != http.StatusServiceUnavailable || recorder.Header().Get("Retry-After") != "30" {
+		t.Errorf("status = %d, Retry-After = %q; want a 503 with Retry-After", recorder.Code, recorder.Header().Get("Retry-After"))
+	}
+	if !c.IsAborted() {
+		t.Error("an error result didn't abort the request")
+	}
+}
+
+func TestDeploymentResultErrorMessage(t *testing.T) {
+	result := deploymentError(http.StatusBadRequest, gin.H{"error": "invalid environment", "message": "too long"})
+	if message := result.errorMessage(); message != "invalid environment: too long" {
+		t.Errorf("errorMessage = %q", message)
+	}
+	if message := versionMismatchResult("api", time.Time{}).errorMessage(); message != "deployment api has changed since it was read" {
+		t.Errorf("errorMessage = %q", message)
+	}
+}
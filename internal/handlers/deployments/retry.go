@@ -0,0 +1,100 @@
+package deployments
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"math/rand"
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/0p5dev/controller/internal/sharedUtils"
+	"github.com/google/go-containerregistry/pkg/v1/remote/transport"
+	"google.golang.org/api/googleapi"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+const (
+	retryBaseDelay = 500 * time.Millisecond
+	retryMaxDelay  = 10 * time.Second
+)
+
+// withTransientRetry runs a Cloud Run API call, retrying with exponential backoff and full jitter while it fails with
+// a transient error such as a rate limit or an unavailable backend. Attempts are capped by GCP_RETRY_MAX_ATTEMPTS
+// (default 3), and no retry is scheduled past ctx's deadline. Other errors, like invalid config or permission
+// denied, are returned immediately.
+func withTransientRetry[T any](ctx context.Context, operation string, fn func() (T, error)) (T, error) {
+	return retryWithBackoff(ctx, operation, sharedUtils.GetEnvInt("GCP_RETRY_MAX_ATTEMPTS", 3), retryBaseDelay, isTransientGcpError, fn)
+}
+
+// withRegistryRetry is withTransientRetry for container registry calls, retrying rate limits, server errors, and
+// network failures but never a missing or inaccessible image. Attempts are capped by REGISTRY_RETRY_MAX_ATTEMPTS
+// (default 3), and the first backoff is REGISTRY_RETRY_BASE_DELAY_MS (default 500).
+func withRegistryRetry[T any](ctx context.Context, operation string, fn func() (T, error)) (T, error) {
+	baseDelay := time.Duration(sharedUtils.GetEnvInt("REGISTRY_RETRY_BASE_DELAY_MS", 500)) * time.Millisecond
+	return retryWithBackoff(ctx, operation, sharedUtils.GetEnvInt("REGISTRY_RETRY_MAX_ATTEMPTS", 3), baseDelay, isTransientRegistryError, fn)
+}
+
+func retryWithBackoff[T any](ctx context.Context, operation string, maxAttempts int, baseDelay time.Duration, isTransient func(context.Context, error) bool, fn func() (T, error)) (T, error) {
+	if maxAttempts < 1 {
+		maxAttempts = 1
+	}
+	baseDelay = max(baseDelay, time.Millisecond)
+
+	for attempt := 1; ; attempt++ {
+		result, err := fn()
+		if err == nil || attempt >= maxAttempts || !isTransient(ctx, err) {
+			return result, err
+		}
+
+		delay := min(baseDelay<<(attempt-1), retryMaxDelay)
+		delay = time.Duration(rand.Int63n(int64(delay))) + 1
+		if deadline, ok := ctx.Deadline(); ok && time.Until(deadline) < delay {
+			return result, err
+		}
+
+		slog.Warn("Transient error, retrying", "operation", operation, "attempt", attempt, "max_attempts", maxAttempts, "delay", delay.String(), "error", err.Error())
+
+		timer := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return result, err
+		case <-timer.C:
+		}
+	}
+}
+
+// isTransientGcpError reports whether a GCP API error is worth retrying. A deadline is only transient when it was
+// the server's, not the caller's context running out.
+func isTransientGcpError(ctx context.Context, err error) bool {
+	// REST APIs, such as the v1 admin API used for domain mappings, report HTTP status codes instead
+	var apiErr *googleapi.Error
+	if errors.As(err, &apiErr) {
+		return apiErr.Code == http.StatusTooManyRequests || apiErr.Code == http.StatusServiceUnavailable
+	}
+
+	switch status.Code(err) {
+	case codes.Unavailable, codes.ResourceExhausted, codes.Aborted:
+		return true
+	case codes.DeadlineExceeded:
+		return ctx.Err() == nil
+	default:
+		return false
+	}
+}
+
+// isTransientRegistryError reports whether a container registry error is worth retrying. Not found, unauthorized,
+// and other client errors are final.
+func isTransientRegistryError(ctx context.Context, err error) bool {
+	var transportErr *transport.Error
+	if errors.As(err, &transportErr) {
+		return transportErr.StatusCode == http.StatusTooManyRequests || transportErr.StatusCode == http.StatusRequestTimeout ||
+			transportErr.StatusCode >= http.StatusInternalServerError
+	}
+
+	var netErr net.Error
+	return errors.As(err, &netErr) && ctx.Err() == nil
+}
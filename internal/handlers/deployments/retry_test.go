@@ -0,0 +1,111 @@
+package deployments
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+var errTransient = errors.New("transient")
+
+func isTestTransient(_ context.Context, err error) bool {
+	return errors.Is(err, errTransient)
+}
+
+func TestRetryWithBackoffRetriesTransientErrors(t *testing.T) {
+	calls := 0
+	result, err := retryWithBackoff(context.Background(), "Test", 3, time.Millisecond, isTestTransient, func() (string, error) {
+		calls++
+		if calls < 3 {
+			return "", errTransient
+		}
+		return "ok", nil
+	})
+	if err != nil || result != "ok" {
+		t.Fatalf("retryWithBackoff = %q, %v; want ok", result, err)
+	}
+	if calls != 3 {
+		t.Errorf("calls = %d, want 3", calls)
+	}
+}
+
+func TestRetryWithBackoffReturnsPermanentErrorsImmediately(t *testing.T) {
+	errPermanent := errors.New("permission denied")
+	calls := 0
+	_, err := retryWithBackoff(context.Background(), "Test", 5, time.Millisecond, isTestTransient, func() (struct{}, error) {
+		calls++
+		return struct{}{}, errPermanent
+	})
+	if !errors.Is(err, errPermanent) {
+		t.Fatalf("err = %v, want %v", err, errPermanent)
+	}
+	if calls != 1 {
+		t.Errorf("calls = %d, want 1", calls)
+	}
+}
+
+func TestRetryWithBackoffStopsAtMaxAttempts(t *testing.T) {
+	for _, maxAttempts := range []int{0, 1, 4} {
+		calls := 0
+		_, err := retryWithBackoff(context.Background(), "Test", maxAttempts, time.Millisecond, isTestTransient, func() (struct{}, error) {
+			calls++
+			return struct{}{}, errTransient
+		})
+		if !errors.Is(err, errTransient) {
+			t.Errorf("maxAttempts %d: err = %v, want the last transient error", maxAttempts, err)
+		}
+		if want := max(maxAttempts, 1); calls != want {
+			t.Errorf("maxAttempts %d: calls = %d, want %d", maxAttempts, calls, want)
+		}
+	}
+}
+
+func TestRetryWithBackoffStopsAtDeadline(t *testing.T) {
+	// Backoffs of up to 5s and more don't fit in the deadline, so retrying stops long before the attempt cap
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	calls := 0
+	started := time.Now()
+	_, err := retryWithBackoff(ctx, "Test", 10, 5*time.Second, func(context.Context, error) bool { return true }, func() (struct{}, error) {
+		calls++
+		return struct{}{}, errTransient
+	})
+	if !errors.Is(err, errTransient) {
+		t.Fatalf("err = %v, want the transient error", err)
+	}
+	if calls >= 10 {
+		t.Errorf("calls = %d, want retries cut off by the deadline", calls)
+	}
+	if elapsed := time.Since(started); elapsed > time.Second {
+		t.Errorf("took %s, want it to give up by the deadline", elapsed)
+	}
+}
+
+func TestIsTransientGcpError(t *testing.T) {
+	canceled, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	tests := []struct {
+		name string
+		ctx  context.Context
+		err  error
+		want bool
+	}{
+		{"unavailable", context.Background(), status.Error(codes.Unavailable, ""), true},
+		{"rate limited", context.Background(), status.Error(codes.ResourceExhausted, ""), true},
+		{"server deadline", context.Background(), status.Error(codes.DeadlineExceeded, ""), true},
+		{"caller deadline", canceled, status.Error(codes.DeadlineExceeded, ""), false},
+		{"permission denied", context.Background(), status.Error(codes.PermissionDenied, ""), false},
+		{"invalid argument", context.Background(), status.Error(codes.InvalidArgument, ""), false},
+	}
+	for _, tt := range tests {
+		if got := isTransientGcpError(tt.ctx, tt.err); got != tt.want {
+			t.Errorf("%s: isTransientGcpError = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,53 @@
+package deployments
+
+import (
+	"context"
+	"errors"
+	"path"
+	"time"
+
+	runpb "cloud.google.com/go/run/apiv2/runpb"
+
+	"github.com/0p5dev/controller/internal/sharedUtils"
+)
+
+// readyWaitTimeout bounds how long an update with wait_for_ready waits for its new revisions, across all regions
+// (READY_WAIT_TIMEOUT_SECONDS, default 300)
+func readyWaitTimeout() time.Duration {
+	return time.Duration(sharedUtils.GetEnvInt("READY_WAIT_TIMEOUT_SECONDS", 300)) * time.Second
+}
+
+// waitForLatestRevisionReady polls a service until its latest created revision is also its latest ready one, so
+// the new version is actually serving. It returns that revision's name and false once ctx is done while the
+// revision is still rolling out, and an error if the rollout failed.
+func waitForLatestRevisionReady(ctx context.Context, servicesClient cloudRunServices, serviceFullName string) (string, bool, error) {
+	ticker := time.NewTicker(5 * time.Second)
+	defer ticker.Stop()
+
+	var revision string
+	for {
+		service, err := withTransientRetry(ctx, "GetService", func() (*runpb.Service, error) {
+			return servicesClient.GetService(ctx, serviceFullName)
+		})
+		if err != nil {
+			if ctx.Err() != nil {
+				return revision, false, nil
+			}
+			return revision, false, newCloudRunError("GetService", serviceFullName, err)
+		}
+
+		revision = path.Base(service.LatestCreatedRevision)
+		if service.LatestCreatedRevision != "" && service.LatestCreatedRevision == service.LatestReadyRevision {
+			return revision, true, nil
+		}
+		if condition := service.TerminalCondition; condition != nil && condition.State == runpb.Condition_CONDITION_FAILED {
+			return revision, false, newCloudRunError("WaitForReady", serviceFullName, errors.New(condition.Message))
+		}
+
+		select {
+		case <-ctx.Done():
+			return revision, false, nil
+		case <-ticker.C:
+		}
+	}
+}
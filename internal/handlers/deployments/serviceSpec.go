@@ -0,0 +1,397 @@
+package deployments
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+
+	runpb "cloud.google.com/go/run/apiv2/runpb"
+	"github.com/0p5dev/controller/internal/models"
+	"google.golang.org/protobuf/types/known/durationpb"
+)
+
+const (
+	defaultMaxConcurrency        = 80
+	maxMaxConcurrency            = 1000
+	defaultRequestTimeoutSeconds = 300
+	maxRequestTimeoutSeconds     = 3600
+)
+
+// versionAnnotation carries a deployment's version on its Cloud Run revisions, so the console shows which commit
+// each revision runs. Annotations are used because versions don't fit the label value character set.
+const versionAnnotation = "0p5dev.io/version"
+
+// revisionSettings holds every deployment setting that is applied to a Cloud Run revision template, plus the
+// service-level ingress. Create and update both build their service from it, so an update never drops a setting
+// it didn't change.
+type revisionSettings struct {
+	Image                string
+	Port                 int
+	MinInstances         int
+	MaxInstances         int
+	CpuAlwaysAllocated   bool
+	StartupCpuBoost      bool
+	MaxConcurrency       int
+	RequestTimeout       int // seconds
+	VpcConnector         *string
+	VpcNetwork           *string
+	VpcSubnet            *string
+	VpcEgress            *string // all-traffic | private-ranges-only
+	Sidecars             []models.SidecarContainer
+	ExecutionEnvironment *string // gen1 | gen2; nil leaves the choice to Cloud Run
+	Volumes              []models.Volume
+	Ingress              *string // all | internal | internal-and-cloud-load-balancing; nil is all
+	Version              *string // recorded on the revision as the versionAnnotation
+	Cpu                  *string // primary container limits; nil leaves them to Cloud Run
+	Memory               *string
+}
+
+// Cloud Run takes CPU as whole or fractional vCPUs or millicpu, and memory as a Kubernetes quantity
+var (
+	cpuLimitPattern    = regexp.MustCompile(`^([0-9]+(\.[0-9]+)?|[1-9][0-9]*m)$`)
+	memoryLimitPattern = regexp.MustCompile(`^[1-9][0-9]*(Mi|Gi|M|G)$`)
+)
+
+var executionEnvironmentValues = map[string]runpb.ExecutionEnvironment{
+	"gen1": runpb.ExecutionEnvironment_EXECUTION_ENVIRONMENT_GEN1,
+	"gen2": runpb.ExecutionEnvironment_EXECUTION_ENVIRONMENT_GEN2,
+}
+
+var ingressValues = map[string]runpb.IngressTraffic{
+	"all":                               runpb.IngressTraffic_INGRESS_TRAFFIC_ALL,
+	"internal":                          runpb.IngressTraffic_INGRESS_TRAFFIC_INTERNAL_ONLY,
+	"internal-and-cloud-load-balancing": runpb.IngressTraffic_INGRESS_TRAFFIC_INTERNAL_LOAD_BALANCER,
+}
+
+var vpcEgressValues = map[string]runpb.VpcAccess_VpcEgress{
+	"all-traffic":         runpb.VpcAccess_ALL_TRAFFIC,
+	"private-ranges-only": runpb.VpcAccess_PRIVATE_RANGES_ONLY,
+}
+
+func buildRevisionTemplate(settings revisionSettings) *runpb.RevisionTemplate {
+	resources := &runpb.ResourceRequirements{
+		// CPU is only allocated during requests unless the user opts into always-allocated CPU
+		CpuIdle:         !settings.CpuAlwaysAllocated,
+		StartupCpuBoost: settings.StartupCpuBoost,
+	}
+
+	primary := &runpb.Container{
+		Image: settings.Image,
+		// Limits apply to the primary container only, so sidecars keep Cloud Run's defaults
+		Resources: &runpb.ResourceRequirements{
+			CpuIdle:         resources.CpuIdle,
+			StartupCpuBoost: resources.StartupCpuBoost,
+			Limits:          buildResourceLimits(settings),
+		},
+		VolumeMounts: buildVolumeMounts(settings.Volumes),
+	}
+	if ingressSidecar(settings.Sidecars) == nil {
+		primary.Ports = []*runpb.ContainerPort{
+			{ContainerPort: int32(settings.Port)},
+		}
+	}
+
+	containers := []*runpb.Container{primary}
+	if len(settings.Sidecars) > 0 {
+		// Containers must be named once a revision has more than one
+		primary.Name = primaryContainerName
+		for _, sidecar := range settings.Sidecars {
+			containers = append(containers, buildSidecarContainer(sidecar, resources))
+		}
+	}
+
+	template := &runpb.RevisionTemplate{
+		VpcAccess:                     buildVpcAccess(settings),
+		MaxInstanceRequestConcurrency: int32(settings.MaxConcurrency),
+		Timeout:                       durationpb.New(time.Duration(settings.RequestTimeout) * time.Second),
+		Scaling: &runpb.RevisionScaling{
+			MinInstanceCount: int32(settings.MinInstances),
+			MaxInstanceCount: int32(settings.MaxInstances),
+		},
+		Containers: containers,
+		Volumes:    buildVolumes(settings.Volumes),
+	}
+	if settings.ExecutionEnvironment != nil {
+		template.ExecutionEnvironment = executionEnvironmentValues[*settings.ExecutionEnvironment]
+	}
+	if settings.Version != nil {
+		template.Annotations = map[string]string{versionAnnotation: *settings.Version}
+	}
+	return template
+}
+
+// buildResourceLimits returns nil when neither limit is set, which also resets them to Cloud Run's defaults on update
+func buildResourceLimits(settings revisionSettings) map[string]string {
+	if settings.Cpu == nil && settings.Memory == nil {
+		return nil
+	}
+	limits := map[string]string{}
+	if settings.Cpu != nil {
+		limits["cpu"] = *settings.Cpu
+	}
+	if settings.Memory != nil {
+		limits["memory"] = *settings.Memory
+	}
+	return limits
+}
+
+// resourceLimit picks a deployment's CPU or memory limit: the requested value, else the operator's default
+// (DEFAULT_CPU or DEFAULT_MEMORY), else nil for Cloud Run's default
+func resourceLimit(requested *string, fallback string) *string {
+	if requested != nil && *requested != "" {
+		return requested
+	}
+	if fallback != "" {
+		return &fallback
+	}
+	return nil
+}
+
+// buildIngress maps the ingress setting to Cloud Run's; unset is sent as all, which also resets it on update
+func buildIngress(settings revisionSettings) runpb.IngressTraffic {
+	if settings.Ingress == nil {
+		return runpb.IngressTraffic_INGRESS_TRAFFIC_ALL
+	}
+	return ingressValues[*settings.Ingress]
+}
+
+// currentRevisionSettings reconstructs the settings a deployment is currently running with from its stored row
+func currentRevisionSettings(deployment models.Deployment) revisionSettings {
+	image := deployment.ContainerImage
+	if deployment.ImageDigest != nil {
+		image = *deployment.ImageDigest
+	}
+	return revisionSettings{
+		Image:                image,
+		Port:                 deployment.Port,
+		MinInstances:         deployment.MinInstances,
+		MaxInstances:         deployment.MaxInstances,
+		CpuAlwaysAllocated:   deployment.CpuAlwaysAllocated,
+		StartupCpuBoost:      deployment.StartupCpuBoost,
+		MaxConcurrency:       deployment.MaxConcurrency,
+		RequestTimeout:       deployment.RequestTimeoutSeconds,
+		VpcConnector:         deployment.VpcConnector,
+		VpcNetwork:           deployment.VpcNetwork,
+		VpcSubnet:            deployment.VpcSubnet,
+		VpcEgress:            deployment.VpcEgress,
+		Sidecars:             deployment.Sidecars,
+		ExecutionEnvironment: deployment.ExecutionEnvironment,
+		Volumes:              deployment.Volumes,
+		Ingress:              deployment.Ingress,
+		Version:              deployment.Version,
+		Cpu:                  deployment.Cpu,
+		Memory:               deployment.Memory,
+	}
+}
+
+// revisionUpdateMask returns the update mask paths for the settings that differ between current and next.
+// An empty mask means the update is a no-op.
+func revisionUpdateMask(current, next revisionSettings) []string {
+	var paths []string
+	// Autoscaling is configured on the template only. Including "scaling" with no value clears the
+	// service-level block older deployments were created with, so it can't override the template.
+	if current.MinInstances != next.MinInstances || current.MaxInstances != next.MaxInstances {
+		paths = append(paths, "scaling")
+	}
+	if current.MinInstances != next.MinInstances {
+		paths = append(paths, "template.scaling.min_instance_count")
+	}
+	if current.MaxInstances != next.MaxInstances {
+		paths = append(paths, "template.scaling.max_instance_count")
+	}
+	if current.Image != next.Image || current.Port != next.Port ||
+		current.CpuAlwaysAllocated != next.CpuAlwaysAllocated || current.StartupCpuBoost != next.StartupCpuBoost ||
+		!sidecarsEqual(current.Sidecars, next.Sidecars) || !volumesEqual(current.Volumes, next.Volumes) ||
+		stringOrEmpty(current.Cpu) != stringOrEmpty(next.Cpu) || stringOrEmpty(current.Memory) != stringOrEmpty(next.Memory) {
+		paths = append(paths, "template.containers")
+	}
+	if !volumesEqual(current.Volumes, next.Volumes) {
+		paths = append(paths, "template.volumes")
+	}
+	if current.MaxConcurrency != next.MaxConcurrency {
+		paths = append(paths, "template.max_instance_request_concurrency")
+	}
+	if current.RequestTimeout != next.RequestTimeout {
+		paths = append(paths, "template.timeout")
+	}
+	if stringOrEmpty(current.VpcConnector) != stringOrEmpty(next.VpcConnector) ||
+		stringOrEmpty(current.VpcNetwork) != stringOrEmpty(next.VpcNetwork) ||
+		stringOrEmpty(current.VpcSubnet) != stringOrEmpty(next.VpcSubnet) ||
+		stringOrEmpty(current.VpcEgress) != stringOrEmpty(next.VpcEgress) {
+		paths = append(paths, "template.vpc_access")
+	}
+	if stringOrEmpty(current.ExecutionEnvironment) != stringOrEmpty(next.ExecutionEnvironment) {
+		paths = append(paths, "template.execution_environment")
+	}
+	if stringOrEmpty(current.Ingress) != stringOrEmpty(next.Ingress) {
+		paths = append(paths, "ingress")
+	}
+	if stringOrEmpty(current.Version) != stringOrEmpty(next.Version) {
+		paths = append(paths, "template.annotations")
+	}
+
+	if len(paths) > 0 {
+		// Route all traffic to the new revision, including after a previous rollback pinned an older one
+		paths = append(paths, "traffic")
+	}
+	return paths
+}
+
+// changedSettings names the request fields whose values differ between current and next, for reporting to clients
+func changedSettings(current, next revisionSettings) []string {
+	var changed []string
+	addIf := func(field string, differs bool) {
+		if differs {
+			changed = append(changed, field)
+		}
+	}
+	addIf("image", current.Image != next.Image)
+	addIf("port", current.Port != next.Port)
+	addIf("min_instances", current.MinInstances != next.MinInstances)
+	addIf("max_instances", current.MaxInstances != next.MaxInstances)
+	addIf("cpu_always_allocated", current.CpuAlwaysAllocated != next.CpuAlwaysAllocated)
+	addIf("startup_cpu_boost", current.StartupCpuBoost != next.StartupCpuBoost)
+	addIf("max_concurrency", current.MaxConcurrency != next.MaxConcurrency)
+	addIf("request_timeout_seconds", current.RequestTimeout != next.RequestTimeout)
+	addIf("vpc_connector", stringOrEmpty(current.VpcConnector) != stringOrEmpty(next.VpcConnector))
+	addIf("vpc_network", stringOrEmpty(current.VpcNetwork) != stringOrEmpty(next.VpcNetwork))
+	addIf("vpc_subnet", stringOrEmpty(current.VpcSubnet) != stringOrEmpty(next.VpcSubnet))
+	addIf("vpc_egress", stringOrEmpty(current.VpcEgress) != stringOrEmpty(next.VpcEgress))
+	addIf("sidecars", !sidecarsEqual(current.Sidecars, next.Sidecars))
+	addIf("volumes", !volumesEqual(current.Volumes, next.Volumes))
+	addIf("execution_environment", stringOrEmpty(current.ExecutionEnvironment) != stringOrEmpty(next.ExecutionEnvironment))
+	addIf("ingress", stringOrEmpty(current.Ingress) != stringOrEmpty(next.Ingress))
+	addIf("version", stringOrEmpty(current.Version) != stringOrEmpty(next.Version))
+	addIf("cpu", stringOrEmpty(current.Cpu) != stringOrEmpty(next.Cpu))
+	addIf("memory", stringOrEmpty(current.Memory) != stringOrEmpty(next.Memory))
+	return changed
+}
+
+// buildVpcAccess returns nil when no VPC egress is configured, which also clears it on update
+func buildVpcAccess(settings revisionSettings) *runpb.VpcAccess {
+	if settings.VpcConnector == nil && settings.VpcNetwork == nil && settings.VpcSubnet == nil {
+		return nil
+	}
+
+	vpcAccess := &runpb.VpcAccess{}
+	if settings.VpcConnector != nil {
+		vpcAccess.Connector = *settings.VpcConnector
+	} else {
+		// Direct VPC egress attaches the revision to a network and/or subnet instead of a connector
+		networkInterface := &runpb.VpcAccess_NetworkInterface{}
+		if settings.VpcNetwork != nil {
+			networkInterface.Network = *settings.VpcNetwork
+		}
+		if settings.VpcSubnet != nil {
+			networkInterface.Subnetwork = *settings.VpcSubnet
+		}
+		vpcAccess.NetworkInterfaces = []*runpb.VpcAccess_NetworkInterface{networkInterface}
+	}
+	if settings.VpcEgress != nil {
+		vpcAccess.Egress = vpcEgressValues[*settings.VpcEgress]
+	}
+
+	return vpcAccess
+}
+
+// validateVpcSettings checks the effective VPC settings, after any update has been merged into the current ones, for
+// a deployment in the given regions
+func validateVpcSettings(settings revisionSettings, regions []string) error {
+	hasDirectEgress := settings.VpcNetwork != nil || settings.VpcSubnet != nil
+	if settings.VpcConnector != nil && hasDirectEgress {
+		return errors.New("vpc_connector cannot be combined with vpc_network or vpc_subnet")
+	}
+	// Connectors and subnets belong to one region, while a network spans them all
+	if len(regions) > 1 && (settings.VpcConnector != nil || settings.VpcSubnet != nil) {
+		return errors.New("vpc_connector and vpc_subnet are regional, so they can't be used by a deployment in more than one region; use vpc_network with a subnet of the same name in each region")
+	}
+	if settings.VpcEgress != nil {
+		if _, ok := vpcEgressValues[*settings.VpcEgress]; !ok {
+			return errors.New("vpc_egress must be all-traffic or private-ranges-only")
+		}
+		if settings.VpcConnector == nil && !hasDirectEgress {
+			return errors.New("vpc_egress requires vpc_connector, vpc_network, or vpc_subnet")
+		}
+	}
+	return nil
+}
+
+// vpcErrorHint points users at the likely cause when Cloud Run rejects a revision with VPC egress configured in region
+func vpcErrorHint(settings revisionSettings, region string, err error) string {
+	if buildVpcAccess(settings) == nil {
+		return ""
+	}
+	message := strings.ToLower(err.Error())
+	if !strings.Contains(message, "vpc") && !strings.Contains(message, "connector") && !strings.Contains(message, "network") {
+		return ""
+	}
+	return fmt.Sprintf(" (check that the VPC connector or network exists in region %s and is usable by the service)", region)
+}
+
+// optionalString treats an empty string as unset, so clients can clear an optional setting by sending ""
+func optionalString(value *string) *string {
+	if value == nil || *value == "" {
+		return nil
+	}
+	return value
+}
+
+// validateMaxConcurrency checks a requested per-instance concurrency against Cloud Run's limits
+func validateMaxConcurrency(maxConcurrency *int) error {
+	if maxConcurrency != nil && (*maxConcurrency < 1 || *maxConcurrency > maxMaxConcurrency) {
+		return fmt.Errorf("max_concurrency must be between 1 and %d", maxMaxConcurrency)
+	}
+	return nil
+}
+
+// validateExecutionEnvironment checks a requested execution environment; an empty string resets it to Cloud Run's default
+func validateExecutionEnvironment(executionEnvironment *string) error {
+	if executionEnvironment == nil || *executionEnvironment == "" {
+		return nil
+	}
+	if _, ok := executionEnvironmentValues[*executionEnvironment]; !ok {
+		return errors.New("execution_environment must be gen1 or gen2")
+	}
+	return nil
+}
+
+// validateIngress checks a requested ingress setting; an empty string resets it to all
+func validateIngress(ingress *string) error {
+	if ingress == nil || *ingress == "" {
+		return nil
+	}
+	if _, ok := ingressValues[*ingress]; !ok {
+		return errors.New("ingress must be all, internal, or internal-and-cloud-load-balancing")
+	}
+	return nil
+}
+
+// validateResourceLimits checks the format of requested CPU and memory limits; empty values reset them
+func validateResourceLimits(cpu, memory *string) error {
+	if cpu != nil && *cpu != "" && !cpuLimitPattern.MatchString(*cpu) {
+		return errors.New("cpu must be a number of vCPUs, e.g. 1 or 0.5, or millicpu, e.g. 500m")
+	}
+	if memory != nil && *memory != "" && !memoryLimitPattern.MatchString(*memory) {
+		return errors.New("memory must be a size in Mi or Gi, e.g. 512Mi or 2Gi")
+	}
+	return nil
+}
+
+// validateRequestTimeout checks a requested request timeout against Cloud Run's limits
+func validateRequestTimeout(requestTimeoutSeconds *int) error {
+	if requestTimeoutSeconds != nil && (*requestTimeoutSeconds < 1 || *requestTimeoutSeconds > maxRequestTimeoutSeconds) {
+		return fmt.Errorf("request_timeout_seconds must be between 1 and %d", maxRequestTimeoutSeconds)
+	}
+	return nil
+}
+
+// cpuAllocationWarnings flags settings that are valid but likely to surprise the user
+func cpuAllocationWarnings(settings revisionSettings) []string {
+	var warnings []string
+	if settings.CpuAlwaysAllocated && settings.MinInstances == 0 {
+		warnings = append(warnings, "cpu_always_allocated has little effect with min_instances set to 0, since idle services scale to zero; consider min_instances of at least 1")
+	}
+	return warnings
+}
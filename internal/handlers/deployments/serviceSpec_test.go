@@ -0,0 +1,83 @@
+package deployments
+
+import (
+	"slices"
+	"testing"
+
+	"github.com/0p5dev/controller/internal/models"
+)
+
+func testDeployment() models.Deployment {
+	connector := "projects/p/locations/us-central1/connectors/c"
+	port := 9000
+	return models.Deployment{
+		Id:                    "api-default-u1",
+		ContainerImage:        "us-docker.pkg.dev/project/repo/api:v1",
+		MinInstances:          1,
+		MaxInstances:          3,
+		Port:                  8080,
+		MaxConcurrency:        80,
+		RequestTimeoutSeconds: 300,
+		VpcConnector:          &connector,
+		Sidecars:              []models.SidecarContainer{{Name: "proxy", Image: "envoyproxy/envoy:v1.31", Port: &port}},
+		Volumes:               []models.Volume{{Name: "cache", Type: "emptyDir", MountPath: "/cache"}},
+	}
+}
+
+func TestRevisionUpdateMaskIsEmptyForUnchangedSettings(t *testing.T) {
+	deployment := testDeployment()
+	current := currentRevisionSettings(deployment)
+
+	// Rebuilt from another copy of the row, as an update repeating the current settings would be
+	next := currentRevisionSettings(testDeployment())
+	if mask := revisionUpdateMask(current, next); len(mask) > 0 {
+		t.Errorf("mask = %v, want an unchanged update to be a no-op", mask)
+	}
+	if changed := changedSettings(current, next); len(changed) > 0 {
+		t.Errorf("changed = %v, want none", changed)
+	}
+
+	// An empty optional setting is the same as an unset one
+	empty := ""
+	next.Ingress = &empty
+	if mask := revisionUpdateMask(current, next); len(mask) > 0 {
+		t.Errorf("mask = %v, want an empty ingress to be a no-op", mask)
+	}
+}
+
+func TestRevisionUpdateMaskIsEmptyForSameDigest(t *testing.T) {
+	digest := "us-docker.pkg.dev/project/repo/api@sha256:0123456789abcdef0123456789abcdef0123456789abcdef0123456789abcdef"
+	deployment := testDeployment()
+	deployment.ImageDigest = &digest
+	current := currentRevisionSettings(deployment)
+
+	// A new tag resolving to the running digest doesn't need a new revision
+	next := current
+	next.Image = digest
+	if mask := revisionUpdateMask(current, next); len(mask) > 0 {
+		t.Errorf("mask = %v, want the same digest to be a no-op", mask)
+	}
+}
+
+func TestRevisionUpdateMaskNamesChangedSettings(t *testing.T) {
+	current := currentRevisionSettings(testDeployment())
+	tests := []struct {
+		name   string
+		change func(*revisionSettings)
+		paths  []string
+	}{
+		{"image", func(s *revisionSettings) { s.Image = "us-docker.pkg.dev/project/repo/api:v2" }, []string{"template.containers"}},
+		{"max instances", func(s *revisionSettings) { s.MaxInstances = 5 }, []string{"scaling", "template.scaling.max_instance_count"}},
+		{"timeout", func(s *revisionSettings) { s.RequestTimeout = 60 }, []string{"template.timeout"}},
+		{"vpc connector removed", func(s *revisionSettings) { s.VpcConnector = nil }, []string{"template.vpc_access"}},
+		{"volumes", func(s *revisionSettings) { s.Volumes = nil }, []string{"template.containers", "template.volumes"}},
+	}
+	for _, tt := range tests {
+		next := current
+		tt.change(&next)
+		want := append(tt.paths, "traffic")
+		if mask := revisionUpdateMask(current, next); !slices.Equal(mask, want) {
+			t.Errorf("%s: mask = %v, want %v", tt.name, mask, want)
+		}
+	}
+}
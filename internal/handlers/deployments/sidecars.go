@@ -0,0 +1,97 @@
+package deployments
+
+import (
+	"errors"
+	"fmt"
+	"maps"
+	"regexp"
+	"slices"
+
+	runpb "cloud.google.com/go/run/apiv2/runpb"
+	"github.com/0p5dev/controller/internal/models"
+)
+
+const (
+	// primaryContainerName names the deployment's own container once sidecars make names necessary
+	primaryContainerName = "app"
+	// Cloud Run allows at most 10 containers per revision, including the primary one
+	maxSidecars = 9
+)
+
+// Container names must be DNS labels
+var sidecarNamePattern = regexp.MustCompile(`^[a-z]([-a-z0-9]{0,61}[a-z0-9])?$`)
+
+// validateSidecars checks requested sidecars. Exactly one container may receive traffic: the primary one by default,
+// or the single sidecar that sets a port, in which case the primary port must not be requested as well.
+func validateSidecars(sidecars []models.SidecarContainer, primaryPortRequested bool) error {
+	if len(sidecars) > maxSidecars {
+		return fmt.Errorf("at most %d sidecars are allowed", maxSidecars)
+	}
+
+	names := []string{primaryContainerName}
+	ingressCount := 0
+	for _, sidecar := range sidecars {
+		if !sidecarNamePattern.MatchString(sidecar.Name) {
+			return fmt.Errorf("sidecar name %q must be lowercase letters, digits, and hyphens, starting with a letter", sidecar.Name)
+		}
+		if slices.Contains(names, sidecar.Name) {
+			return fmt.Errorf("sidecar name %q is not unique (%q is reserved for the primary container)", sidecar.Name, primaryContainerName)
+		}
+		names = append(names, sidecar.Name)
+
+		if sidecar.Image == "" {
+			return fmt.Errorf("sidecar %s requires an image", sidecar.Name)
+		}
+		if sidecar.Port != nil {
+			if *sidecar.Port < 1 || *sidecar.Port > 65535 {
+				return fmt.Errorf("sidecar %s port must be between 1 and 65535", sidecar.Name)
+			}
+			ingressCount++
+		}
+	}
+
+	if ingressCount > 1 {
+		return errors.New("only one sidecar may set a port, since exactly one container receives traffic")
+	}
+	if ingressCount == 1 && primaryPortRequested {
+		return errors.New("port cannot be set when a sidecar sets a port, since exactly one container receives traffic")
+	}
+	return nil
+}
+
+// ingressSidecar returns the sidecar that receives traffic in place of the primary container, if any
+func ingressSidecar(sidecars []models.SidecarContainer) *models.SidecarContainer {
+	for i := range sidecars {
+		if sidecars[i].Port != nil {
+			return &sidecars[i]
+		}
+	}
+	return nil
+}
+
+func buildSidecarContainer(sidecar models.SidecarContainer, resources *runpb.ResourceRequirements) *runpb.Container {
+	container := &runpb.Container{
+		Name:      sidecar.Name,
+		Image:     sidecar.Image,
+		Resources: resources,
+	}
+	if sidecar.Port != nil {
+		container.Ports = []*runpb.ContainerPort{{ContainerPort: int32(*sidecar.Port)}}
+	}
+	// Sort so the same env always produces the same template
+	for _, key := range slices.Sorted(maps.Keys(sidecar.Env)) {
+		container.Env = append(container.Env, &runpb.EnvVar{
+			Name:   key,
+			Values: &runpb.EnvVar_Value{Value: sidecar.Env[key]},
+		})
+	}
+	return container
+}
+
+func sidecarsEqual(a, b []models.SidecarContainer) bool {
+	return slices.EqualFunc(a, b, func(x, y models.SidecarContainer) bool {
+		return x.Name == y.Name && x.Image == y.Image &&
+			(x.Port == nil) == (y.Port == nil) && (x.Port == nil || *x.Port == *y.Port) &&
+			maps.Equal(x.Env, y.Env)
+	})
+}
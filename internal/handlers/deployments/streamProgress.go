@@ -0,0 +1,161 @@
+package deployments
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"slices"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"golang.org/x/net/websocket"
+
+	"github.com/0p5dev/controller/internal/middleware"
+	"github.com/0p5dev/controller/internal/models"
+	"github.com/0p5dev/controller/internal/sharedUtils"
+)
+
+// DeploymentProgressEvent is a message on the deployment progress WebSocket. The last one has Done set and is
+// followed by the server closing the connection.
+type DeploymentProgressEvent struct {
+	Deployment string                        `json:"deployment"`
+	Phase      string                        `json:"phase"` // provisioning | succeeded | failed
+	Done       bool                          `json:"done"`
+	Message    string                        `json:"message,omitempty"`
+	Job        *models.ProvisioningJobUpdate `json:"job,omitempty"`
+}
+
+// @Summary Stream deployment progress over a WebSocket
+// @Description Upgrades to a WebSocket that streams the deployment's latest provisioning job as JSON events until it succeeds or fails. When no job is in progress a single terminal event is sent and the connection is closed. Messages from the client are ignored. Browsers, which can't set an Authorization header on a WebSocket, authenticate by offering the subprotocols "bearer" and their access token, e.g. new WebSocket(url, ["bearer", token]), and must connect from an origin in CORS_ALLOWED_ORIGINS.
+// @Tags deployments
+// @Security BearerAuth
+// @Param name path string true "Deployment name"
+// @Param environment query string false "Deployment environment (default: default)"
+// @Success 101 {object} DeploymentProgressEvent "WebSocket stream of progress events"
+// @Failure 401 {object} map[string]string "Unauthorized"
+// @Failure 403 {object} map[string]string "Origin not in CORS_ALLOWED_ORIGINS"
+// @Failure 404 {object} map[string]string "No provisioning jobs for the deployment"
+// @Failure 500 {object} map[string]string "Failed to look up provisioning jobs"
+// @Router /deployments/{name}/ws [get]
+func StreamProgress(c *gin.Context) {
+	userClaims := c.MustGet("UserClaims").(*sharedUtils.UserClaims)
+	logger := c.MustGet("Logger").(*slog.Logger)
+	pool := c.MustGet("Pool").(*pgxpool.Pool)
+	hub := c.MustGet("Hub").(*middleware.Hub)
+	ctx := c.Request.Context()
+
+	deploymentName := c.Param("name")
+	environment, ok := environmentParam(c)
+	if !ok {
+		return
+	}
+
+	// The deployments row is only written once a create finishes, so look the job up by service id instead
+	var jobId string
+	err := pool.QueryRow(ctx, "SELECT id FROM provisioning_jobs WHERE resource_id = $1 ORDER BY created_at DESC LIMIT 1", deploymentServiceId(deploymentName, environment, userClaims.UserMetadata.AppUser.Id)).Scan(&jobId)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			c.AbortWithStatusJSON(http.StatusNotFound, gin.H{
+				"error": "no provisioning jobs found for deployment " + deploymentName,
+			})
+			return
+		}
+		logger.Error("Failed to look up latest provisioning job", "deployment", deploymentName, "error", err)
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{
+			"error": "failed to look up provisioning jobs",
+		})
+		return
+	}
+
+	server := websocket.Server{
+		Handshake: func(config *websocket.Config, req *http.Request) error {
+			// Browsers always send their Origin, so hold them to the CORS allowlist; other clients send none
+			if origin := req.Header.Get("Origin"); origin != "" && !middleware.OriginAllowed(origin) {
+				return fmt.Errorf("origin %s is not allowed", origin)
+			}
+			// A browser that authenticated with the bearer subprotocol fails the connection unless it's selected
+			if slices.Contains(config.Protocol, middleware.WebSocketBearerProtocol) {
+				config.Protocol = []string{middleware.WebSocketBearerProtocol}
+			} else {
+				config.Protocol = nil
+			}
+			return nil
+		},
+		Handler: func(conn *websocket.Conn) {
+			defer conn.Close()
+			streamJobProgress(conn, logger, pool, hub, deploymentName, jobId)
+		},
+	}
+	server.ServeHTTP(c.Writer, c.Request)
+}
+
+func streamJobProgress(conn *websocket.Conn, logger *slog.Logger, pool *pgxpool.Pool, hub *middleware.Hub, deploymentName string, jobId string) {
+	// Reading is only used to notice the client going away
+	clientGone := make(chan struct{})
+	go func() {
+		defer close(clientGone)
+		var discarded []byte
+		for websocket.Message.Receive(conn, &discarded) == nil {
+		}
+	}()
+
+	statusChan := make(chan models.ProvisioningJobUpdate, 4)
+	hub.RegisterClient(jobId, statusChan)
+	defer hub.UnregisterClient(jobId, statusChan)
+
+	// Read the status after registering so a job that finishes in between isn't missed
+	update, err := sharedUtils.GetProvisioningJobUpdate(context.Background(), pool, jobId)
+	if err != nil {
+		logger.Error("Failed to query provisioning job status", "job_id", jobId, "error", err)
+		websocket.JSON.Send(conn, DeploymentProgressEvent{Deployment: deploymentName, Phase: "failed", Done: true, Message: "failed to read provisioning job status"})
+		return
+	}
+	if update.Status != "pending" {
+		sendJobProgress(conn, logger, pool, deploymentName, update, "no provisioning job in progress, showing the latest one")
+		return
+	}
+	sendJobProgress(conn, logger, pool, deploymentName, update, "")
+
+	for {
+		select {
+		case update := <-statusChan:
+			if sendJobProgress(conn, logger, pool, deploymentName, update, "") {
+				return
+			}
+		case <-clientGone:
+			return
+		}
+	}
+}
+
+// sendJobProgress sends one job update, with the deployment's URL once it has succeeded, and reports whether it
+// was the final one or the client can't be reached anymore
+func sendJobProgress(conn *websocket.Conn, logger *slog.Logger, pool *pgxpool.Pool, deploymentName string, update models.ProvisioningJobUpdate, message string) bool {
+	event := DeploymentProgressEvent{
+		Deployment: deploymentName,
+		Phase:      update.Status,
+		Done:       update.Status != "pending",
+		Message:    message,
+		Job:        &update,
+	}
+	if update.Status == "pending" {
+		event.Phase = "provisioning"
+	}
+	if update.Status == "succeeded" {
+		var serviceUrl string
+		var health *string
+		if err := pool.QueryRow(context.Background(), "SELECT url, health FROM deployments WHERE id = $1", update.ResourceId).Scan(&serviceUrl, &health); err == nil {
+			update.ServiceUrl = &serviceUrl
+			update.Health = health
+		}
+	}
+
+	if err := websocket.JSON.Send(conn, event); err != nil {
+		logger.Debug("Failed to send deployment progress", "deployment", deploymentName, "error", err)
+		return true
+	}
+	return event.Done
+}
@@ -0,0 +1,123 @@
+package deployments
+
+import (
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5/pgxpool"
+
+	"github.com/0p5dev/controller/internal/sharedUtils"
+)
+
+// @Summary Clear a stale deployment lock
+// @Description Fail the pending provisioning jobs that a crashed controller left behind, which otherwise make every later create, update, import, or delete of the deployment fail with a 409. Jobs younger than the deployment timeout plus its cleanup grace period may still be running on another instance and are only cleared with force=true. Admin only.
+// @Tags deployments
+// @Produce json
+// @Security BearerAuth
+// @Param name path string true "Deployment name"
+// @Param environment query string false "Deployment environment (default: default)"
+// @Param user_id query string false "Owner of the deployment (default: the caller)"
+// @Param force query bool false "Also clear jobs younger than the deployment timeout plus its cleanup grace period"
+// @Success 200 {object} map[string]interface{} "Cleared job IDs"
+// @Failure 401 {object} map[string]string "Unauthorized"
+// @Failure 403 {object} map[string]string "Admin access required"
+// @Failure 409 {object} map[string]string "Not locked, or the job is still running"
+// @Failure 500 {object} map[string]string "Failed to clear the lock"
+// @Router /deployments/{name}/unlock [post]
+func UnlockOneByName(c *gin.Context) {
+	userClaims := c.MustGet("UserClaims").(*sharedUtils.UserClaims)
+	pool := c.MustGet("Pool").(*pgxpool.Pool)
+	logger := c.MustGet("Logger").(*slog.Logger)
+	reqCtx := c.Request.Context()
+
+	deploymentName := c.Param("name")
+	environment, ok := environmentParam(c)
+	if !ok {
+		return
+	}
+	userId := c.DefaultQuery("user_id", userClaims.UserMetadata.AppUser.Id)
+	force := c.Query("force") == "true"
+
+	deploymentId := deploymentServiceId(deploymentName, environment, userId)
+
+	auditEntry := sharedUtils.NewAuditLogEntry(c, "deployment.unlock", deploymentName)
+	defer func() { sharedUtils.RecordAuditLogEntry(pool, auditEntry) }()
+
+	// A job running in this process is live, and canceling it releases the lock cleanly
+	runningJobId, err := pendingProvisioningJobId(reqCtx, pool, deploymentId)
+	if err != nil {
+		logger.Error("Failed to check for pending provisioning jobs", "deployment_id", deploymentId, "error", err)
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{
+			"error": "failed to check for pending provisioning jobs",
+		})
+		return
+	}
+	if runningJobId != "" && hasOperation(runningJobId) {
+		c.AbortWithStatusJSON(http.StatusConflict, gin.H{
+			"error": "deployment " + deploymentName + " has a job running on this instance, cancel it instead",
+		})
+		return
+	}
+
+	// A job can't outlive the deployment timeout plus its cleanup, so an older pending one was abandoned by its
+	// instance. A younger one may still be rolling back, deleting the service a new job would provision.
+	staleBefore := time.Now().Add(-(deploymentTimeout() + cleanupGracePeriod))
+	if force {
+		staleBefore = time.Now()
+	}
+	rows, err := pool.Query(reqCtx, `
+		UPDATE provisioning_jobs SET status = 'failed', completed_at = NOW()
+		WHERE resource_id = $1 AND status = 'pending' AND created_at < $2
+		RETURNING id
+	`, deploymentId, staleBefore)
+	if err != nil {
+		logger.Error("Failed to clear stale provisioning jobs", "deployment_id", deploymentId, "error", err)
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{
+			"error": "failed to clear the deployment lock",
+		})
+		return
+	}
+	jobIds := []string{}
+	for rows.Next() {
+		var jobId string
+		if err := rows.Scan(&jobId); err == nil {
+			jobIds = append(jobIds, jobId)
+		}
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		logger.Error("Failed to clear stale provisioning jobs", "deployment_id", deploymentId, "error", err)
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{
+			"error": "failed to clear the deployment lock",
+		})
+		return
+	}
+
+	if len(jobIds) == 0 {
+		pending, err := hasPendingProvisioningJob(reqCtx, pool, deploymentId)
+		if err != nil {
+			logger.Error("Failed to check for pending provisioning jobs", "deployment_id", deploymentId, "error", err)
+			c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{
+				"error": "failed to check for pending provisioning jobs",
+			})
+			return
+		}
+		message := "deployment " + deploymentName + " is not locked"
+		if pending {
+			message = "deployment " + deploymentName + " has a job younger than the deployment timeout plus its cleanup grace period that may still be running on another instance; retry with force=true to clear it anyway"
+		}
+		c.AbortWithStatusJSON(http.StatusConflict, gin.H{
+			"error": message,
+		})
+		return
+	}
+
+	logger.Warn("Cleared stale provisioning jobs", "deployment_id", deploymentId, "job_ids", jobIds, "forced", force)
+	auditEntry.Outcome = "succeeded"
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Cleared the lock on deployment " + deploymentName,
+		"job_ids": jobIds,
+	})
+}
@@ -2,29 +2,56 @@ package deployments
 
 import (
 	"context"
-	"fmt"
+	"errors"
 	"log/slog"
 	"math/rand"
 	"net/http"
-	"os"
+	"path"
 	"strings"
 	"time"
 
 	run "cloud.google.com/go/run/apiv2"
 	runpb "cloud.google.com/go/run/apiv2/runpb"
-	"github.com/0p5dev/controller/internal/models"
+	"github.com/0p5dev/controller/internal/config"
 	"github.com/0p5dev/controller/internal/sharedUtils"
 	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgxpool"
 	"github.com/oklog/ulid/v2"
 	"google.golang.org/protobuf/types/known/fieldmaskpb"
 )
 
 type UpdateDeploymentRequestBody struct {
-	ContainerImage *string `json:"container_image,omitempty"`
-	MinInstances   *int    `json:"min_instances,omitempty"`
-	MaxInstances   *int    `json:"max_instances,omitempty"`
-	Port           *int    `json:"port,omitempty"`
+	ContainerImage        *string `json:"container_image,omitempty"`
+	MinInstances          *int    `json:"min_instances,omitempty"`
+	MaxInstances          *int    `json:"max_instances,omitempty"`
+	Port                  *int    `json:"port,omitempty"`
+	CallbackUrl           *string `json:"callback_url,omitempty"`
+	CpuAlwaysAllocated    *bool   `json:"cpu_always_allocated,omitempty"`
+	StartupCpuBoost       *bool   `json:"startup_cpu_boost,omitempty"`
+	MaxConcurrency        *int    `json:"max_concurrency,omitempty"`
+	RequestTimeoutSeconds *int    `json:"request_timeout_seconds,omitempty"`
+	// Send an empty string to remove a VPC setting
+	VpcConnector *string `json:"vpc_connector,omitempty"`
+	VpcNetwork   *string `json:"vpc_network,omitempty"`
+	VpcSubnet    *string `json:"vpc_subnet,omitempty"`
+	VpcEgress    *string `json:"vpc_egress,omitempty"`
+	// Send an empty string to go back to Cloud Run's default execution environment
+	ExecutionEnvironment *string `json:"execution_environment,omitempty"`
+	// Send an empty string to go back to all ingress
+	Ingress *string `json:"ingress,omitempty"`
+	// Send an empty string to go back to DEFAULT_CPU or DEFAULT_MEMORY, or Cloud Run's default when those are unset
+	Cpu    *string `json:"cpu,omitempty"`
+	Memory *string `json:"memory,omitempty"`
+	// Send an empty string to clear the version
+	Version *string `json:"version,omitempty" binding:"omitempty,max=128"`
+	// DeletionProtection makes deletes fail until it is turned off again
+	DeletionProtection *bool `json:"deletion_protection,omitempty"`
+	// RegistryCredentials reach a new container_image that is private in an external registry
+	RegistryCredentials *RegistryCredentials `json:"registry_credentials,omitempty"`
+	// WaitForReady keeps the job pending until the new revision is serving in every region, up to
+	// READY_WAIT_TIMEOUT_SECONDS. If it is still rolling out by then the job succeeds with rollout: in_progress.
+	WaitForReady *bool `json:"wait_for_ready,omitempty"`
 }
 
 // @Summary Update deployment by name
@@ -34,50 +61,342 @@ type UpdateDeploymentRequestBody struct {
 // @Produce json
 // @Security BearerAuth
 // @Param name path string true "Deployment name"
+// @Param environment query string false "Deployment environment (default: default)"
 // @Param request body api.UpdateDeploymentRequestBody true "Deployment fields to update"
-// @Success 202 {object} map[string]string "Provisioning job accepted"
-// @Failure 400 {object} map[string]string "Invalid request body or missing deployment name"
+// @Param If-Match header string false "The ETag from GET /deployments/{name}, or the deployment's updated_at, when it was read; the update fails with 412 if it has changed since"
+// @Success 200 {object} DeploymentResponse "Deployment already matches the requested configuration"
+// @Success 202 {object} DeploymentResponse "Provisioning job accepted"
+// @Failure 400 {object} map[string]interface{} "Invalid request body, missing deployment name, image registry not allowed, or image not found or inaccessible"
 // @Failure 401 {object} map[string]string "Unauthorized"
+// @Failure 403 {object} map[string]string "Container image does not belong to the user"
 // @Failure 404 {object} map[string]string "Deployment not found"
+// @Failure 409 {object} map[string]string "Another create or update of the deployment is in progress"
+// @Failure 412 {object} map[string]interface{} "Deployment changed since the version in If-Match"
+// @Failure 422 {object} map[string]interface{} "Container image has blocking vulnerabilities"
 // @Failure 500 {object} map[string]string "Failed to queue update"
+// @Failure 502 {object} map[string]string "Failed to reach container registry"
+// @Failure 503 {object} map[string]string "Too many deployment operations in progress; see Retry-After"
 // @Router /deployments/{name} [patch]
 func UpdateOneByName(c *gin.Context) {
-	userClaims := c.MustGet("UserClaims").(*sharedUtils.UserClaims)
-	pool := c.MustGet("Pool").(*pgxpool.Pool)
+	var reqBody UpdateDeploymentRequestBody
+	if err := c.ShouldBindJSON(&reqBody); err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, bindingErrorResponse(c, err))
+		return
+	}
 
-	ctx := context.Background()
-	reqCtx := c.Request.Context()
+	deploymentName, environment, expectedVersion, ok := updateTarget(c)
+	if !ok {
+		return
+	}
+	updateDeployment(c, deploymentName, environment, expectedVersion, reqBody).respond(c)
+}
 
+// updateTarget reads which deployment an update is for from the path and environment param, and the version it
+// expects from If-Match. It responds with a 400 and returns false when any of them is invalid.
+func updateTarget(c *gin.Context) (string, string, *time.Time, bool) {
 	deploymentName := c.Param("name")
+	environment, ok := environmentParam(c)
+	if !ok {
+		return "", "", nil, false
+	}
 	if deploymentName == "" {
 		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{
 			"error": "deployment name is required",
 		})
-		return
+		return "", "", nil, false
 	}
 
-	var reqBody UpdateDeploymentRequestBody
-	if err := c.ShouldBindJSON(&reqBody); err != nil {
+	expectedVersion, err := ifMatchVersion(c)
+	if err != nil {
 		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{
-			"error": "invalid request body",
+			"error":   "invalid If-Match header",
+			"message": err.Error(),
 		})
-		return
+		return "", "", nil, false
+	}
+	return deploymentName, environment, expectedVersion, true
+}
+
+// updateDeployment validates and queues an update to a deployment, only applied while it still has expectedVersion
+// when that is set. It backs the general update endpoint, the narrower ones that only accept some of its fields, and
+// import. It only reads the request from c; the caller sends the result.
+func updateDeployment(c *gin.Context, deploymentName string, environment string, expectedVersion *time.Time, reqBody UpdateDeploymentRequestBody) deploymentResult {
+	userClaims := c.MustGet("UserClaims").(*sharedUtils.UserClaims)
+	logger := c.MustGet("Logger").(*slog.Logger)
+	pool := c.MustGet("Pool").(*pgxpool.Pool)
+
+	reqCtx := c.Request.Context()
+
+	if err := validateMaxConcurrency(reqBody.MaxConcurrency); err != nil {
+		return deploymentError(http.StatusBadRequest, gin.H{
+			"error":   "invalid max_concurrency",
+			"message": err.Error(),
+		})
+	}
+
+	if err := validateRequestTimeout(reqBody.RequestTimeoutSeconds); err != nil {
+		return deploymentError(http.StatusBadRequest, gin.H{
+			"error":   "invalid request_timeout_seconds",
+			"message": err.Error(),
+		})
+	}
+
+	if err := validateExecutionEnvironment(reqBody.ExecutionEnvironment); err != nil {
+		return deploymentError(http.StatusBadRequest, gin.H{
+			"error":   "invalid execution_environment",
+			"message": err.Error(),
+		})
+	}
+
+	if err := validateIngress(reqBody.Ingress); err != nil {
+		return deploymentError(http.StatusBadRequest, gin.H{
+			"error":   "invalid ingress",
+			"message": err.Error(),
+		})
+	}
+
+	if err := validateResourceLimits(reqBody.Cpu, reqBody.Memory); err != nil {
+		return deploymentError(http.StatusBadRequest, gin.H{
+			"error":   "invalid resource limits",
+			"message": err.Error(),
+		})
+	}
+
+	if reqBody.CallbackUrl != nil {
+		if err := validateCallbackUrl(reqCtx, *reqBody.CallbackUrl); err != nil {
+			return deploymentError(http.StatusBadRequest, gin.H{
+				"error":   "invalid callback_url",
+				"message": err.Error(),
+			})
+		}
 	}
 
 	// ensure deployment exists and belongs to user, return a 404 otherwise
-	var currentDeployment models.Deployment
-	err := pool.QueryRow(reqCtx, "SELECT id, container_image, min_instances, max_instances, port FROM deployments WHERE name = $1 AND user_id = $2", deploymentName, userClaims.UserMetadata.AppUser.Id).Scan(
-		&currentDeployment.Id,
-		&currentDeployment.ContainerImage,
-		&currentDeployment.MinInstances,
-		&currentDeployment.MaxInstances,
-		&currentDeployment.Port,
-	)
+	currentDeployment, err := scanDeployment(pool.QueryRow(reqCtx, "SELECT "+deploymentColumns+" FROM deployments WHERE name = $1 AND user_id = $2 AND environment = $3 AND deleted_at IS NULL", deploymentName, userClaims.UserMetadata.AppUser.Id, environment))
 	if err != nil {
-		c.AbortWithStatusJSON(http.StatusNotFound, gin.H{
+		return deploymentError(http.StatusNotFound, gin.H{
 			"error": "deployment " + deploymentName + " not found",
 		})
-		return
+	}
+
+	if expectedVersion != nil && !currentDeployment.UpdatedAt.Equal(*expectedVersion) {
+		return versionMismatchResult(deploymentName, currentDeployment.UpdatedAt)
+	}
+
+	// Resolve the instance range first so an invalid one is rejected before any image lookup. Unset values keep the
+	// existing ones.
+	requestedMin, requestedMax := currentDeployment.MinInstances, currentDeployment.MaxInstances
+	if reqBody.MinInstances != nil {
+		requestedMin = *reqBody.MinInstances
+	}
+	if reqBody.MaxInstances != nil {
+		requestedMax = *reqBody.MaxInstances
+	}
+	effectiveMin, effectiveMax, err := sharedUtils.ValidateMinAndMaxInstances(&requestedMin, &requestedMax, userClaims.DeploymentSettings().MaxInstancesLimit)
+	if err != nil {
+		return deploymentError(http.StatusBadRequest, gin.H{
+			"error":   "invalid instance range",
+			"message": err.Error(),
+		})
+	}
+
+	// Keep running the pinned digest unless a new image is requested, in which case pin that one instead
+	effectiveImage := currentDeployment.ContainerImage
+	effectiveDigest := currentDeployment.ImageDigest
+	if reqBody.ContainerImage != nil {
+		if err := validateImageRegistry(*reqBody.ContainerImage); err != nil {
+			return deploymentError(http.StatusBadRequest, imageRegistryErrorResponse(err))
+		}
+
+		// As on create, resolving an external image with the caller's own credentials proves they may deploy it
+		if reqBody.RegistryCredentials == nil {
+			if err := authorizeContainerImage(reqCtx, pool, userClaims.UserMetadata.AppUser.Id, *reqBody.ContainerImage); err != nil {
+				if errors.Is(err, errImageNotOwned) {
+					return deploymentError(http.StatusForbidden, gin.H{
+						"error": "container image " + *reqBody.ContainerImage + " does not belong to you",
+					})
+				}
+				logger.Error("Failed to authorize container image", "image", *reqBody.ContainerImage, "error", err.Error())
+				return deploymentError(http.StatusInternalServerError, gin.H{
+					"error": "failed to check container image ownership",
+				})
+			}
+		}
+
+		imageDigest, err := resolveImageDigest(reqCtx, *reqBody.ContainerImage, reqBody.RegistryCredentials)
+		if err != nil {
+			logger.Warn("Failed to resolve container image", "image", *reqBody.ContainerImage, "error", err.Error())
+			return deploymentError(imageResolutionErrorResponse(err))
+		}
+
+		if vulnerabilities := findBlockingVulnerabilities(reqCtx, imageDigest); len(vulnerabilities) > 0 {
+			return deploymentError(http.StatusUnprocessableEntity, gin.H{
+				"error":           "container image " + *reqBody.ContainerImage + " has vulnerabilities at or above the allowed severity",
+				"vulnerabilities": vulnerabilities,
+			})
+		}
+
+		if err := recordContainerImageReference(reqCtx, pool, userClaims.UserMetadata.AppUser.Id, *reqBody.ContainerImage); err != nil {
+			logger.Error("Failed to record container image reference", "image", *reqBody.ContainerImage, "error", err.Error())
+			return deploymentError(http.StatusInternalServerError, gin.H{
+				"error": "failed to record container image",
+			})
+		}
+
+		effectiveImage = *reqBody.ContainerImage
+		effectiveDigest = &imageDigest
+	}
+
+	deployImage := effectiveImage
+	if effectiveDigest != nil {
+		deployImage = *effectiveDigest
+	}
+
+	effectivePort := currentDeployment.Port
+	if reqBody.Port != nil {
+		effectivePort = *reqBody.Port
+	}
+
+	settings := revisionSettings{
+		Image:                deployImage,
+		Port:                 effectivePort,
+		MinInstances:         effectiveMin,
+		MaxInstances:         effectiveMax,
+		CpuAlwaysAllocated:   currentDeployment.CpuAlwaysAllocated,
+		StartupCpuBoost:      currentDeployment.StartupCpuBoost,
+		MaxConcurrency:       currentDeployment.MaxConcurrency,
+		RequestTimeout:       currentDeployment.RequestTimeoutSeconds,
+		VpcConnector:         currentDeployment.VpcConnector,
+		VpcNetwork:           currentDeployment.VpcNetwork,
+		VpcSubnet:            currentDeployment.VpcSubnet,
+		VpcEgress:            currentDeployment.VpcEgress,
+		Sidecars:             currentDeployment.Sidecars,
+		ExecutionEnvironment: currentDeployment.ExecutionEnvironment,
+		Ingress:              currentDeployment.Ingress,
+		Version:              currentDeployment.Version,
+		Cpu:                  currentDeployment.Cpu,
+		Memory:               currentDeployment.Memory,
+		Volumes:              currentDeployment.Volumes,
+	}
+	if reqBody.CpuAlwaysAllocated != nil {
+		settings.CpuAlwaysAllocated = *reqBody.CpuAlwaysAllocated
+	}
+	if reqBody.StartupCpuBoost != nil {
+		settings.StartupCpuBoost = *reqBody.StartupCpuBoost
+	}
+	if reqBody.MaxConcurrency != nil {
+		settings.MaxConcurrency = *reqBody.MaxConcurrency
+	}
+	if reqBody.RequestTimeoutSeconds != nil {
+		settings.RequestTimeout = *reqBody.RequestTimeoutSeconds
+	}
+	if reqBody.VpcConnector != nil {
+		settings.VpcConnector = optionalString(reqBody.VpcConnector)
+	}
+	if reqBody.VpcNetwork != nil {
+		settings.VpcNetwork = optionalString(reqBody.VpcNetwork)
+	}
+	if reqBody.VpcSubnet != nil {
+		settings.VpcSubnet = optionalString(reqBody.VpcSubnet)
+	}
+	if reqBody.VpcEgress != nil {
+		settings.VpcEgress = optionalString(reqBody.VpcEgress)
+	}
+	if reqBody.ExecutionEnvironment != nil {
+		settings.ExecutionEnvironment = optionalString(reqBody.ExecutionEnvironment)
+	}
+	if reqBody.Ingress != nil {
+		settings.Ingress = optionalString(reqBody.Ingress)
+	}
+	if reqBody.Version != nil {
+		settings.Version = optionalString(reqBody.Version)
+	}
+	if reqBody.Cpu != nil {
+		settings.Cpu = resourceLimit(reqBody.Cpu, config.Get().DefaultCpu)
+	}
+	if reqBody.Memory != nil {
+		settings.Memory = resourceLimit(reqBody.Memory, config.Get().DefaultMemory)
+	}
+	if err := validateVpcSettings(settings, deploymentRegions(currentDeployment)); err != nil {
+		return deploymentError(http.StatusBadRequest, gin.H{
+			"error":   "invalid VPC settings",
+			"message": err.Error(),
+		})
+	}
+	// Volumes can't be changed here, but a new execution environment must still support them
+	if err := validateVolumes(settings.Volumes, settings.ExecutionEnvironment); err != nil {
+		return deploymentError(http.StatusBadRequest, gin.H{
+			"error":   "invalid execution_environment",
+			"message": err.Error(),
+		})
+	}
+
+	// Deletion protection is the controller's own setting, so it never needs a Cloud Run change
+	deletionProtection := currentDeployment.DeletionProtection
+	if reqBody.DeletionProtection != nil {
+		deletionProtection = *reqBody.DeletionProtection
+	}
+
+	// Re-applying the current configuration (e.g. an idempotent CI redeploy) changes nothing in Cloud Run,
+	// so succeed right away instead of queueing a job
+	maskPaths := revisionUpdateMask(currentRevisionSettings(currentDeployment), settings)
+	if len(maskPaths) == 0 {
+		auditEntry := sharedUtils.NewAuditLogEntry(c, "deployment.update", deploymentName)
+		defer func() { sharedUtils.RecordAuditLogEntry(pool, auditEntry) }()
+
+		protectionChanged := deletionProtection != currentDeployment.DeletionProtection
+		if effectiveImage != currentDeployment.ContainerImage || protectionChanged {
+			// A different reference to the same digest only changes what we display. With If-Match, the version is
+			// checked in the same statement, so a concurrent change can't be overwritten. A running job would write
+			// its own copy of these fields when it finishes, so the change waits for the deployment's lock like any other.
+			result, err := pool.Exec(reqCtx, `
+				UPDATE deployments SET container_image = $1, deletion_protection = $2, updated_at = NOW()
+				WHERE id = $3 AND ($4::timestamptz IS NULL OR updated_at = $4)
+				AND NOT EXISTS(SELECT 1 FROM provisioning_jobs WHERE resource_id = $3 AND status = 'pending')
+			`, effectiveImage, deletionProtection, currentDeployment.Id, expectedVersion)
+			if err != nil {
+				logger.Error("Failed to update deployment record in database", "deployment_id", currentDeployment.Id, "error", err.Error())
+				return deploymentError(http.StatusInternalServerError, gin.H{
+					"error": "failed to update deployment record",
+				})
+			}
+			if result.RowsAffected() == 0 {
+				pending, err := hasPendingProvisioningJob(reqCtx, pool, currentDeployment.Id)
+				if err != nil {
+					logger.Error("Failed to check for pending provisioning jobs", "deployment_id", currentDeployment.Id, "error", err)
+					return deploymentError(http.StatusInternalServerError, gin.H{
+						"error": "failed to check for pending provisioning jobs",
+					})
+				}
+				if pending {
+					return deploymentError(http.StatusConflict, deploymentLockedResponse(deploymentName))
+				}
+				return versionMismatchResult(deploymentName, currentDeployment.UpdatedAt)
+			}
+		}
+		auditEntry.Outcome = "succeeded"
+
+		response := DeploymentResponse{
+			Message:     "Deployment " + deploymentName + " is already up to date",
+			Name:        deploymentName,
+			Environment: environment,
+			Action:      "unchanged",
+			Status:      "succeeded",
+			Url:         currentDeployment.Url,
+		}
+		if currentDeployment.Revision != nil {
+			response.Revision = *currentDeployment.Revision
+		}
+		if protectionChanged {
+			response.Message = "Deployment " + deploymentName + " updated"
+			response.Action = "updated"
+			response.ChangedFields = []string{"deletion_protection"}
+		}
+		return deploymentResult{Status: http.StatusOK, Response: response}
+	}
+
+	if !admitOperation() {
+		return operationsFullResult()
 	}
 
 	// Create entry in provisioning_jobs table and return job ID to client
@@ -85,143 +404,184 @@ func UpdateOneByName(c *gin.Context) {
 	ms := ulid.Timestamp(time.Now())
 	id, err := ulid.New(ms, entropy)
 	if err != nil {
-		slog.Error("Failed to generate ULID for provisioning job", "error", err.Error())
-		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{
+		logger.Error("Failed to generate ULID for provisioning job", "error", err.Error())
+		dismissOperation()
+		return deploymentError(http.StatusInternalServerError, gin.H{
 			"error": "failed to generate provisioning job ID",
 		})
-		return
 	}
 	safeId := strings.ToLower(id.String())
 
+	// With If-Match, the job is only inserted while the deployment still has the version the client read. The job
+	// claims the deployment's lock, so of two updates based on the same read only the first goes ahead, and the
+	// version only moves on once an update is actually applied.
 	var jobId string
-	err = pool.QueryRow(reqCtx, "INSERT INTO provisioning_jobs (id, resource_id, status) VALUES ($1, $2, 'pending') RETURNING id", safeId, currentDeployment.Id).Scan(&jobId)
+	err = pool.QueryRow(reqCtx, `
+		INSERT INTO provisioning_jobs (id, resource_id, status)
+		SELECT $1, $2, 'pending'
+		WHERE $3::timestamptz IS NULL OR EXISTS(SELECT 1 FROM deployments WHERE id = $2 AND updated_at = $3)
+		RETURNING id
+	`, safeId, currentDeployment.Id, expectedVersion).Scan(&jobId)
+	if errors.Is(err, pgx.ErrNoRows) {
+		dismissOperation()
+		return versionMismatchResult(deploymentName, currentDeployment.UpdatedAt)
+	}
+	if sharedUtils.IsUniqueViolation(err, pendingJobIndex) {
+		dismissOperation()
+		return deploymentError(http.StatusConflict, deploymentLockedResponse(deploymentName))
+	}
 	if err != nil {
-		slog.Error("Failed to create provisioning job", "resource_id", currentDeployment.Id, "error", err)
-		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{
+		logger.Error("Failed to create provisioning job", "resource_id", currentDeployment.Id, "error", err)
+		dismissOperation()
+		return deploymentError(http.StatusInternalServerError, gin.H{
 			"error": "failed to create provisioning job, update canceled",
 		})
-		return
 	}
 
-	c.JSON(http.StatusAccepted, gin.H{
-		"message": "Updating deployment " + deploymentName,
-		"job_id":  jobId,
-	})
+	auditEntry := sharedUtils.NewAuditLogEntry(c, "deployment.update", deploymentName)
+	ctx, finishOperation := startOperation(jobId)
+	response := DeploymentResponse{
+		Message:       "Updating deployment " + deploymentName,
+		Name:          deploymentName,
+		Environment:   environment,
+		Action:        "updated",
+		Status:        "pending",
+		JobId:         jobId,
+		Url:           currentDeployment.Url,
+		ChangedFields: changedSettings(currentRevisionSettings(currentDeployment), settings),
+		Warnings:      cpuAllocationWarnings(settings),
+	}
 
 	go func() {
-		projectID := os.Getenv("GCP_PROJECT_ID")
-		region := os.Getenv("GCP_REGION")
-
-		parent := fmt.Sprintf("projects/%s/locations/%s", projectID, region)
-		serviceFullName := fmt.Sprintf("%s/services/%s", parent, currentDeployment.Id)
+		defer finishOperation()
+
+		// Record the outcome so the optional callback and audit log can report it once the job finishes
+		callbackPayload := DeploymentCallbackPayload{JobId: jobId, Name: deploymentName, Status: "succeeded", Action: "updated", ServiceUrl: currentDeployment.Url}
+		failJob := func(errMsg string) {
+			errMsg = jobFailureMessage(ctx, errMsg)
+			callbackPayload.Status = "failed"
+			callbackPayload.Error = errMsg
+			cleanupCtx, cancelCleanup := cleanupContext(ctx)
+			defer cancelCleanup()
+			sharedUtils.FailProvisioningJob(cleanupCtx, pool, jobId, errMsg)
+		}
+		defer func() {
+			auditEntry.Outcome = callbackPayload.Status
+			sharedUtils.RecordAuditLogEntry(pool, auditEntry)
+		}()
+		if reqBody.CallbackUrl != nil {
+			defer func() { sendDeploymentCallback(*reqBody.CallbackUrl, callbackPayload) }()
+		}
 
-		servicesClient, err := run.NewServicesClient(ctx)
+		releaseSlot, err := acquireOperationSlot(ctx)
 		if err != nil {
-			slog.Error("Failed to create Cloud Run client", "error", err.Error())
-			sharedUtils.FailProvisioningJob(ctx, pool, jobId, "failed to create Cloud Run client: "+err.Error())
+			logger.Error("Job gave up waiting for an operation slot", "resource_id", currentDeployment.Id, "error", err.Error())
+			failJob("gave up waiting for other deployment operations to finish: " + err.Error())
 			return
 		}
-		defer servicesClient.Close()
+		defer releaseSlot()
 
-		// Resolve effective values: use the request value if provided, otherwise keep existing
-		effectiveImage := currentDeployment.ContainerImage
-		if reqBody.ContainerImage != nil {
-			effectiveImage = *reqBody.ContainerImage
+		servicesClient, err := newCloudRunServices(ctx)
+		if err != nil {
+			logger.Error("Failed to create Cloud Run client", "operation", "NewServicesClient", "error", err.Error())
+			failJob(newCloudRunError("NewServicesClient", "", err).Error())
+			return
 		}
+		defer servicesClient.Close()
 
-		effectiveMin, effectiveMax := sharedUtils.ValidateMinAndMaxInstances(reqBody.MinInstances, reqBody.MaxInstances)
-
-		effectivePort := currentDeployment.Port
-		if reqBody.Port != nil {
-			effectivePort = *reqBody.Port
+		// Multi-region deployments are updated one region at a time. A failure rolls back every region updated so
+		// far, so all regions keep running the same settings.
+		var updatedRegions []string
+		rollback := func() {
+			cleanupCtx, cancelCleanup := cleanupContext(ctx)
+			defer cancelCleanup()
+			for _, region := range updatedRegions {
+				rollbackToPreviousRevision(cleanupCtx, logger, regionalServiceName(region, currentDeployment.Id), servicesClient)
+			}
 		}
 
-		// Build the update mask dynamically: only include paths for fields being changed
-		maskPaths := []string{"traffic"}
+		events := newDeploymentEventLog(logger)
+		var revision string
+		for _, region := range deploymentRegions(currentDeployment) {
+			serviceFullName := regionalServiceName(region, currentDeployment.Id)
+			updatedRegions = append(updatedRegions, region)
 
-		if reqBody.MinInstances != nil {
-			maskPaths = append(maskPaths, "scaling.min_instance_count", "template.scaling.min_instance_count")
-		}
-		if reqBody.MaxInstances != nil {
-			maskPaths = append(maskPaths, "scaling.max_instance_count", "template.scaling.max_instance_count")
-		}
-		if reqBody.ContainerImage != nil || reqBody.Port != nil {
-			maskPaths = append(maskPaths, "template.containers")
-		}
-		if reqBody.Port != nil {
-			maskPaths = append(maskPaths, "template.containers.ports")
-		}
-
-		if len(maskPaths) == 0 {
-			slog.Info("No fields to update", "deployment", deploymentName)
-			sharedUtils.SucceedProvisioningJob(ctx, pool, jobId)
-			return
-		}
-
-		serviceSpec := &runpb.Service{
-			Name: serviceFullName,
-			Scaling: &runpb.ServiceScaling{
-				MinInstanceCount: int32(effectiveMin),
-				MaxInstanceCount: int32(effectiveMax),
-			},
-			Traffic: []*runpb.TrafficTarget{
-				{
-					Type:    runpb.TrafficTargetAllocationType_TRAFFIC_TARGET_ALLOCATION_TYPE_LATEST,
-					Percent: 100,
-				},
-			},
-			Template: &runpb.RevisionTemplate{
-				Scaling: &runpb.RevisionScaling{
-					MinInstanceCount: int32(effectiveMin),
-					MaxInstanceCount: int32(effectiveMax),
-				},
-				Containers: []*runpb.Container{
+			serviceSpec := &runpb.Service{
+				Name: serviceFullName,
+				Traffic: []*runpb.TrafficTarget{
 					{
-						Image: effectiveImage,
-						Ports: []*runpb.ContainerPort{
-							{ContainerPort: int32(effectivePort)},
-						},
+						Type:    runpb.TrafficTargetAllocationType_TRAFFIC_TARGET_ALLOCATION_TYPE_LATEST,
+						Percent: 100,
 					},
 				},
-			},
+				Template: buildRevisionTemplate(settings),
+				Ingress:  buildIngress(settings),
+			}
+
+			started := time.Now()
+			service, err := servicesClient.UpdateService(ctx, &runpb.UpdateServiceRequest{
+				Service:    serviceSpec,
+				UpdateMask: &fieldmaskpb.FieldMask{Paths: maskPaths},
+			})
+			events.record(region, "update_service", started, err)
+			if err != nil {
+				logger.Error("Failed to update Cloud Run service", "service", serviceFullName, "error", err.Error())
+				failJob(newCloudRunError("UpdateService", serviceFullName, err).Error() + " in " + region + vpcErrorHint(settings, region, err))
+				rollback()
+				return
+			}
+			// The primary region's new revision is the one recorded on the deployment
+			if revision == "" && service != nil {
+				revision = path.Base(service.LatestCreatedRevision)
+			}
 		}
 
-		updateOperation, err := servicesClient.UpdateService(ctx, &runpb.UpdateServiceRequest{
-			Service:    serviceSpec,
-			UpdateMask: &fieldmaskpb.FieldMask{Paths: maskPaths},
-		})
-
-		if err != nil {
-			slog.Error("Failed to update Cloud Run service", "service", serviceFullName, "error", err.Error())
-			sharedUtils.FailProvisioningJob(ctx, pool, jobId, "failed to update Cloud Run service: "+err.Error())
-			rollbackToPreviousRevision(ctx, serviceFullName, servicesClient)
-			return
+		if reqBody.WaitForReady != nil && *reqBody.WaitForReady {
+			readyCtx, cancelReady := context.WithTimeout(ctx, readyWaitTimeout())
+			callbackPayload.Rollout = "ready"
+			for _, region := range updatedRegions {
+				serviceFullName := regionalServiceName(region, currentDeployment.Id)
+				revision, ready, err := waitForLatestRevisionReady(readyCtx, servicesClient, serviceFullName)
+				if err != nil {
+					cancelReady()
+					logger.Error("New revision failed to become ready", "operation", "WaitForReady", "service", serviceFullName, "error", err.Error())
+					failJob(err.Error() + " in " + region)
+					rollback()
+					return
+				}
+				// Report the primary region's revision, or the first one that isn't serving yet
+				if callbackPayload.Revision == "" || (!ready && callbackPayload.Rollout == "ready") {
+					callbackPayload.Revision = revision
+				}
+				if !ready {
+					callbackPayload.Rollout = "in_progress"
+				}
+			}
+			cancelReady()
 		}
-
-		_, err = updateOperation.Wait(ctx)
-		if err != nil {
-			slog.Error("Failed waiting for Cloud Run update", "service", serviceFullName, "error", err.Error())
-			sharedUtils.FailProvisioningJob(ctx, pool, jobId, "failed waiting for Cloud Run update: "+err.Error())
-			rollbackToPreviousRevision(ctx, serviceFullName, servicesClient)
-			return
+		if callbackPayload.Revision == "" {
+			callbackPayload.Revision = revision
 		}
 
-		_, err = pool.Exec(ctx, "UPDATE deployments SET container_image = $1, min_instances = $2, max_instances = $3, port = $4, updated_at = NOW() WHERE id = $5", effectiveImage, effectiveMin, effectiveMax, effectivePort, currentDeployment.Id)
+		// Deletion protection is only written when this update set it, so a job that started from an older read can't revert it
+		_, err = pool.Exec(ctx, "UPDATE deployments SET container_image = $1, image_digest = $2, min_instances = $3, max_instances = $4, port = $5, cpu_always_allocated = $6, startup_cpu_boost = $7, max_concurrency = $8, request_timeout_seconds = $9, vpc_connector = $10, vpc_network = $11, vpc_subnet = $12, vpc_egress = $13, execution_environment = $14, ingress = $15, version = $16, cpu = $17, memory = $18, revision = $19, deletion_protection = COALESCE($20, deletion_protection), events = $21, updated_at = NOW() WHERE id = $22", effectiveImage, effectiveDigest, effectiveMin, effectiveMax, effectivePort, settings.CpuAlwaysAllocated, settings.StartupCpuBoost, settings.MaxConcurrency, settings.RequestTimeout, settings.VpcConnector, settings.VpcNetwork, settings.VpcSubnet, settings.VpcEgress, settings.ExecutionEnvironment, settings.Ingress, settings.Version, settings.Cpu, settings.Memory, optionalString(&revision), reqBody.DeletionProtection, events.snapshot(), currentDeployment.Id)
 		if err != nil {
-			slog.Error("Failed to update deployment record in database", "deployment_id", currentDeployment.Id, "error", err.Error())
-			sharedUtils.FailProvisioningJob(ctx, pool, jobId, "failed to update deployment record in database: "+err.Error())
-			rollbackToPreviousRevision(ctx, serviceFullName, servicesClient)
+			logger.Error("Failed to update deployment record in database", "deployment_id", currentDeployment.Id, "error", err.Error())
+			failJob("failed to update deployment record in database: " + err.Error())
+			rollback()
 			return
 		}
 
 		sharedUtils.SucceedProvisioningJob(ctx, pool, jobId)
 	}()
+
+	return deploymentResult{Status: http.StatusAccepted, Response: response}
 }
 
-func rollbackToPreviousRevision(ctx context.Context, serviceFullName string, servicesClient *run.ServicesClient) {
+func rollbackToPreviousRevision(ctx context.Context, logger *slog.Logger, serviceFullName string, servicesClient cloudRunServices) {
 	revisionsClient, err := run.NewRevisionsClient(ctx)
 	if err != nil {
-		slog.Error("Failed to create Revisions client for rollback", "service", serviceFullName, "error", err.Error())
+		logger.Error("Failed to create Revisions client for rollback", "service", serviceFullName, "error", err.Error())
 		return
 	}
 	defer revisionsClient.Close()
@@ -249,7 +609,7 @@ func rollbackToPreviousRevision(ctx context.Context, serviceFullName string, ser
 	}
 
 	if len(revisionNames) < 2 {
-		slog.Error("Not enough revisions to perform rollback", "service", serviceFullName)
+		logger.Error("Not enough revisions to perform rollback", "service", serviceFullName)
 		return
 	}
 
@@ -257,7 +617,7 @@ func rollbackToPreviousRevision(ctx context.Context, serviceFullName string, ser
 	previousRevision := revisionNames[1]
 
 	// Route 100% of traffic to the previous revision
-	updateOperation, err := servicesClient.UpdateService(ctx, &runpb.UpdateServiceRequest{
+	_, err = servicesClient.UpdateService(ctx, &runpb.UpdateServiceRequest{
 		Service: &runpb.Service{
 			Name: serviceFullName,
 			Traffic: []*runpb.TrafficTarget{
@@ -271,12 +631,6 @@ func rollbackToPreviousRevision(ctx context.Context, serviceFullName string, ser
 		UpdateMask: &fieldmaskpb.FieldMask{Paths: []string{"traffic"}},
 	})
 	if err != nil {
-		slog.Error("Failed to update service traffic for rollback", "service", serviceFullName, "error", err.Error())
-		return
-	}
-
-	if _, err = updateOperation.Wait(ctx); err != nil {
-		slog.Error("Failed waiting for rollback operation to complete", "service", serviceFullName, "error", err.Error())
-		return
+		logger.Error("Failed to update service traffic for rollback", "service", serviceFullName, "error", err.Error())
 	}
 }
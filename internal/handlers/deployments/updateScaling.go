@@ -0,0 +1,57 @@
+package deployments
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+type UpdateScalingRequestBody struct {
+	MinInstances *int    `json:"min_instances,omitempty"`
+	MaxInstances *int    `json:"max_instances,omitempty"`
+	CallbackUrl  *string `json:"callback_url,omitempty"`
+}
+
+// @Summary Update deployment scaling
+// @Description Queue a scaling-only update for an existing deployment. The deployment keeps its current image and other settings, and an omitted bound keeps its current value.
+// @Tags deployments
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param name path string true "Deployment name"
+// @Param environment query string false "Deployment environment (default: default)"
+// @Param request body UpdateScalingRequestBody true "New instance range"
+// @Success 200 {object} map[string]string "Deployment already has the requested scaling"
+// @Success 202 {object} map[string]string "Provisioning job accepted"
+// @Failure 400 {object} map[string]string "Invalid request body or instance range"
+// @Failure 401 {object} map[string]string "Unauthorized"
+// @Failure 404 {object} map[string]string "Deployment not found"
+// @Failure 409 {object} map[string]string "Another create or update of the deployment is in progress"
+// @Failure 500 {object} map[string]string "Failed to queue update"
+// @Router /deployments/{name}/scaling [patch]
+func UpdateScaling(c *gin.Context) {
+	var reqBody UpdateScalingRequestBody
+	if err := c.ShouldBindJSON(&reqBody); err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{
+			"error": "invalid request body",
+		})
+		return
+	}
+
+	if reqBody.MinInstances == nil && reqBody.MaxInstances == nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{
+			"error": "min_instances or max_instances is required",
+		})
+		return
+	}
+
+	deploymentName, environment, expectedVersion, ok := updateTarget(c)
+	if !ok {
+		return
+	}
+	updateDeployment(c, deploymentName, environment, expectedVersion, UpdateDeploymentRequestBody{
+		MinInstances: reqBody.MinInstances,
+		MaxInstances: reqBody.MaxInstances,
+		CallbackUrl:  reqBody.CallbackUrl,
+	}).respond(c)
+}
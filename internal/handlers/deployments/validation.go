@@ -0,0 +1,123 @@
+package deployments
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"reflect"
+	"regexp"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+	"github.com/go-playground/validator/v10"
+	"github.com/google/go-containerregistry/pkg/name"
+)
+
+// Deployment names and environments become part of the Cloud Run service id, so they must be DNS labels
+var dnsLabelPattern = regexp.MustCompile(`^[a-z]([-a-z0-9]*[a-z0-9])?$`)
+
+// FieldError is one invalid field of a request body, named by its JSON key
+type FieldError struct {
+	Field   string `json:"field"`
+	Message string `json:"message"`
+}
+
+// RegisterValidators adds the dnslabel and imageref binding tags to gin's validator, and makes validation errors
+// name fields by their JSON keys
+func RegisterValidators() error {
+	validate, ok := binding.Validator.Engine().(*validator.Validate)
+	if !ok {
+		return errors.New("gin's validator engine is not go-playground/validator")
+	}
+
+	validate.RegisterTagNameFunc(func(field reflect.StructField) string {
+		jsonName, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+		if jsonName == "-" {
+			return ""
+		}
+		return jsonName
+	})
+	if err := validate.RegisterValidation("dnslabel", func(fl validator.FieldLevel) bool {
+		return dnsLabelPattern.MatchString(fl.Field().String())
+	}); err != nil {
+		return err
+	}
+	return validate.RegisterValidation("imageref", func(fl validator.FieldLevel) bool {
+		_, err := name.ParseReference(fl.Field().String())
+		return err == nil
+	})
+}
+
+// bindingErrorResponse turns a binding error into a 400 body and logs the rejection. Validation failures list every
+// invalid field at once; anything else, like malformed JSON, is reported as a single message. Neither the response
+// nor the log quotes the submitted values, since a body can carry credentials.
+func bindingErrorResponse(c *gin.Context, err error) gin.H {
+	logger := c.MustGet("Logger").(*slog.Logger)
+
+	fields, ok := fieldErrors(err)
+	if !ok {
+		message := decodeErrorMessage(err)
+		logger.Warn("Rejected request body", "path", c.FullPath(), "reason", message)
+		return gin.H{
+			"error":   "invalid request payload",
+			"message": message,
+		}
+	}
+	logger.Warn("Rejected request body", "path", c.FullPath(), "fields", fields)
+	return gin.H{
+		"error":  "invalid request payload",
+		"fields": fields,
+	}
+}
+
+// decodeErrorMessage describes why a request body couldn't be decoded by field, type, or offset only. The decoder's
+// own messages can quote the offending value, e.g. a token pasted into a numeric field.
+func decodeErrorMessage(err error) string {
+	var syntaxErr *json.SyntaxError
+	var typeErr *json.UnmarshalTypeError
+	switch {
+	case errors.Is(err, io.EOF):
+		return "request body is empty"
+	case errors.Is(err, io.ErrUnexpectedEOF):
+		return "request body is incomplete JSON"
+	case errors.As(err, &syntaxErr):
+		return fmt.Sprintf("request body is not valid JSON (offset %d)", syntaxErr.Offset)
+	case errors.As(err, &typeErr) && typeErr.Field != "":
+		return fmt.Sprintf("%s must be of type %s", typeErr.Field, typeErr.Type)
+	case errors.As(err, &typeErr):
+		return fmt.Sprintf("request body must be of type %s", typeErr.Type)
+	default:
+		return "request body could not be decoded"
+	}
+}
+
+// fieldErrors lists the invalid fields of a validation error, or returns false for any other kind of error
+func fieldErrors(err error) ([]FieldError, bool) {
+	var validationErrs validator.ValidationErrors
+	if !errors.As(err, &validationErrs) {
+		return nil, false
+	}
+	fields := make([]FieldError, 0, len(validationErrs))
+	for _, fieldErr := range validationErrs {
+		fields = append(fields, FieldError{Field: fieldErr.Field(), Message: fieldErrorMessage(fieldErr)})
+	}
+	return fields, true
+}
+
+func fieldErrorMessage(fieldErr validator.FieldError) string {
+	switch fieldErr.Tag() {
+	case "required":
+		return fieldErr.Field() + " is required"
+	case "max":
+		return fmt.Sprintf("%s must be %s characters or less", fieldErr.Field(), fieldErr.Param())
+	case "dnslabel":
+		return fieldErr.Field() + " must be lowercase letters, digits, and hyphens, starting with a letter and not ending with a hyphen"
+	case "imageref":
+		return fieldErr.Field() + " must be a valid container image reference"
+	default:
+		return fmt.Sprintf("%s failed the %s check", fieldErr.Field(), fieldErr.Tag())
+	}
+}
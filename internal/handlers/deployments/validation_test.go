@@ -0,0 +1,153 @@
+package deployments
+
+import (
+	"bytes"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+var registerValidatorsOnce sync.Once
+
+// bindCreateBody binds body as a create request the way CreateOne does, and returns the 400 body for it along with
+// what was logged, or nil if the body is valid
+func bindCreateBody(t *testing.T, body string) (gin.H, string) {
+	t.Helper()
+	registerValidatorsOnce.Do(func() {
+		if err := RegisterValidators(); err != nil {
+			t.Fatalf("RegisterValidators: %v", err)
+		}
+	})
+
+	gin.SetMode(gin.TestMode)
+	var logs bytes.Buffer
+	c, _ := gin.CreateTestContext(httptest.NewRecorder())
+	c.Request = httptest.NewRequest(http.MethodPost, "/api/v1/deployments", strings.NewReader(body))
+	c.Request.Header.Set("Content-Type", "application/json")
+	c.Set("Logger", slog.New(slog.NewTextHandler(&logs, nil)))
+
+	var reqBody CreateOneRequestBody
+	if err := c.ShouldBindJSON(&reqBody); err != nil {
+		return bindingErrorResponse(c, err), logs.String()
+	}
+	return nil, logs.String()
+}
+
+// invalidFields lists the fields of a 400 body and their messages
+func invalidFields(t *testing.T, response gin.H) map[string]string {
+	t.Helper()
+	fields, ok := response["fields"].([]FieldError)
+	if !ok {
+		t.Fatalf("response has no field errors: %v", response)
+	}
+	messages := map[string]string{}
+	for _, field := range fields {
+		messages[field.Field] = field.Message
+	}
+	return messages
+}
+
+func TestValidCreateBody(t *testing.T) {
+	response, _ := bindCreateBody(t, `{"name": "api", "container_image": "us-docker.pkg.dev/project/repo/api:v1"}`)
+	if response != nil {
+		t.Errorf("valid body rejected: %v", response)
+	}
+}
+
+func TestCreateBodyValidation(t *testing.T) {
+	tests := []struct {
+		name    string
+		body    string
+		field   string
+		message string
+	}{
+		{"missing name", `{"container_image": "nginx"}`, "name", "name is required"},
+		{"missing image", `{"name": "api"}`, "container_image", "container_image is required"},
+		{"uppercase name", `{"name": "My-App", "container_image": "nginx"}`, "name", "name must be lowercase letters"},
+		{"name with underscore", `{"name": "my_app", "container_image": "nginx"}`, "name", "name must be lowercase letters"},
+		{"name ending in hyphen", `{"name": "api-", "container_image": "nginx"}`, "name", "name must be lowercase letters"},
+		{"long name", `{"name": "a-name-longer-than-twenty", "container_image": "nginx"}`, "name", "name must be 20 characters or less"},
+		{"invalid image", `{"name": "api", "container_image": "not an image!"}`, "container_image", "container_image must be a valid container image reference"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			response, _ := bindCreateBody(t, tt.body)
+			message, ok := invalidFields(t, response)[tt.field]
+			if !ok {
+				t.Fatalf("fields = %v, want %s", response["fields"], tt.field)
+			}
+			if !strings.HasPrefix(message, tt.message) {
+				t.Errorf("message = %q, want %q", message, tt.message)
+			}
+		})
+	}
+}
+
+func TestCreateBodyValidationListsEveryField(t *testing.T) {
+	response, _ := bindCreateBody(t, `{"name": "Not_A_Label", "container_image": "not an image!", "version": "`+strings.Repeat("v", 129)+`"}`)
+	fields := invalidFields(t, response)
+	for _, field := range []string{"name", "container_image", "version"} {
+		if _, ok := fields[field]; !ok {
+			t.Errorf("fields = %v, want %s listed too", fields, field)
+		}
+	}
+	if response["error"] != "invalid request payload" {
+		t.Errorf("error = %v", response["error"])
+	}
+}
+
+func TestDecodeErrorMessage(t *testing.T) {
+	tests := []struct {
+		body    string
+		message string
+	}{
+		{``, "request body is empty"},
+		{`{"name": "api"`, "request body is incomplete JSON"},
+		{`{"name": api}`, "request body is not valid JSON"},
+		{`{"name": 42}`, "name must be of type string"},
+		{`[]`, "request body must be of type"},
+	}
+	for _, tt := range tests {
+		response, _ := bindCreateBody(t, tt.body)
+		message, _ := response["message"].(string)
+		if !strings.HasPrefix(message, tt.message) {
+			t.Errorf("body %q: message = %q, want %q", tt.body, message, tt.message)
+		}
+		if response["error"] != "invalid request payload" {
+			t.Errorf("body %q: error = %v", tt.body, response["error"])
+		}
+	}
+}
+
+func TestBindingErrorsDoNotEchoSubmittedValues(t *testing.T) {
+	const secret = "ghp_s3cr3tT0kenValue"
+	bodies := map[string]string{
+		"invalid value":    `{"name": "` + secret + `", "container_image": "nginx"}`,
+		"invalid image":    `{"name": "api", "container_image": "` + secret + `!"}`,
+		"string option":    `{"name": "api", "container_image": "nginx", "port": "` + secret + `"}`,
+		"wrong type":       `{"name": "api", "container_image": "nginx", "registry_credentials": {"username": "me", "password": ["` + secret + `"]}}`,
+		"unquoted value":   `{"name": "api", "container_image": "nginx", "max_concurrency": ` + secret + `}`,
+		"truncated secret": `{"name": "api", "container_image": "nginx", "registry_credentials": {"password": "` + secret,
+		"quoted number":    `{"name": "api", "container_image": "nginx", "max_concurrency": "` + secret + `"}`,
+	}
+	for name, body := range bodies {
+		t.Run(name, func(t *testing.T) {
+			response, logs := bindCreateBody(t, body)
+			if response == nil {
+				t.Fatal("body accepted, want a 400")
+			}
+			if encoded, _ := json.Marshal(response); strings.Contains(string(encoded), secret) {
+				t.Errorf("response quotes the submitted value: %s", encoded)
+			}
+			if strings.Contains(logs, secret) {
+				t.Errorf("log quotes the submitted value: %s", logs)
+			}
+		})
+	}
+}
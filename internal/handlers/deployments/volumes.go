@@ -0,0 +1,172 @@
+package deployments
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"path"
+	"regexp"
+	"slices"
+
+	runpb "cloud.google.com/go/run/apiv2/runpb"
+	"cloud.google.com/go/storage"
+	"github.com/0p5dev/controller/internal/config"
+	"github.com/0p5dev/controller/internal/models"
+	"github.com/gin-gonic/gin"
+	"google.golang.org/api/googleapi"
+)
+
+var (
+	// Volume names must be DNS labels, like container names
+	volumeNamePattern = regexp.MustCompile(`^[a-z]([-a-z0-9]{0,61}[a-z0-9])?$`)
+	// Size limits are Kubernetes quantities, e.g. 512Mi or 1Gi
+	volumeSizeLimitPattern = regexp.MustCompile(`^[1-9][0-9]*(Ki|Mi|Gi)$`)
+
+	errBucketInaccessible = errors.New("bucket not found or inaccessible")
+)
+
+// validateVolumes checks requested volumes before anything is looked up. Mount paths must be absolute and unique,
+// and each volume may only set the fields of its own type.
+func validateVolumes(volumes []models.Volume, executionEnvironment *string) error {
+	var names, mountPaths []string
+	for _, volume := range volumes {
+		if !volumeNamePattern.MatchString(volume.Name) {
+			return fmt.Errorf("volume name %q must be lowercase letters, digits, and hyphens, starting with a letter", volume.Name)
+		}
+		if slices.Contains(names, volume.Name) {
+			return fmt.Errorf("volume name %q is not unique", volume.Name)
+		}
+		names = append(names, volume.Name)
+
+		if !path.IsAbs(volume.MountPath) {
+			return fmt.Errorf("volume %s mount_path must be an absolute path", volume.Name)
+		}
+		mountPath := path.Clean(volume.MountPath)
+		if mountPath == "/" {
+			return fmt.Errorf("volume %s cannot be mounted at /", volume.Name)
+		}
+		if slices.Contains(mountPaths, mountPath) {
+			return fmt.Errorf("volume %s mount_path %s is already used by another volume", volume.Name, mountPath)
+		}
+		mountPaths = append(mountPaths, mountPath)
+
+		switch volume.Type {
+		case "emptyDir":
+			if volume.Bucket != "" || volume.Secret != "" || volume.ReadOnly {
+				return fmt.Errorf("volume %s is an emptyDir and only supports size_limit", volume.Name)
+			}
+			if volume.SizeLimit != "" && !volumeSizeLimitPattern.MatchString(volume.SizeLimit) {
+				return fmt.Errorf("volume %s size_limit must be a size like 512Mi or 1Gi", volume.Name)
+			}
+		case "gcs":
+			if volume.Bucket == "" {
+				return fmt.Errorf("volume %s requires a bucket", volume.Name)
+			}
+			if volume.Secret != "" || volume.SizeLimit != "" {
+				return fmt.Errorf("volume %s is a gcs volume and only supports bucket and read_only", volume.Name)
+			}
+			// The controller's own bucket holds every user's image uploads
+			if volume.Bucket == config.Get().CloudStorageBucketName {
+				return fmt.Errorf("volume %s cannot mount bucket %s", volume.Name, volume.Bucket)
+			}
+			if stringOrEmpty(executionEnvironment) == "gen1" {
+				return fmt.Errorf("volume %s requires the gen2 execution environment, since Cloud Storage volumes are not supported on gen1", volume.Name)
+			}
+		case "secret":
+			if volume.Secret == "" {
+				return fmt.Errorf("volume %s requires a secret", volume.Name)
+			}
+			if volume.Bucket != "" || volume.SizeLimit != "" || volume.ReadOnly {
+				return fmt.Errorf("volume %s is a secret volume and only supports secret", volume.Name)
+			}
+		default:
+			return fmt.Errorf("volume %s type must be emptyDir, gcs, or secret", volume.Name)
+		}
+	}
+	return nil
+}
+
+// checkVolumeBuckets confirms that every Cloud Storage bucket a deployment mounts exists and can be read
+func checkVolumeBuckets(ctx context.Context, volumes []models.Volume) error {
+	var buckets []string
+	for _, volume := range volumes {
+		if volume.Type == "gcs" && !slices.Contains(buckets, volume.Bucket) {
+			buckets = append(buckets, volume.Bucket)
+		}
+	}
+	if len(buckets) == 0 {
+		return nil
+	}
+
+	client, err := storage.NewClient(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to create cloud storage client: %w", err)
+	}
+	defer client.Close()
+
+	for _, bucket := range buckets {
+		_, err := client.Bucket(bucket).Attrs(ctx)
+		var apiErr *googleapi.Error
+		switch {
+		case err == nil:
+		case errors.Is(err, storage.ErrBucketNotExist),
+			errors.As(err, &apiErr) && (apiErr.Code == http.StatusForbidden || apiErr.Code == http.StatusBadRequest):
+			return fmt.Errorf("%w: %s", errBucketInaccessible, bucket)
+		default:
+			return fmt.Errorf("failed to look up bucket %s: %w", bucket, err)
+		}
+	}
+	return nil
+}
+
+func volumeBucketErrorResponse(err error) (int, gin.H) {
+	if errors.Is(err, errBucketInaccessible) {
+		return http.StatusBadRequest, gin.H{
+			"error":   "invalid volumes",
+			"message": err.Error(),
+		}
+	}
+	return http.StatusBadGateway, gin.H{
+		"error":   "failed to reach cloud storage",
+		"message": err.Error(),
+	}
+}
+
+func buildVolumes(volumes []models.Volume) []*runpb.Volume {
+	var built []*runpb.Volume
+	for _, volume := range volumes {
+		runVolume := &runpb.Volume{Name: volume.Name}
+		switch volume.Type {
+		case "emptyDir":
+			runVolume.VolumeType = &runpb.Volume_EmptyDir{EmptyDir: &runpb.EmptyDirVolumeSource{
+				Medium:    runpb.EmptyDirVolumeSource_MEMORY,
+				SizeLimit: volume.SizeLimit,
+			}}
+		case "gcs":
+			runVolume.VolumeType = &runpb.Volume_Gcs{Gcs: &runpb.GCSVolumeSource{
+				Bucket:   volume.Bucket,
+				ReadOnly: volume.ReadOnly,
+			}}
+		case "secret":
+			runVolume.VolumeType = &runpb.Volume_Secret{Secret: &runpb.SecretVolumeSource{
+				Secret: volume.Secret,
+			}}
+		}
+		built = append(built, runVolume)
+	}
+	return built
+}
+
+// buildVolumeMounts mounts every volume into the primary container
+func buildVolumeMounts(volumes []models.Volume) []*runpb.VolumeMount {
+	var mounts []*runpb.VolumeMount
+	for _, volume := range volumes {
+		mounts = append(mounts, &runpb.VolumeMount{Name: volume.Name, MountPath: path.Clean(volume.MountPath)})
+	}
+	return mounts
+}
+
+func volumesEqual(a, b []models.Volume) bool {
+	return slices.Equal(a, b)
+}
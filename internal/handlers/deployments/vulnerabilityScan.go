@@ -0,0 +1,108 @@
+package deployments
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/0p5dev/controller/internal/config"
+	"github.com/0p5dev/controller/internal/sharedUtils"
+	"github.com/google/go-containerregistry/pkg/name"
+	containeranalysis "google.golang.org/api/containeranalysis/v1"
+)
+
+type ImageVulnerability struct {
+	Id           string `json:"id"`
+	Severity     string `json:"severity"`
+	Package      string `json:"package,omitempty"`
+	FixAvailable bool   `json:"fix_available"`
+	Description  string `json:"description,omitempty"`
+}
+
+var vulnerabilitySeverityRank = map[string]int{
+	"MINIMAL":  1,
+	"LOW":      2,
+	"MEDIUM":   3,
+	"HIGH":     4,
+	"CRITICAL": 5,
+}
+
+// findBlockingVulnerabilities returns the scan findings for an image digest at or above VULNERABILITY_SEVERITY_THRESHOLD
+// (default CRITICAL). Scanning is skipped when SKIP_VULNERABILITY_SCAN is true or the image isn't in Artifact Registry,
+// and a slow or failing scan API lets the deploy proceed rather than blocking it.
+func findBlockingVulnerabilities(ctx context.Context, imageDigest string) []ImageVulnerability {
+	if config.Get().SkipVulnerabilityScan {
+		return nil
+	}
+
+	threshold := strings.ToUpper(os.Getenv("VULNERABILITY_SEVERITY_THRESHOLD"))
+	if threshold == "" {
+		threshold = "CRITICAL"
+	}
+	thresholdRank, ok := vulnerabilitySeverityRank[threshold]
+	if !ok {
+		slog.Warn("Invalid VULNERABILITY_SEVERITY_THRESHOLD, using CRITICAL", "value", threshold)
+		thresholdRank = vulnerabilitySeverityRank["CRITICAL"]
+	}
+
+	digest, err := name.NewDigest(imageDigest)
+	if err != nil {
+		return nil
+	}
+	registry := digest.Context().RegistryStr()
+	if !strings.HasSuffix(registry, "-docker.pkg.dev") {
+		return nil
+	}
+	// Artifact Registry repositories are addressed as <region>-docker.pkg.dev/<project>/<repository>/<image>
+	project, _, _ := strings.Cut(digest.Context().RepositoryStr(), "/")
+
+	timeout := time.Duration(sharedUtils.GetEnvInt("VULNERABILITY_SCAN_TIMEOUT_SECONDS", 10)) * time.Second
+	scanCtx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	service, err := containeranalysis.NewService(scanCtx)
+	if err != nil {
+		slog.Warn("Failed to create Container Analysis client, skipping vulnerability scan", "image", imageDigest, "error", err.Error())
+		return nil
+	}
+
+	var vulnerabilities []ImageVulnerability
+	err = service.Projects.Occurrences.List("projects/"+project).
+		Filter(fmt.Sprintf(`kind="VULNERABILITY" AND resourceUrl="https://%s"`, imageDigest)).
+		Pages(scanCtx, func(page *containeranalysis.ListOccurrencesResponse) error {
+			for _, occurrence := range page.Occurrences {
+				if occurrence.Vulnerability == nil {
+					continue
+				}
+				severity := occurrence.Vulnerability.EffectiveSeverity
+				if severity == "" || severity == "SEVERITY_UNSPECIFIED" {
+					severity = occurrence.Vulnerability.Severity
+				}
+				if vulnerabilitySeverityRank[severity] < thresholdRank {
+					continue
+				}
+
+				vulnerability := ImageVulnerability{
+					Id:           path.Base(occurrence.NoteName),
+					Severity:     severity,
+					FixAvailable: occurrence.Vulnerability.FixAvailable,
+					Description:  occurrence.Vulnerability.ShortDescription,
+				}
+				if len(occurrence.Vulnerability.PackageIssue) > 0 {
+					vulnerability.Package = occurrence.Vulnerability.PackageIssue[0].AffectedPackage
+				}
+				vulnerabilities = append(vulnerabilities, vulnerability)
+			}
+			return nil
+		})
+	if err != nil {
+		slog.Warn("Vulnerability scan lookup failed, allowing deployment", "image", imageDigest, "error", err.Error())
+		return nil
+	}
+
+	return vulnerabilities
+}
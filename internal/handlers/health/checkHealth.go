@@ -3,9 +3,13 @@ package health
 import (
 	"log/slog"
 	"net/http"
+	"sync/atomic"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/jackc/pgx/v5/pgxpool"
+
+	"github.com/0p5dev/controller/internal/sharedUtils"
 )
 
 // @Summary Health check
@@ -16,23 +20,47 @@ import (
 // @Failure 500 {object} map[string]interface{} "Service or database is unhealthy"
 // @Router /health [get]
 func CheckHealth(c *gin.Context) {
+	checkHealth(c)
+}
+
+var checkHealth = newHealthCheck(queryPostgresVersion, time.Now)
+
+// queryPostgresVersion is the database probe: a round trip to Postgres on a pooled connection
+func queryPostgresVersion(c *gin.Context) error {
 	pool := c.MustGet("Pool").(*pgxpool.Pool)
+	_, err := pool.Exec(c.Request.Context(), "SELECT version()")
+	return err
+}
+
+// newHealthCheck builds the health check handler around a database probe and a clock. Frequent probes reuse a recent
+// healthy result for HEALTH_CACHE_SECONDS (default 5) instead of each querying Postgres. Only healthy results are
+// remembered, so an outage is reported as soon as the cached result expires.
+func newHealthCheck(probe func(c *gin.Context) error, now func() time.Time) gin.HandlerFunc {
+	// When the database last answered, in Unix nanoseconds
+	var lastHealthyAt atomic.Int64
 
-	// slog.Info("Log level Info test", "key", "value")
-	// slog.Warn("Log level Warn test", "key", "value")
-	// slog.Error("Log level Error test", "key", "value")
+	return func(c *gin.Context) {
+		cacheWindow := time.Duration(sharedUtils.GetEnvInt("HEALTH_CACHE_SECONDS", 5)) * time.Second
+		if now().Sub(time.Unix(0, lastHealthyAt.Load())) < cacheWindow {
+			c.JSON(http.StatusOK, gin.H{
+				"http server": "healthy",
+				"database":    "healthy",
+			})
+			return
+		}
 
-	ctx := c.Request.Context()
-	if _, err := pool.Exec(ctx, "SELECT version()"); err != nil {
-		slog.Error("failed to query postgres version", "error", err)
-		c.JSON(http.StatusInternalServerError, gin.H{
-			"error":  "failed to query postgres version",
-			"detail": err,
+		if err := probe(c); err != nil {
+			slog.Error("failed to query postgres version", "error", err)
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"error":  "failed to query postgres version",
+				"detail": err,
+			})
+			return
+		}
+		lastHealthyAt.Store(now().UnixNano())
+		c.JSON(http.StatusOK, gin.H{
+			"http server": "healthy",
+			"database":    "healthy",
 		})
-		return
 	}
-	c.JSON(http.StatusOK, gin.H{
-		"http server": "healthy",
-		"database":    "healthy",
-	})
 }
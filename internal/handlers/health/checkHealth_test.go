@@ -0,0 +1,83 @@
+package health
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// healthProbe counts database probes and answers them with err
+type healthProbe struct {
+	calls int
+	err   error
+}
+
+func (p *healthProbe) probe(*gin.Context) error {
+	p.calls++
+	return p.err
+}
+
+func serveHealthCheck(handler gin.HandlerFunc) int {
+	gin.SetMode(gin.TestMode)
+	recorder := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(recorder)
+	c.Request = httptest.NewRequest(http.MethodGet, "/health", nil)
+	handler(c)
+	return recorder.Code
+}
+
+func TestHealthCheckCachesHealthyResult(t *testing.T) {
+	t.Setenv("HEALTH_CACHE_SECONDS", "5")
+	clock := time.Unix(1_700_000_000, 0)
+	probe := &healthProbe{}
+	handler := newHealthCheck(probe.probe, func() time.Time { return clock })
+
+	for range 3 {
+		if code := serveHealthCheck(handler); code != http.StatusOK {
+			t.Fatalf("status = %d, want 200", code)
+		}
+		clock = clock.Add(time.Second)
+	}
+	if probe.calls != 1 {
+		t.Errorf("probes within the cache window = %d, want 1", probe.calls)
+	}
+
+	clock = clock.Add(5 * time.Second)
+	serveHealthCheck(handler)
+	if probe.calls != 2 {
+		t.Errorf("probes after the cache window = %d, want 2", probe.calls)
+	}
+}
+
+func TestHealthCheckDoesNotCacheFailures(t *testing.T) {
+	t.Setenv("HEALTH_CACHE_SECONDS", "5")
+	clock := time.Unix(1_700_000_000, 0)
+	probe := &healthProbe{err: errors.New("connection refused")}
+	handler := newHealthCheck(probe.probe, func() time.Time { return clock })
+
+	for range 2 {
+		if code := serveHealthCheck(handler); code != http.StatusInternalServerError {
+			t.Fatalf("status = %d, want 500", code)
+		}
+	}
+	if probe.calls != 2 {
+		t.Errorf("probes = %d, want every unhealthy check to query the database", probe.calls)
+	}
+}
+
+func TestHealthCheckWithoutCache(t *testing.T) {
+	t.Setenv("HEALTH_CACHE_SECONDS", "0")
+	clock := time.Unix(1_700_000_000, 0)
+	probe := &healthProbe{}
+	handler := newHealthCheck(probe.probe, func() time.Time { return clock })
+
+	serveHealthCheck(handler)
+	serveHealthCheck(handler)
+	if probe.calls != 2 {
+		t.Errorf("probes = %d, want 2 with caching disabled", probe.calls)
+	}
+}
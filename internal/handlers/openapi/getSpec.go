@@ -0,0 +1,19 @@
+package openapi
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/0p5dev/controller/docs"
+)
+
+// @Summary Get the OpenAPI spec
+// @Description Get the raw Swagger/OpenAPI JSON describing this API, for generating client SDKs
+// @Tags docs
+// @Produce json
+// @Success 200 {object} map[string]interface{} "OpenAPI spec"
+// @Router /openapi.json [get]
+func GetSpec(c *gin.Context) {
+	c.Data(http.StatusOK, "application/json; charset=utf-8", []byte(docs.SwaggerInfo.ReadDoc()))
+}
@@ -3,18 +3,21 @@ package provisioningJobs
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"log/slog"
 	"net/http"
 	"time"
 
 	"github.com/0p5dev/controller/internal/middleware"
 	"github.com/0p5dev/controller/internal/models"
+	"github.com/0p5dev/controller/internal/sharedUtils"
 	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgxpool"
 )
 
 // @Summary Stream provisioning job status
-// @Description Streams provisioning status updates for a resource using Server-Sent Events (SSE). Events are emitted until status becomes succeeded or failed, or the client disconnects.
+// @Description Streams provisioning status updates for a resource using Server-Sent Events (SSE). Events are emitted until status becomes succeeded or failed, or the client disconnects. A job that has already completed emits its final status, including the service URL, as a single event.
 // @Tags provisioning-jobs
 // @Produce text/event-stream
 // @Param job_id path string true "Job ID"
@@ -37,20 +40,18 @@ func GetStatus(c *gin.Context) {
 	}
 
 	// Check if row in provisioning_jobs table exists for this job
-	var exists bool
-	err := pool.QueryRow(ctx, "SELECT EXISTS(SELECT 1 FROM provisioning_jobs WHERE id = $1 AND completed_at IS NULL)", jobId).Scan(&exists)
-	if err != nil {
+	if _, err := sharedUtils.GetProvisioningJobUpdate(ctx, pool, jobId); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			c.AbortWithStatusJSON(http.StatusNotFound, gin.H{
+				"error": "provisioning job not found for " + jobId,
+			})
+			return
+		}
 		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{
 			"error": "failed to query provisioning job status",
 		})
 		return
 	}
-	if !exists {
-		c.AbortWithStatusJSON(http.StatusNotFound, gin.H{
-			"error": "provisioning job either not found or already completed for " + jobId,
-		})
-		return
-	}
 
 	// Set SSE headers for streaming response
 	c.Header("Content-Type", "text/event-stream")
@@ -58,11 +59,22 @@ func GetStatus(c *gin.Context) {
 	c.Header("Connection", "keep-alive")
 	c.Header("Transfer-Encoding", "chunked")
 
-	// Create a channel to receive provisioning job status updates
-	statusChan := make(chan models.ProvisioningJobUpdate)
+	// Create a channel to receive provisioning job status updates. It is buffered so the hub never blocks
+	// broadcasting to a client that has already returned with the final status read from the database.
+	statusChan := make(chan models.ProvisioningJobUpdate, 4)
 	hub.RegisterClient(jobId, statusChan)
 	defer hub.UnregisterClient(jobId, statusChan)
 
+	// Read the status again after registering, so a job that finished before (or while) the client subscribed
+	// still reports its final status and URL instead of the stream waiting for a notification that already fired
+	currentStatus, err := sharedUtils.GetProvisioningJobUpdate(ctx, pool, jobId)
+	if err != nil {
+		slog.Error("Failed to query provisioning job status", "job_id", jobId, "error", err.Error())
+	} else if currentStatus.Status == "succeeded" || currentStatus.Status == "failed" {
+		sendProvisioningJobUpdate(c, pool, jobId, currentStatus)
+		return
+	}
+
 	ticker := time.NewTicker(5 * time.Second)
 	defer ticker.Stop()
 
@@ -70,21 +82,7 @@ func GetStatus(c *gin.Context) {
 	for {
 		select {
 		case statusUpdate := <-statusChan:
-			statusUpdateJson, _ := json.Marshal(map[string]string{"error": "failed to parse provisioning job update"})
-
-			if statusUpdate.Status == "succeeded" {
-				serviceUrl := "URL not available"
-				err := pool.QueryRow(context.Background(), "SELECT url FROM deployments WHERE id = (SELECT resource_id FROM provisioning_jobs WHERE id = $1)", jobId).Scan(&serviceUrl)
-				if err != nil {
-					slog.Error("Failed to query service URL for completed provisioning job", "job_id", jobId, "error", err.Error())
-				}
-				statusUpdate.ServiceUrl = &serviceUrl
-			}
-
-			statusUpdateJson, _ = json.Marshal(statusUpdate)
-
-			c.SSEvent("message", string(statusUpdateJson))
-			c.Writer.Flush()
+			sendProvisioningJobUpdate(c, pool, jobId, statusUpdate)
 
 			if statusUpdate.Status == "succeeded" || statusUpdate.Status == "failed" {
 				return
@@ -98,3 +96,25 @@ func GetStatus(c *gin.Context) {
 		}
 	}
 }
+
+// sendProvisioningJobUpdate emits a status update, attaching the deployment's URL once the job has succeeded
+func sendProvisioningJobUpdate(c *gin.Context, pool *pgxpool.Pool, jobId string, statusUpdate models.ProvisioningJobUpdate) {
+	if statusUpdate.Status == "succeeded" {
+		serviceUrl := "URL not available"
+		var health *string
+		err := pool.QueryRow(context.Background(), "SELECT url, health FROM deployments WHERE id = (SELECT resource_id FROM provisioning_jobs WHERE id = $1)", jobId).Scan(&serviceUrl, &health)
+		if err != nil {
+			slog.Error("Failed to query service URL for completed provisioning job", "job_id", jobId, "error", err.Error())
+		}
+		statusUpdate.ServiceUrl = &serviceUrl
+		statusUpdate.Health = health
+	}
+
+	statusUpdateJson, err := json.Marshal(statusUpdate)
+	if err != nil {
+		statusUpdateJson, _ = json.Marshal(map[string]string{"error": "failed to parse provisioning job update"})
+	}
+
+	c.SSEvent("message", string(statusUpdateJson))
+	c.Writer.Flush()
+}
@@ -0,0 +1,38 @@
+package version
+
+import (
+	"net/http"
+	"runtime"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Build details, set at build time with -ldflags "-X github.com/0p5dev/controller/internal/handlers/version.Version=..."
+// and likewise for Commit and BuildTime. Builds without them, such as go run during development, report the defaults.
+var (
+	Version   = "dev"
+	Commit    = "unknown"
+	BuildTime = "unknown"
+)
+
+type VersionResponse struct {
+	Version   string `json:"version"`
+	Commit    string `json:"commit"`
+	BuildTime string `json:"build_time"` // RFC3339
+	GoVersion string `json:"go_version"`
+}
+
+// @Summary Controller version
+// @Description Report which controller build is running: its version, git commit, build time, and Go runtime version
+// @Tags health
+// @Produce json
+// @Success 200 {object} version.VersionResponse "Build details"
+// @Router /version [get]
+func GetVersion(c *gin.Context) {
+	c.JSON(http.StatusOK, VersionResponse{
+		Version:   Version,
+		Commit:    Commit,
+		BuildTime: BuildTime,
+		GoVersion: runtime.Version(),
+	})
+}
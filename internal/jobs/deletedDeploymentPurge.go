@@ -0,0 +1,33 @@
+package jobs
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+
+	"github.com/0p5dev/controller/internal/sharedUtils"
+)
+
+// StartDeletedDeploymentPurge periodically removes soft-deleted deployment records once they are older than
+// DELETED_DEPLOYMENT_RETENTION_DAYS (default 90)
+func StartDeletedDeploymentPurge(pool *pgxpool.Pool) {
+	retentionDays := sharedUtils.GetEnvInt("DELETED_DEPLOYMENT_RETENTION_DAYS", 90)
+
+	go func() {
+		ticker := time.NewTicker(24 * time.Hour)
+		defer ticker.Stop()
+
+		for ; true; <-ticker.C {
+			result, err := pool.Exec(context.Background(), "DELETE FROM deployments WHERE deleted_at < NOW() - make_interval(days => $1)", retentionDays)
+			if err != nil {
+				slog.Error("Failed to purge deleted deployments", "error", err.Error())
+				continue
+			}
+			if result.RowsAffected() > 0 {
+				slog.Info("Purged deleted deployments", "count", result.RowsAffected(), "retention_days", retentionDays)
+			}
+		}
+	}()
+}
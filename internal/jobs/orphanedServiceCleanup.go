@@ -0,0 +1,147 @@
+package jobs
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"path"
+	"slices"
+	"strings"
+	"time"
+
+	run "cloud.google.com/go/run/apiv2"
+	runpb "cloud.google.com/go/run/apiv2/runpb"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"google.golang.org/api/iterator"
+
+	"github.com/0p5dev/controller/internal/config"
+	"github.com/0p5dev/controller/internal/sharedUtils"
+)
+
+// StartOrphanedServiceCleanup periodically deletes Cloud Run services created by the controller that have no
+// deployments row, which is what failed or partially deleted deployments leave behind. It is off unless
+// ORPHAN_CLEANUP_ENABLED is true; ORPHAN_CLEANUP_INTERVAL_MINUTES and ORPHAN_CLEANUP_GRACE_MINUTES tune it, and
+// ORPHAN_CLEANUP_REGIONS adds regions to the ones deployments are known to use.
+func StartOrphanedServiceCleanup(pool *pgxpool.Pool) {
+	if !config.Get().OrphanCleanupEnabled {
+		return
+	}
+
+	interval := time.Duration(sharedUtils.GetEnvInt("ORPHAN_CLEANUP_INTERVAL_MINUTES", 60)) * time.Minute
+	// Services are created before their deployments row is written, so young services are never touched
+	gracePeriod := time.Duration(sharedUtils.GetEnvInt("ORPHAN_CLEANUP_GRACE_MINUTES", 60)) * time.Minute
+
+	slog.Info("Starting orphaned Cloud Run service cleanup", "interval", interval.String(), "grace_period", gracePeriod.String())
+
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for range ticker.C {
+			if err := cleanupOrphanedServices(context.Background(), pool, gracePeriod); err != nil {
+				slog.Error("Orphaned Cloud Run service cleanup failed", "error", err.Error())
+			}
+		}
+	}()
+}
+
+func cleanupOrphanedServices(ctx context.Context, pool *pgxpool.Pool, gracePeriod time.Duration) error {
+	servicesClient, err := run.NewServicesClient(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to create Cloud Run client: %w", err)
+	}
+	defer servicesClient.Close()
+
+	regions, err := cleanupRegions(ctx, pool)
+	if err != nil {
+		return err
+	}
+
+	// A region that fails to list is skipped so the others are still cleaned
+	var failedRegions []string
+	for _, region := range regions {
+		if err := cleanupOrphanedServicesInRegion(ctx, pool, servicesClient, region, gracePeriod); err != nil {
+			slog.Error("Orphaned Cloud Run service cleanup failed in region", "region", region, "error", err.Error())
+			failedRegions = append(failedRegions, region)
+		}
+	}
+	if len(failedRegions) > 0 {
+		return fmt.Errorf("cleanup failed in regions %s", strings.Join(failedRegions, ", "))
+	}
+	return nil
+}
+
+// cleanupRegions lists the regions to look for orphans in: GCP_REGION, every region a deployment has run in,
+// including deleted ones, and any listed in ORPHAN_CLEANUP_REGIONS
+func cleanupRegions(ctx context.Context, pool *pgxpool.Pool) ([]string, error) {
+	regions := []string{config.Get().GcpRegion}
+	regions = append(regions, sharedUtils.GetEnvList("ORPHAN_CLEANUP_REGIONS")...)
+
+	rows, err := pool.Query(ctx, "SELECT DISTINCT jsonb_object_keys(region_urls) FROM deployments")
+	if err != nil {
+		return nil, fmt.Errorf("failed to list deployment regions: %w", err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var region string
+		if err := rows.Scan(&region); err != nil {
+			return nil, fmt.Errorf("failed to list deployment regions: %w", err)
+		}
+		regions = append(regions, region)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to list deployment regions: %w", err)
+	}
+
+	slices.Sort(regions)
+	return slices.Compact(regions), nil
+}
+
+func cleanupOrphanedServicesInRegion(ctx context.Context, pool *pgxpool.Pool, servicesClient *run.ServicesClient, region string, gracePeriod time.Duration) error {
+	parent := fmt.Sprintf("projects/%s/locations/%s", config.Get().GcpProjectId, region)
+	services := servicesClient.ListServices(ctx, &runpb.ListServicesRequest{Parent: parent})
+
+	for {
+		service, err := services.Next()
+		if err == iterator.Done {
+			break
+		}
+		if err != nil {
+			return fmt.Errorf("failed to list Cloud Run services: %w", err)
+		}
+
+		if service.Labels["created_by"] != "0p5dev_controller" {
+			continue
+		}
+		if service.CreateTime != nil && time.Since(service.CreateTime.AsTime()) < gracePeriod {
+			continue
+		}
+
+		serviceId := path.Base(service.Name)
+
+		var tracked bool
+		err = pool.QueryRow(ctx, `
+			SELECT EXISTS(SELECT 1 FROM deployments WHERE id = $1)
+				OR EXISTS(SELECT 1 FROM provisioning_jobs WHERE resource_id = $1 AND status = 'pending')
+		`, serviceId).Scan(&tracked)
+		if err != nil {
+			return fmt.Errorf("failed to look up deployment for service %s: %w", serviceId, err)
+		}
+		if tracked {
+			continue
+		}
+
+		deleteOp, err := servicesClient.DeleteService(ctx, &runpb.DeleteServiceRequest{Name: service.Name})
+		if err == nil {
+			_, err = deleteOp.Wait(ctx)
+		}
+		if err != nil {
+			slog.Error("Failed to delete orphaned Cloud Run service", "service", service.Name, "error", err.Error())
+			continue
+		}
+
+		slog.Info("Deleted orphaned Cloud Run service", "service", service.Name, "created_at", service.CreateTime.AsTime().Format(time.RFC3339))
+	}
+
+	return nil
+}
@@ -0,0 +1,23 @@
+package middleware
+
+import (
+	"net/http"
+
+	"github.com/0p5dev/controller/internal/sharedUtils"
+	"github.com/gin-gonic/gin"
+)
+
+// AdminMiddleware only lets through users listed in ADMIN_EMAILS. It must run after AuthMiddleware.
+func AdminMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userClaims := c.MustGet("UserClaims").(*sharedUtils.UserClaims)
+		if !sharedUtils.IsAdmin(userClaims) {
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{
+				"error": "admin access required",
+			})
+			return
+		}
+
+		c.Next()
+	}
+}
@@ -4,19 +4,25 @@ import (
 	"log/slog"
 	"net/http"
 
+	"github.com/0p5dev/controller/internal/config"
 	"github.com/0p5dev/controller/internal/sharedUtils"
 	"github.com/gin-gonic/gin"
 	"github.com/jackc/pgx/v5/pgxpool"
 	"github.com/stripe/stripe-go/v84"
 
+	"errors"
 	"fmt"
-	"os"
 	"strings"
+	"time"
 
 	"github.com/golang-jwt/jwt/v5"
 )
 
-func getUserClaims(authHeader string, pool *pgxpool.Pool, stripeClient *stripe.Client) (*sharedUtils.UserClaims, error) {
+// errJwtSecretNotConfigured fails auth closed: an empty HMAC key would verify tokens forged with an empty secret
+var errJwtSecretNotConfigured = errors.New("SUPABASE_JWT_SECRET is not configured")
+
+// parseToken verifies a bearer token's signature and its exp, nbf, and iat claims
+func parseToken(authHeader string) (*sharedUtils.OauthClaims, error) {
 	if authHeader == "" {
 		return nil, fmt.Errorf("authorization header required")
 	}
@@ -26,15 +32,27 @@ func getUserClaims(authHeader string, pool *pgxpool.Pool, stripeClient *stripe.C
 	}
 	tokenString := strings.TrimPrefix(authHeader, "Bearer ")
 
-	jwtSecret := os.Getenv("SUPABASE_JWT_SECRET")
+	jwtSecret := config.Get().SupabaseJwtSecret
+	if strings.TrimSpace(jwtSecret) == "" {
+		return nil, errJwtSecretNotConfigured
+	}
+	// Tolerate clock skew between Supabase and the controller when checking exp, nbf and iat
+	leeway := time.Duration(sharedUtils.GetEnvInt("JWT_LEEWAY_SECONDS", 30)) * time.Second
 	token, err := jwt.ParseWithClaims(tokenString, &sharedUtils.OauthClaims{}, func(token *jwt.Token) (any, error) {
 		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
 			return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
 		}
 		return []byte(jwtSecret), nil
-	})
-
-	if err != nil {
+	}, jwt.WithLeeway(leeway), jwt.WithIssuedAt())
+
+	switch {
+	case errors.Is(err, jwt.ErrTokenNotValidYet):
+		return nil, fmt.Errorf("token is not valid yet, check for clock skew: %v", err)
+	case errors.Is(err, jwt.ErrTokenUsedBeforeIssued):
+		return nil, fmt.Errorf("token was issued in the future, check for clock skew: %v", err)
+	case errors.Is(err, jwt.ErrTokenExpired):
+		return nil, fmt.Errorf("token has expired: %v", err)
+	case err != nil:
 		return nil, fmt.Errorf("invalid token: %v", err)
 	}
 
@@ -42,6 +60,14 @@ func getUserClaims(authHeader string, pool *pgxpool.Pool, stripeClient *stripe.C
 	if !ok || !token.Valid {
 		return nil, fmt.Errorf("invalid token claims")
 	}
+	return oauthClaims, nil
+}
+
+func getUserClaims(authHeader string, pool *pgxpool.Pool, stripeClient *stripe.Client) (*sharedUtils.UserClaims, error) {
+	oauthClaims, err := parseToken(authHeader)
+	if err != nil {
+		return nil, err
+	}
 
 	user, err := sharedUtils.GetOrCreateUser(pool, *oauthClaims, stripeClient)
 	if err != nil {
@@ -56,13 +82,45 @@ func getUserClaims(authHeader string, pool *pgxpool.Pool, stripeClient *stripe.C
 	return userClaims, nil
 }
 
+// WebSocketBearerProtocol is the subprotocol a browser offers, followed by its access token, to authenticate a
+// WebSocket upgrade: new WebSocket(url, ["bearer", token]). The browser WebSocket API can't set an Authorization header,
+// and unlike a query parameter the token stays out of access logs.
+const WebSocketBearerProtocol = "bearer"
+
+// webSocketBearerToken returns the token offered in a WebSocket upgrade's Sec-WebSocket-Protocol header, if any
+func webSocketBearerToken(req *http.Request) string {
+	if !strings.EqualFold(req.Header.Get("Upgrade"), "websocket") {
+		return ""
+	}
+	var protocols []string
+	for _, header := range req.Header.Values("Sec-WebSocket-Protocol") {
+		for protocol := range strings.SplitSeq(header, ",") {
+			protocols = append(protocols, strings.TrimSpace(protocol))
+		}
+	}
+	if len(protocols) != 2 || protocols[0] != WebSocketBearerProtocol {
+		return ""
+	}
+	return protocols[1]
+}
+
 func AuthMiddleware() gin.HandlerFunc {
 	return func(c *gin.Context) {
 		pool := c.MustGet("Pool").(*pgxpool.Pool)
 		stripeClient := c.MustGet("StripeClient").(*stripe.Client)
 		authHeader := c.GetHeader("Authorization")
+		if token := webSocketBearerToken(c.Request); authHeader == "" && token != "" {
+			authHeader = "Bearer " + token
+		}
 
 		userClaims, err := getUserClaims(authHeader, pool, stripeClient)
+		if errors.Is(err, errJwtSecretNotConfigured) {
+			slog.Error("Refusing to authenticate user", "error", err.Error())
+			c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{
+				"error": "internal server error: authentication is misconfigured",
+			})
+			return
+		}
 		if err != nil {
 			slog.Error("Failed to authenticate user", "error", err.Error())
 			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{
@@ -0,0 +1,119 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/stripe/stripe-go/v84"
+
+	"github.com/0p5dev/controller/internal/config"
+	"github.com/0p5dev/controller/internal/sharedUtils"
+)
+
+// signToken signs claims for user-1 with secret, using HS256 like Supabase
+func signToken(t *testing.T, secret string, claims jwt.RegisteredClaims) string {
+	t.Helper()
+	claims.Subject = "user-1"
+	token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, &sharedUtils.OauthClaims{RegisteredClaims: claims, Email: "user@example.com"}).SignedString([]byte(secret))
+	if err != nil {
+		t.Fatalf("failed to sign token: %v", err)
+	}
+	return token
+}
+
+func TestAuthMiddlewareFailsClosedWithoutSecret(t *testing.T) {
+	for _, secret := range []string{"", "   "} {
+		useConfig(t, &config.Config{SupabaseJwtSecret: secret})
+
+		// A token forged with the same empty key must not verify
+		token := signToken(t, secret, jwt.RegisteredClaims{ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Hour))})
+
+		gin.SetMode(gin.TestMode)
+		recorder := httptest.NewRecorder()
+		c, _ := gin.CreateTestContext(recorder)
+		c.Request = httptest.NewRequest(http.MethodGet, "/api/v1/deployments", nil)
+		c.Request.Header.Set("Authorization", "Bearer "+token)
+		c.Set("Pool", (*pgxpool.Pool)(nil))
+		c.Set("StripeClient", (*stripe.Client)(nil))
+
+		AuthMiddleware()(c)
+		if recorder.Code != http.StatusInternalServerError {
+			t.Errorf("secret %q: status = %d, want 500", secret, recorder.Code)
+		}
+		if _, ok := c.Get("UserClaims"); ok || !c.IsAborted() {
+			t.Errorf("secret %q: request was authenticated", secret)
+		}
+	}
+}
+
+func TestParseTokenClockSkew(t *testing.T) {
+	const secret = "test-secret"
+	useConfig(t, &config.Config{SupabaseJwtSecret: secret})
+	t.Setenv("JWT_LEEWAY_SECONDS", "30")
+	now := time.Now()
+	at := func(offset time.Duration) *jwt.NumericDate { return jwt.NewNumericDate(now.Add(offset)) }
+	expiry := at(time.Hour)
+
+	tests := []struct {
+		name    string
+		claims  jwt.RegisteredClaims
+		wantErr string
+	}{
+		{"valid", jwt.RegisteredClaims{IssuedAt: at(0), NotBefore: at(0), ExpiresAt: expiry}, ""},
+		{"nbf within leeway", jwt.RegisteredClaims{NotBefore: at(20 * time.Second), ExpiresAt: expiry}, ""},
+		{"iat within leeway", jwt.RegisteredClaims{IssuedAt: at(20 * time.Second), ExpiresAt: expiry}, ""},
+		{"exp within leeway", jwt.RegisteredClaims{ExpiresAt: at(-20 * time.Second)}, ""},
+		{"nbf beyond leeway", jwt.RegisteredClaims{NotBefore: at(2 * time.Minute), ExpiresAt: expiry}, "token is not valid yet, check for clock skew"},
+		{"iat beyond leeway", jwt.RegisteredClaims{IssuedAt: at(2 * time.Minute), ExpiresAt: expiry}, "token was issued in the future, check for clock skew"},
+		{"expired", jwt.RegisteredClaims{ExpiresAt: at(-2 * time.Minute)}, "token has expired"},
+	}
+	for _, tt := range tests {
+		_, err := parseToken("Bearer " + signToken(t, secret, tt.claims))
+		switch {
+		case tt.wantErr == "" && err != nil:
+			t.Errorf("%s: %v, want the token accepted", tt.name, err)
+		case tt.wantErr != "" && (err == nil || !strings.HasPrefix(err.Error(), tt.wantErr)):
+			t.Errorf("%s: err = %v, want %q", tt.name, err, tt.wantErr)
+		}
+	}
+}
+
+func TestParseTokenLeewayIsConfigurable(t *testing.T) {
+	const secret = "test-secret"
+	useConfig(t, &config.Config{SupabaseJwtSecret: secret})
+	token := signToken(t, secret, jwt.RegisteredClaims{
+		NotBefore: jwt.NewNumericDate(time.Now().Add(20 * time.Second)),
+		ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Hour)),
+	})
+
+	t.Setenv("JWT_LEEWAY_SECONDS", "0")
+	if _, err := parseToken("Bearer " + token); err == nil {
+		t.Error("token 20s early accepted with no leeway")
+	}
+	t.Setenv("JWT_LEEWAY_SECONDS", "60")
+	if _, err := parseToken("Bearer " + token); err != nil {
+		t.Errorf("token 20s early rejected with a 60s leeway: %v", err)
+	}
+}
+
+func TestParseTokenRejectsOtherKeysAndAlgorithms(t *testing.T) {
+	useConfig(t, &config.Config{SupabaseJwtSecret: "test-secret"})
+	claims := jwt.RegisteredClaims{ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Hour))}
+
+	if _, err := parseToken("Bearer " + signToken(t, "another-secret", claims)); err == nil {
+		t.Error("token signed with another key accepted")
+	}
+	unsigned, _ := jwt.NewWithClaims(jwt.SigningMethodNone, &claims).SignedString(jwt.UnsafeAllowNoneSignatureType)
+	if _, err := parseToken("Bearer " + unsigned); err == nil {
+		t.Error("unsigned token accepted")
+	}
+	if _, err := parseToken(signToken(t, "test-secret", claims)); err == nil {
+		t.Error("token without the Bearer prefix accepted")
+	}
+}
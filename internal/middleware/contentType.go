@@ -0,0 +1,30 @@
+package middleware
+
+import (
+	"net/http"
+	"slices"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ContentTypeMiddleware rejects requests whose Content-Type isn't one of the given media types with a 415 naming the
+// expected ones. Parameters such as charset are ignored, and so is case.
+func ContentTypeMiddleware(mediaTypes ...string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		contentType := strings.ToLower(c.ContentType())
+		if !slices.Contains(mediaTypes, contentType) {
+			message := "missing Content-Type"
+			if contentType != "" {
+				message = "unsupported Content-Type " + contentType
+			}
+			c.AbortWithStatusJSON(http.StatusUnsupportedMediaType, gin.H{
+				"error":    message,
+				"expected": mediaTypes,
+			})
+			return
+		}
+
+		c.Next()
+	}
+}
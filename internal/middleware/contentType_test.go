@@ -0,0 +1,67 @@
+package middleware
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func serveWithContentType(contentType string) *httptest.ResponseRecorder {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.POST("/", ContentTypeMiddleware(gin.MIMEJSON), func(c *gin.Context) {
+		c.Status(http.StatusNoContent)
+	})
+
+	req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(`{}`))
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+	recorder := httptest.NewRecorder()
+	router.ServeHTTP(recorder, req)
+	return recorder
+}
+
+func TestContentTypeMiddlewareAcceptsJson(t *testing.T) {
+	for _, contentType := range []string{"application/json", "application/json; charset=utf-8", "Application/JSON"} {
+		if code := serveWithContentType(contentType).Code; code != http.StatusNoContent {
+			t.Errorf("Content-Type %q: status = %d, want 204", contentType, code)
+		}
+	}
+}
+
+func TestContentTypeMiddlewareRejectsOtherTypes(t *testing.T) {
+	tests := []struct {
+		contentType string
+		error       string
+	}{
+		{"", "missing Content-Type"},
+		{"text/plain", "unsupported Content-Type text/plain"},
+		{"application/x-www-form-urlencoded", "unsupported Content-Type application/x-www-form-urlencoded"},
+	}
+	for _, tt := range tests {
+		recorder := serveWithContentType(tt.contentType)
+		if recorder.Code != http.StatusUnsupportedMediaType {
+			t.Errorf("Content-Type %q: status = %d, want 415", tt.contentType, recorder.Code)
+			continue
+		}
+
+		var body struct {
+			Error    string   `json:"error"`
+			Expected []string `json:"expected"`
+		}
+		if err := json.Unmarshal(recorder.Body.Bytes(), &body); err != nil {
+			t.Fatalf("Content-Type %q: invalid response body: %v", tt.contentType, err)
+		}
+		if body.Error != tt.error {
+			t.Errorf("Content-Type %q: error = %q, want %q", tt.contentType, body.Error, tt.error)
+		}
+		if len(body.Expected) != 1 || body.Expected[0] != gin.MIMEJSON {
+			t.Errorf("Content-Type %q: expected = %v, want [%s]", tt.contentType, body.Expected, gin.MIMEJSON)
+		}
+	}
+}
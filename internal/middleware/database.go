@@ -4,11 +4,13 @@ import (
 	"context"
 	"log/slog"
 	"net/http"
-	"os"
+	"strconv"
 	"sync"
 	"time"
 
+	"github.com/0p5dev/controller/internal/config"
 	"github.com/0p5dev/controller/internal/models"
+	"github.com/0p5dev/controller/internal/sharedUtils"
 	"github.com/gin-gonic/gin"
 	"github.com/jackc/pgx/v5/pgxpool"
 )
@@ -21,33 +23,29 @@ var (
 func DatabaseMiddleware() gin.HandlerFunc {
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 	defer cancel()
-	postgresConnectionString := os.Getenv("POSTGRES_CONNECTION_STRING")
-	pool, err := pgxpool.New(ctx, postgresConnectionString)
+	postgresConnectionString := config.Get().PostgresConnectionString
+	poolConfig, err := newPoolConfig(postgresConnectionString)
 	if err != nil {
-		slog.Error("unable to create database connection pool", "error", err)
+		slog.Error("invalid database connection string", "error", err)
 		return func(c *gin.Context) {
 			c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "internal server error: failed to connect to database"})
 		}
 	}
-
-	migrations := []struct {
-		name string
-		fn   func(*pgxpool.Pool) error
-	}{
-		{"users", models.MigrateUserTable},
-		{"usage_ledger", models.MigrateUsageLedgerTable},
-		{"provisioning_jobs", models.MigrateProvisioningJobTable},
-		{"container_images", models.MigrateContainerImageTable},
-		{"deployments", models.MigrateDeploymentTable},
+	pool, err := pgxpool.NewWithConfig(ctx, poolConfig)
+	if err != nil {
+		slog.Error("unable to create database connection pool", "error", err)
+		return func(c *gin.Context) {
+			c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "internal server error: failed to connect to database"})
+		}
 	}
 
-	for _, migration := range migrations {
-		slog.Info("Running migration", "name", migration.name)
-		if err := migration.fn(pool); err != nil {
+	for _, migration := range models.TableMigrations {
+		slog.Info("Running migration", "name", migration.Name)
+		if err := migration.Fn(pool); err != nil {
 			pool.Close()
-			slog.Error("failed to migrate table", "table", migration.name, "error", err)
+			slog.Error("failed to migrate table", "table", migration.Name, "error", err)
 			return func(c *gin.Context) {
-				c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "internal server error: failed to migrate table " + migration.name})
+				c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "internal server error: failed to migrate table " + migration.Name})
 			}
 		}
 	}
@@ -62,6 +60,26 @@ func DatabaseMiddleware() gin.HandlerFunc {
 	}
 }
 
+func newPoolConfig(connectionString string) (*pgxpool.Config, error) {
+	poolConfig, err := pgxpool.ParseConfig(connectionString)
+	if err != nil {
+		return nil, err
+	}
+	// Every connection starts with a server-side statement timeout, so a runaway query is cancelled by Postgres
+	// instead of holding its pool connection indefinitely. DB_STATEMENT_TIMEOUT_SECONDS=0 disables it.
+	if timeoutSeconds := sharedUtils.GetEnvInt("DB_STATEMENT_TIMEOUT_SECONDS", 5); timeoutSeconds > 0 {
+		poolConfig.ConnConfig.RuntimeParams["statement_timeout"] = strconv.Itoa(timeoutSeconds * 1000)
+	}
+	return poolConfig, nil
+}
+
+// DatabasePool returns the pool created by DatabaseMiddleware, for background work that runs outside a request
+func DatabasePool() *pgxpool.Pool {
+	databasePoolMu.Lock()
+	defer databasePoolMu.Unlock()
+	return databasePool
+}
+
 func CloseDatabasePool() {
 	databasePoolMu.Lock()
 	pool := databasePool
@@ -0,0 +1,78 @@
+package middleware
+
+import (
+	"bytes"
+	"compress/gzip"
+	"log/slog"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/0p5dev/controller/internal/sharedUtils"
+)
+
+// gzipBufferWriter holds the whole response back so it can be compressed, or not, once its size is known.
+// It must only wrap handlers that write a single body, never streaming ones.
+type gzipBufferWriter struct {
+	gin.ResponseWriter
+	buffer bytes.Buffer
+}
+
+func (w *gzipBufferWriter) Write(data []byte) (int, error) {
+	return w.buffer.Write(data)
+}
+
+func (w *gzipBufferWriter) WriteString(data string) (int, error) {
+	return w.buffer.WriteString(data)
+}
+
+// GzipMiddleware compresses responses for clients that accept gzip, once they reach GZIP_MIN_BYTES (default 1024).
+// Smaller responses aren't worth the overhead and are sent as they are.
+func GzipMiddleware() gin.HandlerFunc {
+	minBytes := sharedUtils.GetEnvInt("GZIP_MIN_BYTES", 1024)
+
+	return func(c *gin.Context) {
+		if c.Request.Method == http.MethodHead || !acceptsGzip(c.GetHeader("Accept-Encoding")) {
+			c.Next()
+			return
+		}
+
+		c.Header("Vary", "Accept-Encoding")
+		writer := &gzipBufferWriter{ResponseWriter: c.Writer}
+		c.Writer = writer
+		c.Next()
+		c.Writer = writer.ResponseWriter
+
+		body := writer.buffer.Bytes()
+		if len(body) < minBytes || writer.Header().Get("Content-Encoding") != "" {
+			writer.ResponseWriter.WriteHeaderNow()
+			writer.ResponseWriter.Write(body)
+			return
+		}
+
+		writer.Header().Set("Content-Encoding", "gzip")
+		writer.Header().Del("Content-Length")
+		gzipWriter := gzip.NewWriter(writer.ResponseWriter)
+		if _, err := gzipWriter.Write(body); err != nil {
+			slog.Debug("Failed to write compressed response", "error", err)
+		}
+		if err := gzipWriter.Close(); err != nil {
+			slog.Debug("Failed to finish compressed response", "error", err)
+		}
+	}
+}
+
+// acceptsGzip reports whether an Accept-Encoding header allows gzip, honoring an explicit q=0 refusal
+func acceptsGzip(acceptEncoding string) bool {
+	for _, coding := range strings.Split(acceptEncoding, ",") {
+		name, params, _ := strings.Cut(strings.TrimSpace(coding), ";")
+		name = strings.ToLower(strings.TrimSpace(name))
+		if name != "gzip" && name != "*" {
+			continue
+		}
+		quality := strings.ReplaceAll(strings.TrimSpace(params), " ", "")
+		return quality != "q=0" && quality != "q=0.0" && quality != "q=0.00" && quality != "q=0.000"
+	}
+	return false
+}
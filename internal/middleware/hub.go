@@ -5,9 +5,9 @@ import (
 	"encoding/json"
 	"fmt"
 	"log/slog"
-	"os"
 	"sync"
 
+	"github.com/0p5dev/controller/internal/config"
 	"github.com/0p5dev/controller/internal/models"
 	"github.com/gin-gonic/gin"
 	"github.com/jackc/pgx/v5"
@@ -52,7 +52,7 @@ func (hub *Hub) broadcast(update models.ProvisioningJobUpdate) {
 
 func (hub *Hub) listenForProvisioningJobUpdates(onUpdate func(models.ProvisioningJobUpdate)) error {
 	ctx := context.Background()
-	postgresConnectionString := os.Getenv("POSTGRES_CONNECTION_STRING")
+	postgresConnectionString := config.Get().PostgresConnectionString
 	conn, err := pgx.Connect(ctx, postgresConnectionString)
 	if err != nil {
 		return fmt.Errorf("error making dedicated connection to database for LISTEN/NOTIFY: %w", err)
@@ -0,0 +1,29 @@
+package middleware
+
+import (
+	"slices"
+
+	"github.com/0p5dev/controller/internal/config"
+	"github.com/0p5dev/controller/internal/sharedUtils"
+)
+
+const defaultDashboardOrigin = "https://0p5.dev"
+
+// AllowedOrigins is the browser origin allowlist from CORS_ALLOWED_ORIGINS. When it's unset, release builds allow only
+// the dashboard and everything else allows any origin ("*").
+func AllowedOrigins() []string {
+	allowedOrigins := sharedUtils.GetEnvList("CORS_ALLOWED_ORIGINS")
+	if len(allowedOrigins) == 0 {
+		if config.Get().Release {
+			return []string{defaultDashboardOrigin}
+		}
+		return []string{"*"}
+	}
+	return allowedOrigins
+}
+
+// OriginAllowed reports whether a browser on origin may call the API
+func OriginAllowed(origin string) bool {
+	allowedOrigins := AllowedOrigins()
+	return slices.Contains(allowedOrigins, "*") || slices.Contains(allowedOrigins, origin)
+}
@@ -0,0 +1,43 @@
+package middleware
+
+import (
+	"slices"
+	"testing"
+
+	"github.com/0p5dev/controller/internal/config"
+)
+
+func useConfig(t *testing.T, cfg *config.Config) {
+	t.Helper()
+	config.Set(cfg)
+	t.Cleanup(func() { config.Set(nil) })
+}
+
+func TestAllowedOriginsDefaults(t *testing.T) {
+	t.Setenv("CORS_ALLOWED_ORIGINS", "")
+
+	useConfig(t, &config.Config{Release: true})
+	if origins := AllowedOrigins(); !slices.Equal(origins, []string{"https://0p5.dev"}) {
+		t.Errorf("release origins = %v, want only the dashboard", origins)
+	}
+	if OriginAllowed("https://evil.example.com") {
+		t.Error("release allows any origin")
+	}
+
+	useConfig(t, &config.Config{Release: false})
+	if !OriginAllowed("http://localhost:3000") {
+		t.Error("development doesn't allow any origin")
+	}
+}
+
+func TestAllowedOriginsFromEnv(t *testing.T) {
+	t.Setenv("CORS_ALLOWED_ORIGINS", " https://0p5.dev ,https://staging.0p5.dev,")
+	useConfig(t, &config.Config{Release: true})
+
+	if origins := AllowedOrigins(); !slices.Equal(origins, []string{"https://0p5.dev", "https://staging.0p5.dev"}) {
+		t.Errorf("origins = %v", origins)
+	}
+	if !OriginAllowed("https://staging.0p5.dev") || OriginAllowed("https://0p5.dev.evil.example.com") {
+		t.Error("OriginAllowed doesn't match the allowlist exactly")
+	}
+}
@@ -0,0 +1,34 @@
+package middleware
+
+import (
+	"log/slog"
+	"math/rand"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/oklog/ulid/v2"
+)
+
+const RequestIdHeader = "X-Request-ID"
+
+// Propagated request IDs are echoed into logs and response headers, so only accept simple tokens
+var validRequestId = regexp.MustCompile(`^[A-Za-z0-9._-]{1,128}$`)
+
+// RequestIdMiddleware propagates the caller's X-Request-ID or generates one, and injects a logger that tags
+// every line with it so a request can be correlated with the deployment work it triggered
+func RequestIdMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		requestId := c.GetHeader(RequestIdHeader)
+		if !validRequestId.MatchString(requestId) {
+			entropy := rand.New(rand.NewSource(time.Now().UnixNano()))
+			requestId = strings.ToLower(ulid.MustNew(ulid.Timestamp(time.Now()), entropy).String())
+		}
+
+		c.Header(RequestIdHeader, requestId)
+		c.Set("RequestId", requestId)
+		c.Set("Logger", slog.Default().With("request_id", requestId))
+		c.Next()
+	}
+}
@@ -1,19 +1,88 @@
 package middleware
 
 import (
+	"fmt"
 	"log/slog"
 	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/vlad-tokarev/sloggcp"
+
+	"github.com/0p5dev/controller/internal/config"
 )
 
+// logLevel is shared by the default handler so it can be changed without rebuilding the logger
+var logLevel slog.LevelVar
+
+// ConfigureLogging installs the default slog handler from LOG_LEVEL (debug, info, warn, error) and LOG_FORMAT
+// (json, text). They default to info and GCP-formatted json in release mode, and to debug and text otherwise.
+// Sending the process SIGUSR1 toggles between debug and the configured level, e.g. to diagnose a failing
+// deployment without a redeploy.
+func ConfigureLogging() error {
+	level := slog.LevelInfo
+	format := "json"
+	if !config.Get().Release {
+		level = slog.LevelDebug
+		format = "text"
+	}
+
+	if levelStr := os.Getenv("LOG_LEVEL"); levelStr != "" {
+		if err := level.UnmarshalText([]byte(levelStr)); err != nil {
+			return fmt.Errorf("invalid LOG_LEVEL %q: must be one of debug, info, warn, error", levelStr)
+		}
+	}
+	if formatStr := strings.ToLower(os.Getenv("LOG_FORMAT")); formatStr != "" {
+		if formatStr != "json" && formatStr != "text" {
+			return fmt.Errorf("invalid LOG_FORMAT %q: must be json or text", formatStr)
+		}
+		format = formatStr
+	}
+	logLevel.Set(level)
+
+	var handler slog.Handler
+	if format == "json" {
+		handler = slog.NewJSONHandler(os.Stderr, &slog.HandlerOptions{
+			Level:       &logLevel,
+			ReplaceAttr: sloggcp.ReplaceAttr,
+			AddSource:   true,
+		})
+	} else {
+		handler = slog.NewTextHandler(os.Stderr, &slog.HandlerOptions{Level: &logLevel})
+	}
+	slog.SetDefault(slog.New(handler))
+
+	toggleSignal := make(chan os.Signal, 1)
+	signal.Notify(toggleSignal, syscall.SIGUSR1)
+	go func() {
+		for range toggleSignal {
+			if logLevel.Level() == slog.LevelDebug {
+				logLevel.Set(level)
+			} else {
+				logLevel.Set(slog.LevelDebug)
+			}
+			slog.Warn("Log level changed", "level", logLevel.Level().String())
+		}
+	}()
+
+	return nil
+}
+
 func SloggerMiddleware() gin.HandlerFunc {
-	gcpJsonHandler := slog.NewJSONHandler(os.Stderr, &slog.HandlerOptions{
-		ReplaceAttr: sloggcp.ReplaceAttr,
-		AddSource:   true,
-	})
-	slog.SetDefault(slog.New(gcpJsonHandler))
+	return func(c *gin.Context) {
+		start := time.Now()
+		c.Next()
 
-	return func(c *gin.Context) {}
+		slog.Info("Request completed",
+			"request_id", c.GetString("RequestId"),
+			"method", c.Request.Method,
+			"path", c.FullPath(),
+			"status", c.Writer.Status(),
+			"latency_ms", time.Since(start).Milliseconds(),
+			"client_ip", c.ClientIP(),
+		)
+	}
 }
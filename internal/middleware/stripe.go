@@ -1,14 +1,14 @@
 package middleware
 
 import (
-	"os"
-
 	"github.com/gin-gonic/gin"
 	"github.com/stripe/stripe-go/v84"
+
+	"github.com/0p5dev/controller/internal/config"
 )
 
 func StripeMiddleware() gin.HandlerFunc {
-	stripeClient := stripe.NewClient(os.Getenv("STRIPE_API_KEY"))
+	stripeClient := stripe.NewClient(config.Get().StripeApiKey)
 
 	return func(c *gin.Context) {
 		c.Set("StripeClient", stripeClient)
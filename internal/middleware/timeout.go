@@ -0,0 +1,81 @@
+package middleware
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/0p5dev/controller/internal/sharedUtils"
+)
+
+// timeoutWriter drops what a handler writes once its deadline has passed, unless the response was already under way,
+// so a timed-out request can still be answered with a 504
+type timeoutWriter struct {
+	gin.ResponseWriter
+	ctx context.Context
+}
+
+func (w *timeoutWriter) discards() bool {
+	return w.ctx.Err() != nil && !w.ResponseWriter.Written()
+}
+
+func (w *timeoutWriter) Write(data []byte) (int, error) {
+	if w.discards() {
+		return len(data), nil
+	}
+	return w.ResponseWriter.Write(data)
+}
+
+func (w *timeoutWriter) WriteString(data string) (int, error) {
+	if w.discards() {
+		return len(data), nil
+	}
+	return w.ResponseWriter.WriteString(data)
+}
+
+func (w *timeoutWriter) WriteHeaderNow() {
+	if !w.discards() {
+		w.ResponseWriter.WriteHeaderNow()
+	}
+}
+
+func (w *timeoutWriter) Flush() {
+	if !w.discards() {
+		w.ResponseWriter.Flush()
+	}
+}
+
+// TimeoutMiddleware bounds a route group's requests to the number of seconds in envVar (default defaultSeconds; 0 or
+// less disables it). Past the deadline the request context is canceled, and the request is answered with a 504
+// unless its handler had already started responding. Handlers must use the request context for the cancellation to
+// stop their work, and streaming or websocket routes shouldn't be in a timed group.
+func TimeoutMiddleware(envVar string, defaultSeconds int) gin.HandlerFunc {
+	timeout := time.Duration(sharedUtils.GetEnvInt(envVar, defaultSeconds)) * time.Second
+
+	return func(c *gin.Context) {
+		if timeout <= 0 {
+			c.Next()
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(c.Request.Context(), timeout)
+		defer cancel()
+		c.Request = c.Request.WithContext(ctx)
+
+		writer := &timeoutWriter{ResponseWriter: c.Writer, ctx: ctx}
+		c.Writer = writer
+		c.Next()
+		c.Writer = writer.ResponseWriter
+
+		if errors.Is(ctx.Err(), context.DeadlineExceeded) && !c.Writer.Written() {
+			c.MustGet("Logger").(*slog.Logger).Warn("Request timed out", "method", c.Request.Method, "path", c.FullPath(), "timeout", timeout.String())
+			c.AbortWithStatusJSON(http.StatusGatewayTimeout, gin.H{
+				"error": "request timed out after " + timeout.String(),
+			})
+		}
+	}
+}
@@ -0,0 +1,36 @@
+package models
+
+import (
+	"context"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+type AuditLogEntry struct {
+	Id         int64     `json:"id"`
+	ActorEmail string    `json:"actor_email"`
+	Action     string    `json:"action"` // deployment.create | deployment.update | deployment.cancel | deployment.unlock | deployment.delete | container_image.push | container_image.retag | container_image.delete
+	Target     string    `json:"target"` // deployment name or image fqin
+	SourceIp   string    `json:"source_ip"`
+	Outcome    string    `json:"outcome"` // succeeded | failed
+	CreatedAt  time.Time `json:"created_at"`
+}
+
+func MigrateAuditLogTable(pool *pgxpool.Pool) error {
+	ctx := context.Background()
+	_, err := pool.Exec(ctx, `
+		CREATE TABLE IF NOT EXISTS audit_log (
+			id BIGSERIAL PRIMARY KEY,
+			actor_email TEXT NOT NULL,
+			action TEXT NOT NULL,
+			target TEXT NOT NULL,
+			source_ip TEXT NOT NULL,
+			outcome TEXT NOT NULL,
+			created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+		);
+
+		CREATE INDEX IF NOT EXISTS idx_audit_log_created_at ON audit_log (created_at DESC);
+	`)
+	return err
+}
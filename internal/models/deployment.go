@@ -8,16 +8,69 @@ import (
 )
 
 type Deployment struct {
-	Id             string    `json:"id"`
-	Name           string    `json:"name"`
-	Url            string    `json:"url"`
-	ContainerImage string    `json:"container_image"`
-	UserId         string    `json:"user_id"`
-	MinInstances   int       `json:"min_instances"`
-	MaxInstances   int       `json:"max_instances"`
-	Port           int       `json:"port"`
-	CreatedAt      time.Time `json:"created_at"`
-	UpdatedAt      time.Time `json:"updated_at"`
+	Id                    string             `json:"id"`
+	Name                  string             `json:"name"`
+	Environment           string             `json:"environment"` // e.g. staging or production; default unless one was requested
+	Url                   string             `json:"url"`
+	ContainerImage        string             `json:"container_image"`
+	ImageDigest           *string            `json:"image_digest"`
+	UserId                string             `json:"user_id"`
+	MinInstances          int                `json:"min_instances"`
+	MaxInstances          int                `json:"max_instances"`
+	Port                  int                `json:"port"`
+	CpuAlwaysAllocated    bool               `json:"cpu_always_allocated"`
+	StartupCpuBoost       bool               `json:"startup_cpu_boost"`
+	MaxConcurrency        int                `json:"max_concurrency"`
+	RequestTimeoutSeconds int                `json:"request_timeout_seconds"`
+	VpcConnector          *string            `json:"vpc_connector"`
+	VpcNetwork            *string            `json:"vpc_network"`
+	VpcSubnet             *string            `json:"vpc_subnet"`
+	VpcEgress             *string            `json:"vpc_egress"`
+	Sidecars              []SidecarContainer `json:"sidecars"`
+	ExecutionEnvironment  *string            `json:"execution_environment"` // gen1 | gen2; null uses the Cloud Run default
+	Volumes               []Volume           `json:"volumes"`
+	Ingress               *string            `json:"ingress"`             // all | internal | internal-and-cloud-load-balancing; null is all
+	Version               *string            `json:"version"`             // git SHA or release version the deployment runs, if the user set one
+	Cpu                   *string            `json:"cpu"`                 // primary container CPU limit, e.g. 1 or 500m; null uses the Cloud Run default
+	Memory                *string            `json:"memory"`              // primary container memory limit, e.g. 512Mi; null uses the Cloud Run default
+	Revision              *string            `json:"revision"`            // Cloud Run revision created by the last create or update, in the primary region
+	DeletionProtection    bool               `json:"deletion_protection"` // blocks deletes until it is turned off
+	RegionUrls            map[string]string  `json:"region_urls"`         // region -> service URL; empty for deployments only in GCP_REGION
+	Health                *string            `json:"health"`              // ok | unreachable, from the post-deploy health check if one was requested
+	Events                []DeploymentEvent  `json:"events"`              // Cloud Run steps of the last successful create or update
+	CreatedAt             time.Time          `json:"created_at"`
+	UpdatedAt             time.Time          `json:"updated_at"`
+	DeletedAt             *time.Time         `json:"deleted_at,omitempty"`
+}
+
+// SidecarContainer is an extra container deployed next to the primary one in the same Cloud Run service
+type SidecarContainer struct {
+	Name  string            `json:"name"`
+	Image string            `json:"image"`
+	Port  *int              `json:"port,omitempty"` // makes this sidecar receive traffic instead of the primary container
+	Env   map[string]string `json:"env,omitempty"`
+}
+
+// Volume is a volume mounted into the primary container: an in-memory emptyDir, a Cloud Storage bucket, or a
+// Secret Manager secret
+type Volume struct {
+	Name      string `json:"name"`
+	Type      string `json:"type"` // emptyDir | gcs | secret
+	MountPath string `json:"mount_path"`
+	SizeLimit string `json:"size_limit,omitempty"` // emptyDir only, e.g. 512Mi
+	Bucket    string `json:"bucket,omitempty"`     // gcs only
+	ReadOnly  bool   `json:"read_only,omitempty"`  // gcs only
+	Secret    string `json:"secret,omitempty"`     // secret only; a secret name, or projects/*/secrets/* in another project
+}
+
+// DeploymentEvent is one finished Cloud Run step of a create or update, e.g. creating the service in a region
+type DeploymentEvent struct {
+	Region     string    `json:"region"`
+	Step       string    `json:"step"`   // create_service | set_iam_policy | update_service
+	Status     string    `json:"status"` // succeeded | failed
+	StartedAt  time.Time `json:"started_at"`
+	DurationMs int64     `json:"duration_ms"`
+	Error      string    `json:"error,omitempty"`
 }
 
 func MigrateDeploymentTable(pool *pgxpool.Pool) error {
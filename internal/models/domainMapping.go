@@ -0,0 +1,32 @@
+package models
+
+import (
+	"context"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+type DomainMapping struct {
+	Domain       string    `json:"domain"`
+	DeploymentId string    `json:"deployment_id"`
+	UserId       string    `json:"user_id"`
+	Region       string    `json:"region"`
+	CreatedAt    time.Time `json:"created_at"`
+}
+
+func MigrateDomainMappingTable(pool *pgxpool.Pool) error {
+	ctx := context.Background()
+	_, err := pool.Exec(ctx, `
+		CREATE TABLE IF NOT EXISTS domain_mappings (
+			domain TEXT PRIMARY KEY,
+			deployment_id TEXT NOT NULL,
+			user_id VARCHAR(26) NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+			region TEXT NOT NULL,
+			created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+		);
+
+		CREATE INDEX IF NOT EXISTS idx_domain_mappings_deployment_id ON domain_mappings (deployment_id);
+	`)
+	return err
+}
@@ -0,0 +1,29 @@
+package models
+
+import (
+	"context"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+type IdempotencyKey struct {
+	Key       string    `json:"key"`
+	UserId    string    `json:"user_id"`
+	Fqin      string    `json:"fqin"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+func MigrateIdempotencyKeyTable(pool *pgxpool.Pool) error {
+	ctx := context.Background()
+	_, err := pool.Exec(ctx, `
+		CREATE TABLE IF NOT EXISTS idempotency_keys (
+			key TEXT NOT NULL,
+			user_id VARCHAR(26) NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+			fqin TEXT NOT NULL,
+			created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+			PRIMARY KEY (user_id, key)
+		);
+	`)
+	return err
+}
@@ -0,0 +1,23 @@
+package models
+
+import "github.com/jackc/pgx/v5/pgxpool"
+
+// TableMigration creates or updates one table
+type TableMigration struct {
+	Name string
+	Fn   func(*pgxpool.Pool) error
+}
+
+// TableMigrations builds the whole schema, in dependency order
+var TableMigrations = []TableMigration{
+	{"users", MigrateUserTable},
+	{"usage_ledger", MigrateUsageLedgerTable},
+	{"provisioning_jobs", MigrateProvisioningJobTable},
+	{"container_images", MigrateContainerImageTable},
+	{"deployments", MigrateDeploymentTable},
+	{"idempotency_keys", MigrateIdempotencyKeyTable},
+	{"audit_log", MigrateAuditLogTable},
+	{"domain_mappings", MigrateDomainMappingTable},
+	// Versioned changes to the tables above; must run last
+	{"schema_migrations", RunSchemaMigrations},
+}
@@ -22,6 +22,7 @@ type ProvisioningJobUpdate struct {
 	CreatedAt   string  `json:"created_at"`
 	CompletedAt *string `json:"completed_at"`
 	ServiceUrl  *string `json:"service_url"`
+	Health      *string `json:"health,omitempty"`
 }
 
 func MigrateProvisioningJobTable(pool *pgxpool.Pool) error {
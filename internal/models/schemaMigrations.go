@@ -0,0 +1,231 @@
+package models
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// SchemaMigration is an ordered, versioned schema change. The Migrate*Table functions create the baseline
+// tables; every change to an existing table after that is added here with the next version number.
+type SchemaMigration struct {
+	Version int
+	Name    string
+	Sql     string
+}
+
+var schemaMigrations = []SchemaMigration{
+	{
+		Version: 1,
+		Name:    "deployment_settings",
+		Sql: `
+			ALTER TABLE deployments ADD COLUMN IF NOT EXISTS image_digest TEXT;
+			ALTER TABLE deployments ADD COLUMN IF NOT EXISTS cpu_always_allocated BOOLEAN NOT NULL DEFAULT FALSE;
+			ALTER TABLE deployments ADD COLUMN IF NOT EXISTS startup_cpu_boost BOOLEAN NOT NULL DEFAULT FALSE;
+			ALTER TABLE deployments ADD COLUMN IF NOT EXISTS max_concurrency INT NOT NULL DEFAULT 80;
+			ALTER TABLE deployments ADD COLUMN IF NOT EXISTS request_timeout_seconds INT NOT NULL DEFAULT 300;
+			ALTER TABLE deployments ADD COLUMN IF NOT EXISTS vpc_connector TEXT;
+			ALTER TABLE deployments ADD COLUMN IF NOT EXISTS vpc_network TEXT;
+			ALTER TABLE deployments ADD COLUMN IF NOT EXISTS vpc_subnet TEXT;
+			ALTER TABLE deployments ADD COLUMN IF NOT EXISTS vpc_egress TEXT;
+			ALTER TABLE deployments ADD COLUMN IF NOT EXISTS health TEXT;
+		`,
+	},
+	{
+		Version: 2,
+		Name:    "updated_at_triggers",
+		Sql: `
+			CREATE OR REPLACE FUNCTION set_updated_at()
+			RETURNS trigger AS $$
+			BEGIN
+			  NEW.updated_at := NOW();
+			  RETURN NEW;
+			END;
+			$$ LANGUAGE plpgsql;
+
+			DROP TRIGGER IF EXISTS trg_set_updated_at ON deployments;
+			CREATE TRIGGER trg_set_updated_at
+			BEFORE UPDATE ON deployments
+			FOR EACH ROW
+			EXECUTE FUNCTION set_updated_at();
+
+			DROP TRIGGER IF EXISTS trg_set_updated_at ON container_images;
+			CREATE TRIGGER trg_set_updated_at
+			BEFORE UPDATE ON container_images
+			FOR EACH ROW
+			EXECUTE FUNCTION set_updated_at();
+
+			DROP TRIGGER IF EXISTS trg_set_updated_at ON users;
+			CREATE TRIGGER trg_set_updated_at
+			BEFORE UPDATE ON users
+			FOR EACH ROW
+			EXECUTE FUNCTION set_updated_at();
+		`,
+	},
+	{
+		Version: 3,
+		Name:    "deployments_soft_delete",
+		Sql: `
+			ALTER TABLE deployments ADD COLUMN IF NOT EXISTS deleted_at TIMESTAMPTZ;
+			CREATE INDEX IF NOT EXISTS idx_deployments_deleted_at ON deployments (deleted_at) WHERE deleted_at IS NOT NULL;
+		`,
+	},
+	{
+		Version: 4,
+		Name:    "deployment_sidecars",
+		Sql: `
+			ALTER TABLE deployments ADD COLUMN IF NOT EXISTS sidecars JSONB NOT NULL DEFAULT '[]'::jsonb;
+		`,
+	},
+	{
+		Version: 5,
+		Name:    "deployment_region_urls",
+		Sql: `
+			ALTER TABLE deployments ADD COLUMN IF NOT EXISTS region_urls JSONB NOT NULL DEFAULT '{}'::jsonb;
+		`,
+	},
+	{
+		Version: 6,
+		Name:    "deployment_events",
+		Sql: `
+			ALTER TABLE deployments ADD COLUMN IF NOT EXISTS events JSONB NOT NULL DEFAULT '[]'::jsonb;
+		`,
+	},
+	{
+		Version: 7,
+		Name:    "deployment_execution_environment",
+		Sql: `
+			ALTER TABLE deployments ADD COLUMN IF NOT EXISTS execution_environment TEXT;
+		`,
+	},
+	{
+		Version: 8,
+		Name:    "deployment_volumes",
+		Sql: `
+			ALTER TABLE deployments ADD COLUMN IF NOT EXISTS volumes JSONB NOT NULL DEFAULT '[]'::jsonb;
+		`,
+	},
+	{
+		Version: 9,
+		Name:    "deployment_environments",
+		Sql: `
+			ALTER TABLE deployments ADD COLUMN IF NOT EXISTS environment TEXT NOT NULL DEFAULT 'default';
+		`,
+	},
+	{
+		Version: 10,
+		Name:    "deployment_deletion_protection",
+		Sql: `
+			ALTER TABLE deployments ADD COLUMN IF NOT EXISTS deletion_protection BOOLEAN NOT NULL DEFAULT false;
+		`,
+	},
+	{
+		Version: 11,
+		Name:    "deployment_ingress",
+		Sql: `
+			ALTER TABLE deployments ADD COLUMN IF NOT EXISTS ingress TEXT;
+		`,
+	},
+	{
+		Version: 12,
+		Name:    "deployment_version",
+		Sql: `
+			ALTER TABLE deployments ADD COLUMN IF NOT EXISTS version TEXT;
+		`,
+	},
+	{
+		Version: 13,
+		Name:    "deployment_resource_limits",
+		Sql: `
+			ALTER TABLE deployments ADD COLUMN IF NOT EXISTS cpu TEXT;
+			ALTER TABLE deployments ADD COLUMN IF NOT EXISTS memory TEXT;
+		`,
+	},
+	{
+		Version: 14,
+		Name:    "deployment_revision",
+		Sql: `
+			ALTER TABLE deployments ADD COLUMN IF NOT EXISTS revision TEXT;
+		`,
+	},
+	{
+		// A deployment's pending job is its lock, so at most one may exist. Older duplicates left by earlier races
+		// are failed first so the index can be built.
+		Version: 15,
+		Name:    "provisioning_job_single_pending",
+		Sql: `
+			UPDATE provisioning_jobs SET status = 'failed', completed_at = NOW()
+			WHERE status = 'pending' AND id NOT IN (
+				SELECT DISTINCT ON (resource_id) id FROM provisioning_jobs
+				WHERE status = 'pending'
+				ORDER BY resource_id, created_at DESC
+			);
+			CREATE UNIQUE INDEX IF NOT EXISTS provisioning_jobs_one_pending ON provisioning_jobs (resource_id) WHERE status = 'pending';
+		`,
+	},
+}
+
+// schemaMigrationLockKey serializes migrations across instances starting at the same time
+const schemaMigrationLockKey = 7340201
+
+// RunSchemaMigrations applies every schema migration that hasn't been recorded in schema_migrations, in version
+// order. Each migration runs in its own transaction together with the record of its version.
+func RunSchemaMigrations(pool *pgxpool.Pool) error {
+	ctx := context.Background()
+	_, err := pool.Exec(ctx, `
+		CREATE TABLE IF NOT EXISTS schema_migrations (
+			version INT PRIMARY KEY,
+			name TEXT NOT NULL,
+			applied_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+		);
+	`)
+	if err != nil {
+		return err
+	}
+
+	for _, migration := range schemaMigrations {
+		if err := applySchemaMigration(ctx, pool, migration); err != nil {
+			return fmt.Errorf("schema migration %d (%s) failed: %w", migration.Version, migration.Name, err)
+		}
+	}
+
+	return nil
+}
+
+func applySchemaMigration(ctx context.Context, pool *pgxpool.Pool, migration SchemaMigration) error {
+	tx, err := pool.BeginTx(ctx, pgx.TxOptions{})
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	// Waiting for another instance's migrations and rewriting large tables can outlast the pool's statement timeout
+	if _, err := tx.Exec(ctx, "SET LOCAL statement_timeout = 0"); err != nil {
+		return err
+	}
+	if _, err := tx.Exec(ctx, "SELECT pg_advisory_xact_lock($1)", schemaMigrationLockKey); err != nil {
+		return err
+	}
+
+	var applied bool
+	err = tx.QueryRow(ctx, "SELECT EXISTS(SELECT 1 FROM schema_migrations WHERE version = $1)", migration.Version).Scan(&applied)
+	if err != nil {
+		return err
+	}
+	if applied {
+		return nil
+	}
+
+	slog.Info("Applying schema migration", "version", migration.Version, "name", migration.Name)
+	if _, err := tx.Exec(ctx, migration.Sql); err != nil {
+		return err
+	}
+	if _, err := tx.Exec(ctx, "INSERT INTO schema_migrations (version, name) VALUES ($1, $2)", migration.Version, migration.Name); err != nil {
+		return err
+	}
+
+	return tx.Commit(ctx)
+}
@@ -0,0 +1,81 @@
+package models
+
+import (
+	"context"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/oklog/ulid/v2"
+)
+
+// testPool connects to the Postgres at TEST_DATABASE_URL and builds the schema there, or skips the test when it
+// isn't set
+func testPool(t *testing.T) *pgxpool.Pool {
+	t.Helper()
+	url := os.Getenv("TEST_DATABASE_URL")
+	if url == "" {
+		t.Skip("TEST_DATABASE_URL is not set")
+	}
+
+	pool, err := pgxpool.New(context.Background(), url)
+	if err != nil {
+		t.Fatalf("failed to connect to the test database: %v", err)
+	}
+	t.Cleanup(pool.Close)
+
+	for _, migration := range TableMigrations {
+		if err := migration.Fn(pool); err != nil {
+			t.Fatalf("failed to migrate %s: %v", migration.Name, err)
+		}
+	}
+	return pool
+}
+
+func TestSchemaMigrationVersionsIncrease(t *testing.T) {
+	for i, migration := range schemaMigrations {
+		if migration.Version != i+1 {
+			t.Errorf("migration %q has version %d, want %d", migration.Name, migration.Version, i+1)
+		}
+		if migration.Name == "" || strings.TrimSpace(migration.Sql) == "" {
+			t.Errorf("migration %d is missing its name or SQL", migration.Version)
+		}
+	}
+}
+
+func TestUpdatedAtTriggers(t *testing.T) {
+	pool := testPool(t)
+	ctx := context.Background()
+	stale := time.Date(2000, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	userId := ulid.Make().String()
+	image := "us-docker.pkg.dev/project/repo/" + strings.ToLower(userId) + ":v1"
+	t.Cleanup(func() {
+		pool.Exec(ctx, "DELETE FROM container_images WHERE fqin = $1", image)
+		pool.Exec(ctx, "DELETE FROM users WHERE id = $1", userId)
+	})
+	if _, err := pool.Exec(ctx, "INSERT INTO users (id, email, updated_at) VALUES ($1, 'trigger@example.com', $2)", userId, stale); err != nil {
+		t.Fatalf("failed to create user: %v", err)
+	}
+	if _, err := pool.Exec(ctx, "INSERT INTO container_images (fqin, user_id, updated_at) VALUES ($1, $2, $3)", image, userId, stale); err != nil {
+		t.Fatalf("failed to create image: %v", err)
+	}
+
+	// An update that doesn't mention updated_at still moves it
+	updates := map[string]string{
+		"users":            "UPDATE users SET email = 'trigger-updated@example.com' WHERE id = $1 RETURNING updated_at",
+		"container_images": "UPDATE container_images SET user_id = user_id WHERE fqin = $1 RETURNING updated_at",
+	}
+	keys := map[string]string{"users": userId, "container_images": image}
+	for table, update := range updates {
+		var updatedAt time.Time
+		if err := pool.QueryRow(ctx, update, keys[table]).Scan(&updatedAt); err != nil {
+			t.Fatalf("%s: update failed: %v", table, err)
+		}
+		if !updatedAt.After(stale) {
+			t.Errorf("%s: updated_at = %s, want it moved to the time of the update", table, updatedAt)
+		}
+	}
+}
@@ -1,34 +1,50 @@
 package routes
 
 import (
-	"os"
+	"net/url"
 
-	_ "github.com/0p5dev/controller/docs"
+	"github.com/0p5dev/controller/docs"
+	"github.com/0p5dev/controller/internal/config"
 	"github.com/0p5dev/controller/internal/middleware"
 	"github.com/gin-gonic/gin"
 	swaggerFiles "github.com/swaggo/files"
 	ginSwagger "github.com/swaggo/gin-swagger"
 
+	auditHandler "github.com/0p5dev/controller/internal/handlers/audit"
 	billingHandler "github.com/0p5dev/controller/internal/handlers/billing"
 	containerImagesHandler "github.com/0p5dev/controller/internal/handlers/containerImages"
 	deploymentsHandler "github.com/0p5dev/controller/internal/handlers/deployments"
 	healthHandler "github.com/0p5dev/controller/internal/handlers/health"
+	openapiHandler "github.com/0p5dev/controller/internal/handlers/openapi"
 	provisioningJobsHandler "github.com/0p5dev/controller/internal/handlers/provisioningJobs"
 	usersHandler "github.com/0p5dev/controller/internal/handlers/users"
+	versionHandler "github.com/0p5dev/controller/internal/handlers/version"
 )
 
 func CreateRoutes(router *gin.Engine) {
-	swaggerUrl := "http://localhost:8080/swagger/doc.json"
-	if os.Getenv("GIN_MODE") == "release" {
-		swaggerUrl = "https://controller.0p5.dev/swagger/doc.json"
+	// Without PUBLIC_BASE_URL the spec is loaded relative to the swagger UI page and leaves its host unset,
+	// so both follow whichever host served the request
+	swaggerUrl := "/swagger/doc.json"
+	docs.SwaggerInfo.Host = ""
+	if publicBaseUrl := config.Get().PublicBaseUrl; publicBaseUrl != "" {
+		swaggerUrl = publicBaseUrl + "/swagger/doc.json"
+		if parsed, err := url.Parse(publicBaseUrl); err == nil && parsed.Host != "" {
+			docs.SwaggerInfo.Host = parsed.Host
+			docs.SwaggerInfo.Schemes = []string{parsed.Scheme}
+		}
 	}
 
-	url := ginSwagger.URL(swaggerUrl)
-	router.GET("/swagger/*any", ginSwagger.WrapHandler(swaggerFiles.Handler, url))
+	router.GET("/swagger/*any", ginSwagger.WrapHandler(swaggerFiles.Handler, ginSwagger.URL(swaggerUrl)))
 
 	apiv1 := router.Group("/api/v1")
 
+	// Reads should answer quickly, while creates, updates, and deletes wait on Cloud Run and the registry
+	readTimeout := middleware.TimeoutMiddleware("READ_REQUEST_TIMEOUT_SECONDS", 5)
+	writeTimeout := middleware.TimeoutMiddleware("WRITE_REQUEST_TIMEOUT_SECONDS", 600)
+
 	apiv1.GET("/health", healthHandler.CheckHealth)
+	apiv1.GET("/version", versionHandler.GetVersion)
+	apiv1.GET("/openapi.json", openapiHandler.GetSpec)
 
 	apiv1.GET("/provisioning-jobs/:job_id/status", provisioningJobsHandler.GetStatus)
 
@@ -37,16 +53,43 @@ func CreateRoutes(router *gin.Engine) {
 	containerImages := apiv1.Group("/container-images")
 	containerImages.Use(middleware.AuthMiddleware())
 	containerImages.Use(middleware.PaymentMethodMiddleware())
-	containerImages.POST("/signed-url", containerImagesHandler.GenerateSignedUrl)
-	containerImages.POST("", containerImagesHandler.PushToRegistry)
+	// Pushes of large images, which may stream their progress, run without a request timeout
+	containerImages.POST("", middleware.ContentTypeMiddleware(gin.MIMEJSON), containerImagesHandler.PushToRegistry)
+	containerImageReads := containerImages.Group("", readTimeout)
+	containerImageReads.GET("/tags", containerImagesHandler.ListTags)
+	containerImageReads.GET("/:fqin/manifest", containerImagesHandler.GetManifest)
+	containerImageWrites := containerImages.Group("", writeTimeout)
+	containerImageWrites.POST("/signed-url", containerImagesHandler.GenerateSignedUrl)
+	containerImageWrites.DELETE("/:fqin", containerImagesHandler.DeleteOne)
+	containerImageWrites.POST("/:fqin/retag", containerImagesHandler.Retag)
 
 	deployments := apiv1.Group("/deployments")
 	deployments.Use(middleware.AuthMiddleware())
-	deployments.GET("/:name", deploymentsHandler.GetOne)
-	deployments.PATCH("/:name", deploymentsHandler.UpdateOneByName)
-	deployments.DELETE("/:name", deploymentsHandler.DeleteOneByName)
-	deployments.GET("", deploymentsHandler.GetMany)
-	deployments.POST("", middleware.PaymentMethodMiddleware(), deploymentsHandler.CreateOne)
+	// Streams and health probes bound their own work, so they run without a request timeout
+	deployments.GET("/health", deploymentsHandler.GetHealthBoard)
+	deployments.GET("/:name/ws", deploymentsHandler.StreamProgress)
+	deployments.GET("/:name/service-logs", deploymentsHandler.GetServiceLogs)
+	deploymentReads := deployments.Group("", readTimeout)
+	deploymentReads.GET("/stats", deploymentsHandler.GetStats)
+	deploymentReads.GET("/:name", deploymentsHandler.GetOne)
+	deploymentReads.GET("/:name/status", deploymentsHandler.GetLiveStatus)
+	deploymentReads.GET("/:name/export", deploymentsHandler.ExportOneByName)
+	deploymentReads.GET("/:name/domain", deploymentsHandler.GetDomains)
+	deploymentReads.GET("", middleware.GzipMiddleware(), deploymentsHandler.GetMany)
+	deploymentReads.HEAD("", deploymentsHandler.GetMany)
+	deploymentWrites := deployments.Group("", writeTimeout)
+	deploymentWrites.POST("/:name/refresh", deploymentsHandler.RefreshOneByName)
+	deploymentWrites.POST("/:name/cancel", deploymentsHandler.CancelOneByName)
+	deploymentWrites.POST("/:name/unlock", middleware.AdminMiddleware(), deploymentsHandler.UnlockOneByName)
+	deploymentWrites.POST("/:name/domain", deploymentsHandler.MapDomain)
+	deploymentWrites.PATCH("/:name", deploymentsHandler.UpdateOneByName)
+	deploymentWrites.PATCH("/:name/scaling", deploymentsHandler.UpdateScaling)
+	deploymentWrites.DELETE("/:name", deploymentsHandler.DeleteOneByName)
+	deploymentWrites.POST("", middleware.ContentTypeMiddleware(gin.MIMEJSON), middleware.PaymentMethodMiddleware(), deploymentsHandler.CreateOne)
+	deploymentWrites.POST("/bulk-delete", deploymentsHandler.BulkDelete)
+	deploymentWrites.POST("/import", middleware.PaymentMethodMiddleware(), deploymentsHandler.ImportMany)
+
+	apiv1.GET("/audit", middleware.AuthMiddleware(), middleware.AdminMiddleware(), middleware.GzipMiddleware(), auditHandler.GetMany)
 
 	billing := apiv1.Group("/billing")
 	billing.GET("/payment-method", middleware.AuthMiddleware(), billingHandler.GetUserPaymentMethod)
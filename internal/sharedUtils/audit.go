@@ -0,0 +1,49 @@
+package sharedUtils
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5/pgxpool"
+
+	"github.com/0p5dev/controller/internal/models"
+)
+
+// NewAuditLogEntry captures who is acting and from where for an audit log entry. The outcome starts as failed and
+// the caller marks it succeeded once the operation completes. It must be called while the request is being handled,
+// since the gin context can't be used once the handler returns.
+func NewAuditLogEntry(c *gin.Context, action string, target string) models.AuditLogEntry {
+	var actorEmail string
+	if userClaims, ok := c.Get("UserClaims"); ok {
+		if claims := userClaims.(*UserClaims); claims.UserMetadata.AppUser != nil {
+			actorEmail = claims.UserMetadata.AppUser.Email
+		}
+	}
+
+	return models.AuditLogEntry{
+		ActorEmail: actorEmail,
+		Action:     action,
+		Target:     target,
+		SourceIp:   c.ClientIP(),
+		Outcome:    "failed",
+	}
+}
+
+// RecordAuditLogEntry writes an audit log entry in the background. Auditing is best-effort: a failed insert is only
+// logged and never fails or delays the audited operation.
+func RecordAuditLogEntry(pool *pgxpool.Pool, entry models.AuditLogEntry) {
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+
+		_, err := pool.Exec(ctx, `
+			INSERT INTO audit_log (actor_email, action, target, source_ip, outcome)
+			VALUES ($1, $2, $3, $4, $5)
+		`, entry.ActorEmail, entry.Action, entry.Target, entry.SourceIp, entry.Outcome)
+		if err != nil {
+			slog.Warn("Failed to write audit log entry", "action", entry.Action, "target", entry.Target, "outcome", entry.Outcome, "error", err.Error())
+		}
+	}()
+}
@@ -0,0 +1,42 @@
+package sharedUtils
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/0p5dev/controller/internal/config"
+)
+
+// SetPageLinks sets an RFC 8288 Link header with first, prev, next, and last links for an offset-paginated list.
+// Links keep the request's other query params, so filters and sorting carry over to every page.
+func SetPageLinks(c *gin.Context, page int, totalPages int) {
+	lastPage := max(totalPages, 1)
+	links := []string{paginationLink(c, "page", strconv.Itoa(1), "first")}
+	if page > 1 {
+		links = append(links, paginationLink(c, "page", strconv.Itoa(min(page-1, lastPage)), "prev"))
+	}
+	if page < lastPage {
+		links = append(links, paginationLink(c, "page", strconv.Itoa(page+1), "next"))
+	}
+	links = append(links, paginationLink(c, "page", strconv.Itoa(lastPage), "last"))
+	c.Header("Link", strings.Join(links, ", "))
+}
+
+// SetCursorLinks sets a Link header for a keyset-paginated list, which can only link to its first and next pages
+func SetCursorLinks(c *gin.Context, nextCursor string) {
+	links := []string{paginationLink(c, "cursor", "", "first")}
+	if nextCursor != "" {
+		links = append(links, paginationLink(c, "cursor", nextCursor, "next"))
+	}
+	c.Header("Link", strings.Join(links, ", "))
+}
+
+// paginationLink is relative to the host that served the request unless PUBLIC_BASE_URL is set
+func paginationLink(c *gin.Context, param string, value string, rel string) string {
+	query := c.Request.URL.Query()
+	query.Set(param, value)
+	return fmt.Sprintf(`<%s%s?%s>; rel="%s"`, config.Get().PublicBaseUrl, c.Request.URL.Path, query.Encode(), rel)
+}
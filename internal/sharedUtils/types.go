@@ -10,6 +10,9 @@ type OauthClaims struct {
 	Email        string       `json:"email"`
 	Role         string       `json:"role"`
 	UserMetadata UserMetadata `json:"user_metadata"`
+	// AppMetadata is kept untyped so an unexpected value set by an admin can't make the whole token unparseable;
+	// read it through UserClaims.DeploymentSettings
+	AppMetadata map[string]any `json:"app_metadata"`
 }
 
 type UserClaims struct {
@@ -17,6 +20,30 @@ type UserClaims struct {
 	// models.User
 }
 
+// UserDeploymentSettings are per-user deployment defaults and limits, set by admins in the user's Supabase
+// app_metadata. Only app_metadata is trusted for this, since users can edit their own user_metadata.
+//
+// Recognized app_metadata keys:
+//   - default_region: region to deploy to when a request doesn't list any (default GCP_REGION)
+//   - max_instances_limit: highest max_instances the user may request; LIMIT_MAX_INSTANCES still applies when it is lower
+type UserDeploymentSettings struct {
+	DefaultRegion     string
+	MaxInstancesLimit int // 0 when unset
+}
+
+// DeploymentSettings reads the recognized app_metadata keys, ignoring any with an unexpected type or value
+func (claims *UserClaims) DeploymentSettings() UserDeploymentSettings {
+	var settings UserDeploymentSettings
+	if region, ok := claims.AppMetadata["default_region"].(string); ok {
+		settings.DefaultRegion = region
+	}
+	// JSON numbers decode as float64
+	if limit, ok := claims.AppMetadata["max_instances_limit"].(float64); ok && limit >= 1 && limit == float64(int(limit)) {
+		settings.MaxInstancesLimit = int(limit)
+	}
+	return settings
+}
+
 type UserMetadata struct {
 	AppUser           *models.User `json:"app_user"`
 	AvatarUrl         string       `json:"avatar_url"`
@@ -9,11 +9,14 @@ import (
 	"fmt"
 	"log/slog"
 	"math/rand"
+	"os"
+	"strconv"
 	"strings"
 	"time"
 
 	"github.com/0p5dev/controller/internal/models"
 	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
 	"github.com/jackc/pgx/v5/pgxpool"
 	"github.com/oklog/ulid/v2"
 	"github.com/stripe/stripe-go/v84"
@@ -29,30 +32,115 @@ func NormalizeEmail(email string) string {
 	return strings.ToLower(strings.TrimSpace(email))
 }
 
-func ValidateMinAndMaxInstances(min *int, max *int) (int, int) {
-	effectiveMin := 0
-	effectiveMax := 1
+// GetEnvInt returns the integer value of an environment variable, or fallback when it is unset or not a valid integer
+func GetEnvInt(key string, fallback int) int {
+	value := os.Getenv(key)
+	if value == "" {
+		return fallback
+	}
+
+	parsed, err := strconv.Atoi(value)
+	if err != nil {
+		slog.Warn("Invalid integer environment variable, using default", "key", key, "value", value, "default", fallback)
+		return fallback
+	}
+
+	return parsed
+}
+
+// GetEnvList returns the comma-separated values of an environment variable with whitespace and empty entries removed
+func GetEnvList(key string) []string {
+	var values []string
+	for _, value := range strings.Split(os.Getenv(key), ",") {
+		if trimmed := strings.TrimSpace(value); trimmed != "" {
+			values = append(values, trimmed)
+		}
+	}
+	return values
+}
+
+// IsAdmin reports whether the user's email is listed in the ADMIN_EMAILS environment variable
+func IsAdmin(userClaims *UserClaims) bool {
+	if userClaims == nil || userClaims.UserMetadata.AppUser == nil {
+		return false
+	}
+	email := NormalizeEmail(userClaims.UserMetadata.AppUser.Email)
+	for _, adminEmail := range GetEnvList("ADMIN_EMAILS") {
+		if NormalizeEmail(adminEmail) == email {
+			return true
+		}
+	}
+	return false
+}
+
+// foreignKeyViolationCode is the Postgres SQLSTATE for foreign_key_violation
+const foreignKeyViolationCode = "23503"
+
+// IsForeignKeyViolation reports whether a database error is a foreign key constraint violation
+func IsForeignKeyViolation(err error) bool {
+	var pgErr *pgconn.PgError
+	return errors.As(err, &pgErr) && pgErr.Code == foreignKeyViolationCode
+}
+
+// uniqueViolationCode is the Postgres SQLSTATE for unique_violation
+const uniqueViolationCode = "23505"
 
+// IsUniqueViolation reports whether a database error violates the named unique constraint or index
+func IsUniqueViolation(err error, constraint string) bool {
+	var pgErr *pgconn.PgError
+	return errors.As(err, &pgErr) && pgErr.Code == uniqueViolationCode && pgErr.ConstraintName == constraint
+}
+
+// ValidateMinAndMaxInstances resolves the instance range for a deployment. An unset min defaults to 0 and an unset max
+// to DEFAULT_MAX_INSTANCES (default 1), raised to min if needed. A max above LIMIT_MAX_INSTANCES (default 10), or
+// above userLimit when it is set, or an explicitly inverted range is rejected.
+func ValidateMinAndMaxInstances(min *int, max *int, userLimit int) (int, int, error) {
+	limitMax := GetEnvInt("LIMIT_MAX_INSTANCES", 10)
+	if userLimit > 0 && userLimit < limitMax {
+		limitMax = userLimit
+	}
+	defaultMax := GetEnvInt("DEFAULT_MAX_INSTANCES", 1)
+	if defaultMax > limitMax {
+		defaultMax = limitMax
+	}
+
+	effectiveMin := 0
 	if min != nil {
 		effectiveMin = *min
 	}
 	if effectiveMin < 0 {
-		effectiveMin = 0
+		return 0, 0, errors.New("min_instances must not be negative")
 	}
-	if effectiveMin > 10 {
-		effectiveMin = 10
+
+	effectiveMax := defaultMax
+	if effectiveMax < effectiveMin {
+		effectiveMax = effectiveMin
 	}
 	if max != nil {
 		effectiveMax = *max
+		if effectiveMax < 1 {
+			return 0, 0, errors.New("max_instances must be at least 1, or the service could never serve requests")
+		}
+		if effectiveMin > effectiveMax {
+			return 0, 0, fmt.Errorf("min_instances (%d) must not be greater than max_instances (%d)", effectiveMin, effectiveMax)
+		}
 	}
-	if effectiveMax < effectiveMin {
-		effectiveMax = effectiveMin
-	}
-	if effectiveMax > 10 {
-		effectiveMax = 10
+	if effectiveMax > limitMax {
+		return 0, 0, fmt.Errorf("max_instances must be at most %d", limitMax)
 	}
 
-	return effectiveMin, effectiveMax
+	return effectiveMin, effectiveMax, nil
+}
+
+// GetProvisioningJobUpdate reads a provisioning job's current status in the shape streamed to clients
+func GetProvisioningJobUpdate(ctx context.Context, pool *pgxpool.Pool, jobId string) (models.ProvisioningJobUpdate, error) {
+	var update models.ProvisioningJobUpdate
+	err := pool.QueryRow(ctx, `
+		SELECT id, resource_id, status, to_json(created_at)#>>'{}', to_json(completed_at)#>>'{}'
+		FROM provisioning_jobs
+		WHERE id = $1
+	`, jobId).Scan(&update.Id, &update.ResourceId, &update.Status, &update.CreatedAt, &update.CompletedAt)
+	return update, err
 }
 
 func SucceedProvisioningJob(ctx context.Context, pool *pgxpool.Pool, jobId string) {
@@ -0,0 +1,92 @@
+package sharedUtils
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+func TestIsForeignKeyViolation(t *testing.T) {
+	violation := &pgconn.PgError{Code: "23503", ConstraintName: "deployments_container_image_fkey"}
+	tests := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{"foreign key violation", violation, true},
+		{"wrapped", fmt.Errorf("failed to delete container image: %w", violation), true},
+		{"unique violation", &pgconn.PgError{Code: "23505"}, false},
+		{"not a database error", errors.New("connection reset"), false},
+		{"nil", nil, false},
+	}
+	for _, tt := range tests {
+		if got := IsForeignKeyViolation(tt.err); got != tt.want {
+			t.Errorf("%s: IsForeignKeyViolation = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestIsUniqueViolation(t *testing.T) {
+	violation := &pgconn.PgError{Code: "23505", ConstraintName: "provisioning_jobs_one_pending"}
+	if !IsUniqueViolation(fmt.Errorf("insert failed: %w", violation), "provisioning_jobs_one_pending") {
+		t.Error("wrapped violation of the named index not recognized")
+	}
+	if IsUniqueViolation(violation, "deployments_pkey") {
+		t.Error("violation of another constraint matched")
+	}
+	if IsUniqueViolation(&pgconn.PgError{Code: "23503", ConstraintName: "provisioning_jobs_one_pending"}, "provisioning_jobs_one_pending") {
+		t.Error("foreign key violation matched as unique")
+	}
+}
+
+func TestValidateMinAndMaxInstances(t *testing.T) {
+	t.Setenv("DEFAULT_MAX_INSTANCES", "2")
+	t.Setenv("LIMIT_MAX_INSTANCES", "10")
+	intPtr := func(value int) *int { return &value }
+
+	tests := []struct {
+		name      string
+		min, max  *int
+		userLimit int
+		wantMin   int
+		wantMax   int
+		wantErr   bool
+	}{
+		{"defaults", nil, nil, 0, 0, 2, false},
+		{"default max raised to min", intPtr(4), nil, 0, 4, 4, false},
+		{"explicit range", intPtr(1), intPtr(10), 0, 1, 10, false},
+		{"above the limit", nil, intPtr(11), 0, 0, 0, true},
+		{"min above the limit", intPtr(11), nil, 0, 0, 0, true},
+		{"inverted range", intPtr(5), intPtr(3), 0, 0, 0, true},
+		{"negative min", intPtr(-1), nil, 0, 0, 0, true},
+		{"zero max", nil, intPtr(0), 0, 0, 0, true},
+		{"user limit below the operator's", nil, intPtr(5), 4, 0, 0, true},
+		{"within the user limit", nil, intPtr(4), 4, 0, 4, false},
+		{"user limit above the operator's", nil, intPtr(11), 20, 0, 0, true},
+	}
+	for _, tt := range tests {
+		gotMin, gotMax, err := ValidateMinAndMaxInstances(tt.min, tt.max, tt.userLimit)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("%s: err = %v, want error %v", tt.name, err, tt.wantErr)
+			continue
+		}
+		if !tt.wantErr && (gotMin != tt.wantMin || gotMax != tt.wantMax) {
+			t.Errorf("%s: got %d..%d, want %d..%d", tt.name, gotMin, gotMax, tt.wantMin, tt.wantMax)
+		}
+	}
+}
+
+func TestValidateMinAndMaxInstancesClampsDefault(t *testing.T) {
+	// An operator default above the cap, or above the user's limit, is clamped rather than rejected
+	t.Setenv("DEFAULT_MAX_INSTANCES", "50")
+	t.Setenv("LIMIT_MAX_INSTANCES", "10")
+
+	if _, gotMax, err := ValidateMinAndMaxInstances(nil, nil, 0); err != nil || gotMax != 10 {
+		t.Errorf("max = %d, %v; want the default clamped to 10", gotMax, err)
+	}
+	if _, gotMax, err := ValidateMinAndMaxInstances(nil, nil, 3); err != nil || gotMax != 3 {
+		t.Errorf("max = %d, %v; want the default clamped to the user limit of 3", gotMax, err)
+	}
+}